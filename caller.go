@@ -0,0 +1,223 @@
+package rzerolog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// CallerFuncFieldName is the field name for the function of caller, see WithCallerFunc.
+const CallerFuncFieldName = "caller_func"
+
+// CallerFormat defines how ConsoleWriter prints the file path of caller.
+type CallerFormat int
+
+const (
+	// CallerShort prints the last directory and the file name, such as "rzerolog/logger.go:12".
+	CallerShort CallerFormat = iota
+	// CallerRelative prints the path relative to the root of the Go module in the working directory.
+	CallerRelative
+	// CallerFull prints the path as it is recorded.
+	CallerFull
+)
+
+const (
+	CallerShortName    = "short"
+	CallerRelativeName = "relative"
+	CallerFullName     = "full"
+)
+
+// DefaultCallerLinkFormat is the default target of caller hyperlinks.
+const DefaultCallerLinkFormat = "file://{path}"
+
+// String returns the name of the caller format.
+func (f CallerFormat) String() string {
+	switch f {
+	case CallerShort:
+		return CallerShortName
+	case CallerRelative:
+		return CallerRelativeName
+	case CallerFull:
+		return CallerFullName
+	}
+	return fmt.Sprintf("CallerFormat(%d)", int(f))
+}
+
+// ParseCallerFormat returns the caller format with the given name.
+// An empty name means CallerShort.
+func ParseCallerFormat(name string) (CallerFormat, error) {
+	switch name {
+	case "", CallerShortName:
+		return CallerShort, nil
+	case CallerRelativeName:
+		return CallerRelative, nil
+	case CallerFullName:
+		return CallerFull, nil
+	}
+	return 0, fmt.Errorf("unsupported caller format %q. supporting: %s %s %s", name,
+		CallerShortName, CallerRelativeName, CallerFullName)
+}
+
+// WithCallerFormat set how the file path of caller is printed.
+func WithCallerFormat(format CallerFormat) ConsoleWriterOption {
+	return func(w *ConsoleWriter) {
+		w.CallerFormat = format
+	}
+}
+
+// WithCallerLinks prints the caller as an OSC 8 hyperlink to linkFormat,
+// where "{path}" is replaced by the absolute path and "{line}" by the line.
+// An empty linkFormat means DefaultCallerLinkFormat.
+//
+// eg: "vscode://file/{path}:{line}" opens the caller in VS Code.
+func WithCallerLinks(linkFormat string) ConsoleWriterOption {
+	return func(w *ConsoleWriter) {
+		w.CallerLinks = true
+		w.CallerLinkFormat = linkFormat
+	}
+}
+
+// callerDirs is the working directory and the module root, computed once.
+var callerDirs struct {
+	once    sync.Once
+	cwd     string
+	modRoot string
+}
+
+func loadCallerDirs() {
+	callerDirs.once.Do(func() {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return
+		}
+		callerDirs.cwd = cwd
+		for dir := cwd; ; {
+			if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+				callerDirs.modRoot = dir
+				break
+			}
+			parent := filepath.Dir(dir)
+			if parent == dir {
+				break
+			}
+			dir = parent
+		}
+		if callerDirs.modRoot == "" {
+			callerDirs.modRoot = cwd
+		}
+	})
+}
+
+type callerKey struct {
+	format CallerFormat
+	caller string
+}
+
+// callerCache caches the formatted paths by format and caller, the count of callers is bounded by the call sites.
+var callerCache sync.Map
+
+// formatCallerPath returns the caller "file:line" with the file formatted in format.
+func formatCallerPath(caller string, format CallerFormat) string {
+	if format == CallerFull {
+		return caller
+	}
+	key := callerKey{format: format, caller: caller}
+	if c, ok := callerCache.Load(key); ok {
+		return c.(string)
+	}
+	loadCallerDirs()
+	c := caller
+	switch format {
+	case CallerShort:
+		if callerDirs.cwd == "" {
+			break
+		}
+		if rel, err := filepath.Rel(callerDirs.cwd, c); err == nil {
+			c = rel
+			ss := strings.Split(c, string(filepath.Separator))
+			ssl := len(ss)
+			if ssl > 1 {
+				c = ss[ssl-2] + "/" + ss[ssl-1]
+			}
+		}
+	case CallerRelative:
+		if callerDirs.modRoot == "" {
+			break
+		}
+		if rel, err := filepath.Rel(callerDirs.modRoot, c); err == nil && !strings.HasPrefix(rel, "..") {
+			c = filepath.ToSlash(rel)
+		}
+	}
+	callerCache.Store(key, c)
+	return c
+}
+
+// callerLink returns the target of the hyperlink to caller "file:line".
+func callerLink(caller, linkFormat string) string {
+	if linkFormat == "" {
+		linkFormat = DefaultCallerLinkFormat
+	}
+	path, line := caller, ""
+	if i := strings.LastIndexByte(caller, ':'); i > 0 {
+		path, line = caller[:i], caller[i+1:]
+	}
+	if !filepath.IsAbs(path) {
+		loadCallerDirs()
+		path = filepath.Join(callerDirs.cwd, path)
+	}
+	return strings.NewReplacer("{path}", filepath.ToSlash(path), "{line}", line).Replace(linkFormat)
+}
+
+// appendCaller appends the caller part in theme t, fn is the function of caller or nil.
+func (w *ConsoleWriter) appendCaller(dst []byte, caller string, fn []byte, t *Theme) []byte {
+	if caller == "" {
+		return dst
+	}
+	dst = w.appendCallerPath(dst, caller, fn, t)
+	dst = t.FieldName.appendStart(dst)
+	dst = append(dst, " >"...)
+	return t.FieldName.appendEnd(dst)
+}
+
+// appendCallerPath appends the caller without the separator " >".
+func (w *ConsoleWriter) appendCallerPath(dst []byte, caller string, fn []byte, t *Theme) []byte {
+	links := w.CallerLinks && t != &noColorTheme
+	if links {
+		// OSC 8 ; params ; URI ST
+		dst = append(dst, "\x1b]8;;"...)
+		dst = append(dst, callerLink(caller, w.CallerLinkFormat)...)
+		dst = append(dst, "\x1b\\"...)
+	}
+	dst = t.Caller.appendStart(dst)
+	dst = append(dst, formatCallerPath(caller, w.CallerFormat)...)
+	dst = t.Caller.appendEnd(dst)
+	if links {
+		dst = append(dst, "\x1b]8;;\x1b\\"...)
+	}
+	if len(fn) > 0 {
+		dst = append(dst, ' ')
+		dst = append(dst, fn...)
+	}
+	return dst
+}
+
+// callerFuncName returns the function name of the caller skip frames above.
+func callerFuncName(skip int) string {
+	pc, _, _, ok := runtime.Caller(skip + 1)
+	if !ok {
+		return ""
+	}
+	fn := runtime.FuncForPC(pc)
+	if fn == nil {
+		return ""
+	}
+	// "github.com/sophon-labs/rzerolog.(*RZeroLogger).Close" => "rzerolog.(*RZeroLogger).Close"
+	name := fn.Name()
+	if i := strings.LastIndexByte(name, '/'); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
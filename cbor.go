@@ -0,0 +1,404 @@
+package rzerolog
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"unicode/utf8"
+)
+
+var (
+	_ io.Writer  = (*CBORWriter)(nil)
+	_ FileWriter = (*CBORWriter)(nil)
+)
+
+// CBORFileHeader starts every CBOR log file and every stream appended to it:
+// the self-described CBOR tag 55799 of a map {"rzerolog": "cbor", "version": 1}.
+var CBORFileHeader = []byte("\xd9\xd9\xf7\xa2\x68rzerolog\x64cbor\x67version\x01")
+
+const (
+	cborUnsigned = 0 << 5
+	cborNegative = 1 << 5
+	cborBytes    = 2 << 5
+	cborText     = 3 << 5
+	cborArray    = 4 << 5
+	cborMap      = 5 << 5
+	cborTag      = 6 << 5
+	cborSimple   = 7 << 5
+
+	cborFalse      = 0xf4
+	cborTrue       = 0xf5
+	cborNull       = 0xf6
+	cborUndefined  = 0xf7
+	cborFloat16    = 0xf9
+	cborFloat32    = 0xfa
+	cborFloat64    = 0xfb
+	cborIndefinite = 0x1f
+	cborBreak      = 0xff
+)
+
+// CBORWriter parses the JSON input and writes it to Out as a CBOR map in the same order,
+// the log file is a CBOR sequence starting with CBORFileHeader, which is about 30% smaller than JSON.
+// The log files are converted back to JSON or text by CBORDecoder, or the command rzerolog-decode.
+//
+// NOTE: The events are always encoded in JSON by zerolog and transcoded here,
+// do not build with the "binary_log" tag of zerolog, which is not supported by the other writers.
+type CBORWriter struct {
+	Enable bool
+	// Out is the output destination.
+	Out io.Writer
+}
+
+// NewCBORWriter creates a CBORWriter writing to out.
+func NewCBORWriter(out io.Writer) *CBORWriter {
+	return &CBORWriter{Enable: true, Out: out}
+}
+
+// SetOutput set the output, and writes CBORFileHeader to it if it is a new or empty file,
+// or a stream wrapping the file, such as a gzip stream.
+func (w *CBORWriter) SetOutput(out io.WriteCloser) error {
+	w.Out = out
+	if !isNewOutput(out) {
+		return nil
+	}
+	_, err := out.Write(CBORFileHeader)
+	return err
+}
+
+// Write transcodes the JSON input to CBOR and appends it to w.Out.
+func (w *CBORWriter) Write(p []byte) (n int, err error) {
+	if !w.Enable {
+		return len(p), nil
+	}
+
+	evt := getConsoleEvent()
+	defer evt.release()
+
+	if err = evt.parse(p); err != nil {
+		return n, fmt.Errorf("cannot decode event: %s", err)
+	}
+	for i := range evt.fields {
+		evt.order = append(evt.order, i)
+	}
+	evt.dedupOrder()
+	evt.buf = appendCBORHead(evt.buf, cborMap, uint64(len(evt.order)))
+	for _, idx := range evt.order {
+		f := &evt.fields[idx]
+		evt.buf = appendCBORHead(evt.buf, cborText, uint64(len(f.key)))
+		evt.buf = append(evt.buf, f.key...)
+		if evt.buf, err = evt.appendCBORValue(evt.buf, f.kind, f.value); err != nil {
+			return n, fmt.Errorf("cannot encode event: %s", err)
+		}
+	}
+	_, err = w.Out.Write(evt.buf)
+	return len(p), err
+}
+
+// appendCBORHead appends the head of a data item of major type with the argument n.
+func appendCBORHead(dst []byte, major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return append(dst, major|byte(n))
+	case n <= math.MaxUint8:
+		return append(dst, major|24, byte(n))
+	case n <= math.MaxUint16:
+		return append(dst, major|25, byte(n>>8), byte(n))
+	case n <= math.MaxUint32:
+		return append(dst, major|26, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	}
+	dst = append(dst, major|27)
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], n)
+	return append(dst, b[:]...)
+}
+
+// appendCBORValue appends the JSON value of kind as CBOR.
+func (e *consoleEvent) appendCBORValue(dst []byte, kind jsonKind, value []byte) ([]byte, error) {
+	switch kind {
+	case jsonString:
+		dst = appendCBORHead(dst, cborText, uint64(len(value)))
+		return append(dst, value...), nil
+	case jsonNumber:
+		return appendCBORNumber(dst, value)
+	case jsonBool:
+		if value[0] == 't' {
+			return append(dst, cborTrue), nil
+		}
+		return append(dst, cborFalse), nil
+	case jsonNull:
+		return append(dst, cborNull), nil
+	}
+	// the composites are indefinite-length, which saves counting the members
+	isArray := kind == jsonArray
+	if isArray {
+		dst = append(dst, cborArray|cborIndefinite)
+	} else {
+		dst = append(dst, cborMap|cborIndefinite)
+	}
+	var err error
+	if ferr := e.forEachMember(value, func(key []byte, kind jsonKind, value []byte) {
+		if err != nil {
+			return
+		}
+		if !isArray {
+			dst = appendCBORHead(dst, cborText, uint64(len(key)))
+			dst = append(dst, key...)
+		}
+		dst, err = e.appendCBORValue(dst, kind, value)
+	}); ferr != nil {
+		return dst, ferr
+	}
+	if err != nil {
+		return dst, err
+	}
+	return append(dst, cborBreak), nil
+}
+
+// appendCBORNumber appends the JSON number as an integer if it is, otherwise a float64.
+func appendCBORNumber(dst, number []byte) ([]byte, error) {
+	integer := true
+	for _, c := range number {
+		if c == '.' || c == 'e' || c == 'E' {
+			integer = false
+			break
+		}
+	}
+	if integer {
+		if number[0] == '-' {
+			if n, err := strconv.ParseInt(string(number), 10, 64); err == nil {
+				if n == 0 {
+					// -0 is the integer 0
+					return appendCBORHead(dst, cborUnsigned, 0), nil
+				}
+				return appendCBORHead(dst, cborNegative, uint64(-(n + 1))), nil
+			}
+		} else if n, err := strconv.ParseUint(string(number), 10, 64); err == nil {
+			return appendCBORHead(dst, cborUnsigned, n), nil
+		}
+	}
+	f, err := strconv.ParseFloat(string(number), 64)
+	if err != nil {
+		return dst, err
+	}
+	dst = append(dst, cborFloat64)
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], math.Float64bits(f))
+	return append(dst, b[:]...), nil
+}
+
+var errCBORBreak = errors.New("cbor: unexpected break")
+
+// CBORDecoder reads the events of a CBOR log file written by CBORWriter, and converts them to JSON.
+// The headers are skipped wherever they are, such as in the files appended to by several streams.
+type CBORDecoder struct {
+	r *bufio.Reader
+}
+
+// NewCBORDecoder creates a CBORDecoder reading from r.
+func NewCBORDecoder(r io.Reader) *CBORDecoder {
+	return &CBORDecoder{r: bufio.NewReader(r)}
+}
+
+// Next appends the next event as a JSON object to dst, it returns io.EOF if there are no more events.
+func (d *CBORDecoder) Next(dst []byte) ([]byte, error) {
+	for {
+		b, err := d.r.Peek(len(CBORFileHeader))
+		if err == nil && string(b) == string(CBORFileHeader) {
+			_, _ = d.r.Discard(len(CBORFileHeader))
+			continue
+		}
+		if _, err = d.r.Peek(1); err != nil {
+			return dst, err
+		}
+		dst, err = d.appendItem(dst)
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return dst, err
+	}
+}
+
+// head reads the head of a data item, and returns the major type, the additional info and its argument.
+func (d *CBORDecoder) head() (major, info byte, n uint64, err error) {
+	b, err := d.r.ReadByte()
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	major, info = b&0xe0, b&0x1f
+	switch {
+	case info < 24:
+		return major, info, uint64(info), nil
+	case info <= 27:
+		size := 1 << (info - 24)
+		var buf [8]byte
+		if _, err = io.ReadFull(d.r, buf[8-size:]); err != nil {
+			return 0, 0, 0, err
+		}
+		return major, info, binary.BigEndian.Uint64(buf[:]), nil
+	case info == cborIndefinite:
+		return major, info, 0, nil
+	}
+	return 0, 0, 0, fmt.Errorf("cbor: invalid additional info %d", info)
+}
+
+// appendItem appends the next data item as JSON to dst.
+func (d *CBORDecoder) appendItem(dst []byte) ([]byte, error) {
+	major, info, n, err := d.head()
+	if err != nil {
+		return dst, err
+	}
+	indefinite := info == cborIndefinite
+	switch major {
+	case cborUnsigned:
+		return strconv.AppendUint(dst, n, 10), nil
+	case cborNegative:
+		if n == math.MaxUint64 {
+			return append(dst, "-18446744073709551616"...), nil
+		} else if n >= math.MaxInt64 {
+			// -1-n overflows int64
+			return strconv.AppendUint(append(dst, '-'), n+1, 10), nil
+		}
+		return strconv.AppendInt(dst, -1-int64(n), 10), nil
+	case cborBytes, cborText:
+		var s []byte
+		if s, err = d.readString(major, n, indefinite); err != nil {
+			return dst, err
+		}
+		if !utf8.Valid(s) {
+			// the byte strings are written as their hex digits
+			dst = append(dst, '"')
+			for _, c := range s {
+				dst = append(dst, lowerhex[c>>4], lowerhex[c&0xF])
+			}
+			return append(dst, '"'), nil
+		}
+		return appendJSONString(dst, s), nil
+	case cborArray, cborMap:
+		return d.appendComposite(dst, major == cborMap, n, indefinite)
+	case cborTag:
+		// the tagged items are written as they are, such as epoch times as numbers
+		return d.appendItem(dst)
+	}
+
+	switch cborSimple | info {
+	case cborFalse:
+		return append(dst, "false"...), nil
+	case cborTrue:
+		return append(dst, "true"...), nil
+	case cborNull, cborUndefined:
+		return append(dst, "null"...), nil
+	case cborBreak:
+		return dst, errCBORBreak
+	case cborFloat16:
+		return appendJSONFloat(dst, float64(halfToFloat32(uint16(n)))), nil
+	case cborFloat32:
+		return appendJSONFloat(dst, float64(math.Float32frombits(uint32(n)))), nil
+	case cborFloat64:
+		return appendJSONFloat(dst, math.Float64frombits(n)), nil
+	}
+	// the other simple values
+	return strconv.AppendUint(dst, n, 10), nil
+}
+
+func (d *CBORDecoder) readString(major byte, n uint64, indefinite bool) ([]byte, error) {
+	if !indefinite {
+		if n > 1<<30 {
+			return nil, fmt.Errorf("cbor: string too long: %d", n)
+		}
+		s := make([]byte, n)
+		_, err := io.ReadFull(d.r, s)
+		return s, err
+	}
+	var s []byte
+	for {
+		if b, err := d.r.Peek(1); err != nil {
+			return nil, err
+		} else if b[0] == cborBreak {
+			_, _ = d.r.Discard(1)
+			return s, nil
+		}
+		m, _, n, err := d.head()
+		if err != nil {
+			return nil, err
+		}
+		if m != major {
+			return nil, fmt.Errorf("cbor: invalid chunk of indefinite string")
+		}
+		chunk, err := d.readString(major, n, false)
+		if err != nil {
+			return nil, err
+		}
+		s = append(s, chunk...)
+	}
+}
+
+func (d *CBORDecoder) appendComposite(dst []byte, isMap bool, n uint64, indefinite bool) ([]byte, error) {
+	open, close := byte('['), byte(']')
+	if isMap {
+		open, close = '{', '}'
+	}
+	dst = append(dst, open)
+	for i := uint64(0); indefinite || i < n; i++ {
+		if indefinite {
+			b, err := d.r.Peek(1)
+			if err != nil {
+				return dst, err
+			}
+			if b[0] == cborBreak {
+				_, _ = d.r.Discard(1)
+				break
+			}
+		}
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		var err error
+		if isMap {
+			start := len(dst)
+			if dst, err = d.appendItem(dst); err != nil {
+				return dst, err
+			}
+			if len(dst) == start || dst[start] != '"' {
+				// JSON keys are strings
+				key := append([]byte(nil), dst[start:]...)
+				dst = appendJSONString(dst[:start], key)
+			}
+			dst = append(dst, ':')
+		}
+		if dst, err = d.appendItem(dst); err != nil {
+			return dst, err
+		}
+	}
+	return append(dst, close), nil
+}
+
+// appendJSONFloat appends f as a JSON number, or null if it is NaN or infinite.
+func appendJSONFloat(dst []byte, f float64) []byte {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return append(dst, "null"...)
+	}
+	return strconv.AppendFloat(dst, f, 'g', -1, 64)
+}
+
+// halfToFloat32 converts an IEEE 754 half-precision float.
+func halfToFloat32(h uint16) float32 {
+	sign := uint32(h>>15) << 31
+	exp := uint32(h>>10) & 0x1f
+	frac := uint32(h) & 0x3ff
+	switch exp {
+	case 0:
+		// subnormal
+		f := float32(frac) / (1 << 24)
+		if sign != 0 {
+			f = -f
+		}
+		return f
+	case 0x1f:
+		return math.Float32frombits(sign | 0x7f800000 | frac<<13)
+	}
+	return math.Float32frombits(sign | (exp+112)<<23 | frac<<13)
+}
@@ -0,0 +1,112 @@
+package rzerolog
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func decodeCBORLines(t *testing.T, b []byte) []string {
+	dec := NewCBORDecoder(bytes.NewReader(b))
+	var lines []string
+	for {
+		line, err := dec.Next(nil)
+		if err == io.EOF {
+			return lines
+		}
+		require.Nil(t, err)
+		lines = append(lines, string(line))
+	}
+}
+
+func TestCBORWriter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := NewCBORWriter(buf)
+	input := `{"level":"info","n":-3,"big":18446744073709551615,"f":1.5,"ok":true,"none":null,` +
+		`"nested":{"a":[1,"b\n",{"c":false}],"e":{}},"list":[],"message":"héllo \"x\""}`
+	_, err := w.Write([]byte(input))
+	require.Nil(t, err)
+	require.Less(t, buf.Len(), len(input))
+	require.Equal(t, []string{input}, decodeCBORLines(t, buf.Bytes()))
+
+	// the duplicated fields are written once, as the last value
+	buf.Reset()
+	_, err = w.Write([]byte(`{"a":1,"a":2}`))
+	require.Nil(t, err)
+	require.Equal(t, "\xa1\x61a\x02", buf.String())
+
+	buf.Reset()
+	_, err = w.Write([]byte(`{"z":-0,"n":-1}`))
+	require.Nil(t, err)
+	require.Equal(t, []string{`{"z":0,"n":-1}`}, decodeCBORLines(t, buf.Bytes()))
+
+	_, err = w.Write([]byte(`{"a":`))
+	require.Error(t, err)
+
+	cfg := defaultConfig()
+	cfg.apply(WithLogFormat(LogFormatCBOR))
+	_, ok := cfg.fw.writer.(*CBORWriter)
+	require.True(t, ok)
+}
+
+func TestCBORLogFile(t *testing.T) {
+	fs := NewMemFileSystem()
+	w := newMemLogFileWriter(t, fs, WithLogFormat(LogFormatCBOR))
+
+	_, err := w.Write([]byte(`{"level":"info","message":"first"}`))
+	require.Nil(t, err)
+	require.Nil(t, w.Close())
+	// the header is not repeated in the reopened file
+	_, err = w.Write([]byte(`{"level":"warn","message":"second"}`))
+	require.Nil(t, err)
+	require.Nil(t, w.Close())
+
+	b, err := fs.ReadFile("logs/app.log")
+	require.Nil(t, err)
+	require.True(t, bytes.HasPrefix(b, CBORFileHeader))
+	require.Equal(t, 1, bytes.Count(b, CBORFileHeader))
+	require.Equal(t, []string{`{"level":"info","message":"first"}`, `{"level":"warn","message":"second"}`},
+		decodeCBORLines(t, b))
+
+	// the headers in the middle are skipped, such as the gzip members appended
+	b = append(b, CBORFileHeader...)
+	b = append(b, "\xa1\x67message\x65third"...)
+	lines := decodeCBORLines(t, b)
+	require.Equal(t, `{"message":"third"}`, lines[2])
+
+	out := &bytes.Buffer{}
+	cw := &ConsoleWriter{Enable: true, NoColor: true, Out: out}
+	for _, line := range lines[:2] {
+		_, err = cw.Write([]byte(line))
+		require.Nil(t, err)
+	}
+	require.Equal(t, "<nil> INF first\n<nil> WRN second\n", out.String())
+}
+
+func TestCBORDecoder(t *testing.T) {
+	for _, c := range []struct{ in, out string }{
+		{"\x18\x64", "100"},
+		{"\x38\x63", "-100"},
+		{"\x3b\xff\xff\xff\xff\xff\xff\xff\xff", "-18446744073709551616"},
+		{"\xf9\x3c\x00", "1"},
+		{"\xf9\xc4\x00", "-4"},
+		{"\xfa\x47\xc3\x50\x00", "100000"},
+		{"\xf9\x7c\x00", "null"},
+		{"\xc1\x1a\x51\x4b\x67\xb0", "1363896240"},
+		{"\x43\x01\x02\xff", `"0102ff"`},
+		{"\x7f\x62ab\x61c\xff", `"abc"`},
+		{"\x9f\x01\x82\x02\x03\xff", "[1,[2,3]]"},
+		{"\xa2\x01\x02\x61a\xf7", `{"1":2,"a":null}`},
+	} {
+		b, err := NewCBORDecoder(bytes.NewReader([]byte(c.in))).Next(nil)
+		require.Nil(t, err, c.out)
+		require.Equal(t, c.out, string(b))
+	}
+
+	_, err := NewCBORDecoder(bytes.NewReader([]byte("\xa1\x61a"))).Next(nil)
+	require.Equal(t, io.ErrUnexpectedEOF, err)
+	_, err = NewCBORDecoder(bytes.NewReader([]byte("\xff"))).Next(nil)
+	require.Error(t, err)
+}
@@ -0,0 +1,94 @@
+// Command rzerolog-decode converts the CBOR log files written in the "cbor" log format
+// back to JSON lines or console text.
+//
+//	rzerolog-decode [-format json|text] [-color] [file ...]
+//
+// The files are read in order, the standard input if there are no files.
+// Files ending in ".gz" are decompressed.
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/sophon-labs/rzerolog"
+)
+
+func main() {
+	format := flag.String("format", rzerolog.LogFormatJSON, "output format, json or text")
+	color := flag.Bool("color", false, "colorize the text output")
+	flag.Parse()
+
+	out := bufio.NewWriter(os.Stdout)
+	var w io.Writer = out
+	switch *format {
+	case rzerolog.LogFormatJSON:
+	case rzerolog.LogFormatConsoleText:
+		// the output is colorized by the flag, not by the terminal
+		w = &rzerolog.ConsoleWriter{Enable: true, NoColor: !*color, ColorMode: rzerolog.ColorAlways, Out: out}
+	default:
+		fmt.Fprintf(os.Stderr, "unsupported format %q. supporting: %s %s\n",
+			*format, rzerolog.LogFormatJSON, rzerolog.LogFormatConsoleText)
+		os.Exit(2)
+	}
+
+	files := flag.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	code := 0
+	for _, name := range files {
+		if err := decode(name, w); err != nil {
+			fmt.Fprintf(os.Stderr, "rzerolog-decode: %s: %v\n", name, err)
+			code = 1
+		}
+	}
+	if err := out.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "rzerolog-decode: %v\n", err)
+		code = 1
+	}
+	os.Exit(code)
+}
+
+// decode writes the events of the file name to w, one JSON object per write.
+func decode(name string, w io.Writer) error {
+	var r io.Reader = os.Stdin
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	if strings.HasSuffix(name, ".gz") {
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		r = zr
+	}
+
+	dec := rzerolog.NewCBORDecoder(r)
+	var buf []byte
+	for {
+		var err error
+		buf, err = dec.Next(buf[:0])
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		buf = append(buf, '\n')
+		if _, err = w.Write(buf); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package rzerolog
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+)
+
+// ColorMode defines when the text output is colorized.
+type ColorMode int
+
+const (
+	// ColorAlways always colorizes the output, it is the zero value as ConsoleWriter always did.
+	ColorAlways ColorMode = iota
+	// ColorAuto colorizes the output only if it is a terminal.
+	// The NO_COLOR and FORCE_COLOR environment variables take precedence over the detection.
+	ColorAuto
+	// ColorNever never colorizes the output.
+	ColorNever
+)
+
+const (
+	ColorAutoName   = "auto"
+	ColorAlwaysName = "always"
+	ColorNeverName  = "never"
+)
+
+// String returns the name of the color mode.
+func (m ColorMode) String() string {
+	switch m {
+	case ColorAuto:
+		return ColorAutoName
+	case ColorAlways:
+		return ColorAlwaysName
+	case ColorNever:
+		return ColorNeverName
+	}
+	return fmt.Sprintf("ColorMode(%d)", int(m))
+}
+
+// ParseColorMode returns the color mode with the given name.
+// An empty name means ColorAuto.
+func ParseColorMode(name string) (ColorMode, error) {
+	switch name {
+	case "", ColorAutoName:
+		return ColorAuto, nil
+	case ColorAlwaysName:
+		return ColorAlways, nil
+	case ColorNeverName:
+		return ColorNever, nil
+	}
+	return 0, fmt.Errorf("unsupported color mode %q. supporting: %s %s %s", name,
+		ColorAutoName, ColorAlwaysName, ColorNeverName)
+}
+
+// states of ColorAuto resolved for the current output of ConsoleWriter
+const (
+	colorStateUnresolved uint32 = iota
+	colorStateOn
+	colorStateOff
+)
+
+// colorDisabled reports whether the colors are disabled for Out.
+func (w *ConsoleWriter) colorDisabled() bool {
+	return w.colorDisabledFor(w.Out, &w.colorState)
+}
+
+// colorDisabledFor reports whether the colors are disabled for out.
+//
+// ColorAuto is resolved on the first write and cached in state until the output is changed by SetOutput.
+func (w *ConsoleWriter) colorDisabledFor(out io.Writer, state *uint32) bool {
+	if w.NoColor {
+		return true
+	}
+	switch w.ColorMode {
+	case ColorAlways:
+		return false
+	case ColorNever:
+		return true
+	}
+	s := atomic.LoadUint32(state)
+	if s == colorStateUnresolved {
+		s = colorStateOff
+		if detectColor(out) {
+			s = colorStateOn
+		}
+		atomic.StoreUint32(state, s)
+	}
+	return s == colorStateOff
+}
+
+// detectColor reports whether out should be colorized in ColorAuto mode.
+//
+// NOTE: NO_COLOR disables the colors if it is set to any non-empty value (https://no-color.org),
+// FORCE_COLOR enables the colors if it is set to any non-empty value except "0" and "false".
+func detectColor(out io.Writer) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	switch force := os.Getenv("FORCE_COLOR"); force {
+	case "":
+	case "0", "false":
+		return false
+	default:
+		return true
+	}
+	return isTerminal(out)
+}
+
+// isTerminal reports whether out is a terminal.
+func isTerminal(out io.Writer) bool {
+	f, ok := out.(interface{ Fd() uintptr })
+	if !ok {
+		return false
+	}
+	return isTerminalFd(f.Fd())
+}
@@ -3,34 +3,128 @@ package config
 import "os"
 
 type LoggerConfig struct {
-	Enable             bool   `mapstructure:"enable" json:"enable"`
-	EnableConsolePrint bool   `mapstructure:"enable_console_print" json:"enable_console_print"`
-	EnableLogFiles     bool   `mapstructure:"enable_log_files" json:"enable_log_files"`
-	FileLogFormat      string `mapstructure:"file_log_format" json:"file_log_format"`
-	LogFilesPath       string `mapstructure:"log_files_path" json:"log_files_path"`
-	LogFileName        string `mapstructure:"log_file_name" json:"log_file_name"`
-	EnableTimeRolling  bool   `mapstructure:"enable_time_rolling" json:"enable_time_rolling"`
-	EnableSizeRolling  bool   `mapstructure:"enable_size_rolling" json:"enable_size_rolling"`
-	MaxFileSizeKB      int64  `mapstructure:"max_file_size_kb" json:"max_file_size_kb"`
-	MaxFilesCount      int    `mapstructure:"max_files_count" json:"max_files_count"`
-	Level              string `mapstructure:"level" json:"level"`
-	Label              string `mapstructure:"label" json:"label"`
+	Enable                     bool     `mapstructure:"enable" json:"enable"`
+	EnableConsolePrint         bool     `mapstructure:"enable_console_print" json:"enable_console_print"`
+	ConsoleColorMode           string   `mapstructure:"console_color_mode" json:"console_color_mode"`
+	ConsoleTheme               string   `mapstructure:"console_theme" json:"console_theme"`
+	ConsoleLabelColors         bool     `mapstructure:"console_label_colors" json:"console_label_colors"`
+	ConsoleLabelColorOverrides []string `mapstructure:"console_label_color_overrides" json:"console_label_color_overrides"`
+	ConsoleLayout              string   `mapstructure:"console_layout" json:"console_layout"`
+	ConsolePartWidths          []string `mapstructure:"console_part_widths" json:"console_part_widths"`
+	ConsoleWidth               int      `mapstructure:"console_width" json:"console_width"`
+	ConsoleOverflow            string   `mapstructure:"console_overflow" json:"console_overflow"`
+	ConsoleMultiline           string   `mapstructure:"console_multiline" json:"console_multiline"`
+	ConsoleCallerLinks         bool     `mapstructure:"console_caller_links" json:"console_caller_links"`
+	ConsoleCallerLinkFormat    string   `mapstructure:"console_caller_link_format" json:"console_caller_link_format"`
+	ConsoleLogFormat           string   `mapstructure:"console_log_format" json:"console_log_format"`
+	ConsoleStderrLevel         string   `mapstructure:"console_stderr_level" json:"console_stderr_level"`
+	EnableLogFiles             bool     `mapstructure:"enable_log_files" json:"enable_log_files"`
+	FileLogFormat              string   `mapstructure:"file_log_format" json:"file_log_format"`
+	CSVColumns                 []string `mapstructure:"csv_columns" json:"csv_columns"`
+	TextFieldOrder             string   `mapstructure:"text_field_order" json:"text_field_order"`
+	TextPriorityFields         []string `mapstructure:"text_priority_fields" json:"text_priority_fields"`
+	TextNested                 string   `mapstructure:"text_nested" json:"text_nested"`
+	TextNestedDepth            int      `mapstructure:"text_nested_depth" json:"text_nested_depth"`
+	TextCallerFormat           string   `mapstructure:"text_caller_format" json:"text_caller_format"`
+	TextLabelWidth             int      `mapstructure:"text_label_width" json:"text_label_width"`
+	TextTemplate               string   `mapstructure:"text_template" json:"text_template"`
+	LogFilesPath               string   `mapstructure:"log_files_path" json:"log_files_path"`
+	LogFileName                string   `mapstructure:"log_file_name" json:"log_file_name"`
+	LogFileMode                string   `mapstructure:"log_file_mode" json:"log_file_mode"`
+	LogDirMode                 string   `mapstructure:"log_dir_mode" json:"log_dir_mode"`
+	LogFileGroup               string   `mapstructure:"log_file_group" json:"log_file_group"`
+	EnableTimeRolling          bool     `mapstructure:"enable_time_rolling" json:"enable_time_rolling"`
+	RollingTimezone            string   `mapstructure:"rolling_timezone" json:"rolling_timezone"`
+	EnableSizeRolling          bool     `mapstructure:"enable_size_rolling" json:"enable_size_rolling"`
+	MaxFileSizeKB              int64    `mapstructure:"max_file_size_kb" json:"max_file_size_kb"`
+	MaxFilesCount              int      `mapstructure:"max_files_count" json:"max_files_count"`
+	Level                      string   `mapstructure:"level" json:"level"`
+	Label                      string   `mapstructure:"label" json:"label"`
+	CallerFunc                 bool     `mapstructure:"caller_func" json:"caller_func"`
+	GELFNetwork                string   `mapstructure:"gelf_network" json:"gelf_network"`
+	GELFAddress                string   `mapstructure:"gelf_address" json:"gelf_address"`
+	GELFCompressionLevel       int      `mapstructure:"gelf_compression_level" json:"gelf_compression_level"`
+	SyslogNetwork              string   `mapstructure:"syslog_network" json:"syslog_network"`
+	SyslogAddress              string   `mapstructure:"syslog_address" json:"syslog_address"`
+	SyslogFormat               string   `mapstructure:"syslog_format" json:"syslog_format"`
+	SyslogFacility             string   `mapstructure:"syslog_facility" json:"syslog_facility"`
+	SyslogLabelAsMsgID         bool     `mapstructure:"syslog_label_as_msgid" json:"syslog_label_as_msgid"`
+	EnableJournald             bool     `mapstructure:"enable_journald" json:"enable_journald"`
+	JournaldSocket             string   `mapstructure:"journald_socket" json:"journald_socket"`
+	NetworkSinkNetwork         string   `mapstructure:"network_sink_network" json:"network_sink_network"`
+	NetworkSinkAddress         string   `mapstructure:"network_sink_address" json:"network_sink_address"`
+	NetworkSinkBufferKB        int      `mapstructure:"network_sink_buffer_kb" json:"network_sink_buffer_kb"`
+	NetworkSinkSpillPath       string   `mapstructure:"network_sink_spill_path" json:"network_sink_spill_path"`
+	NetworkSinkSpillMaxKB      int64    `mapstructure:"network_sink_spill_max_kb" json:"network_sink_spill_max_kb"`
+	HTTPSinkFormat             string   `mapstructure:"http_sink_format" json:"http_sink_format"`
+	HTTPSinkURL                string   `mapstructure:"http_sink_url" json:"http_sink_url"`
+	HTTPSinkBatchKB            int      `mapstructure:"http_sink_batch_kb" json:"http_sink_batch_kb"`
+	HTTPSinkFlushIntervalMS    int      `mapstructure:"http_sink_flush_interval_ms" json:"http_sink_flush_interval_ms"`
+	HTTPSinkLokiLabels         []string `mapstructure:"http_sink_loki_labels" json:"http_sink_loki_labels"`
+	HTTPSinkIndex              string   `mapstructure:"http_sink_index" json:"http_sink_index"`
 }
 
 func DefaultLoggerConfig() LoggerConfig {
 	return LoggerConfig{
-		Enable:             true,
-		EnableConsolePrint: true,
-		EnableLogFiles:     false,
-		FileLogFormat:      "json",
-		LogFilesPath:       ".",
-		LogFileName:        "rzerolog.log",
-		EnableTimeRolling:  false,
-		EnableSizeRolling:  false,
-		MaxFileSizeKB:      100 << 10,
-		MaxFilesCount:      0,
-		Level:              "DEBUG",
-		Label:              "",
+		Enable:                     true,
+		EnableConsolePrint:         true,
+		ConsoleColorMode:           "auto",
+		ConsoleTheme:               "dark",
+		ConsoleLabelColors:         false,
+		ConsoleLabelColorOverrides: []string{},
+		ConsoleLayout:              "compact",
+		ConsolePartWidths:          []string{},
+		ConsoleWidth:               0,
+		ConsoleOverflow:            "wrap",
+		ConsoleMultiline:           "compact",
+		ConsoleCallerLinks:         false,
+		ConsoleCallerLinkFormat:    "",
+		ConsoleLogFormat:           "text",
+		ConsoleStderrLevel:         "",
+		EnableLogFiles:             false,
+		FileLogFormat:              "json",
+		CSVColumns:                 []string{"time", "level", "label", "message"},
+		TextFieldOrder:             "alphabetical",
+		TextPriorityFields:         []string{},
+		TextNested:                 "json",
+		TextNestedDepth:            0,
+		TextCallerFormat:           "short",
+		TextLabelWidth:             0,
+		TextTemplate:               "",
+		LogFilesPath:               ".",
+		LogFileName:                "rzerolog.log",
+		LogFileMode:                "",
+		LogDirMode:                 "",
+		LogFileGroup:               "",
+		EnableTimeRolling:          false,
+		RollingTimezone:            "Local",
+		EnableSizeRolling:          false,
+		MaxFileSizeKB:              100 << 10,
+		MaxFilesCount:              0,
+		Level:                      "DEBUG",
+		Label:                      "",
+		CallerFunc:                 false,
+		GELFNetwork:                "",
+		GELFAddress:                "",
+		GELFCompressionLevel:       0,
+		SyslogNetwork:              "",
+		SyslogAddress:              "",
+		SyslogFormat:               "rfc5424",
+		SyslogFacility:             "user",
+		SyslogLabelAsMsgID:         false,
+		EnableJournald:             false,
+		JournaldSocket:             "",
+		NetworkSinkNetwork:         "",
+		NetworkSinkAddress:         "",
+		NetworkSinkBufferKB:        4 << 10,
+		NetworkSinkSpillPath:       "",
+		NetworkSinkSpillMaxKB:      0,
+		HTTPSinkFormat:             "loki",
+		HTTPSinkURL:                "",
+		HTTPSinkBatchKB:            1 << 10,
+		HTTPSinkFlushIntervalMS:    1000,
+		HTTPSinkLokiLabels:         []string{},
+		HTTPSinkIndex:              "rzerolog",
 	}
 }
 
@@ -18,23 +18,94 @@ const defaultConfigTemplate = `# This is a TOML config file.
 enable = {{ .Enable}}
 # Whether print log text on console
 enable_console_print = {{ .EnableConsolePrint}}
+# When colorize log text on console
+# ["auto","always","never"] supported
+#   auto - only if the console is a terminal,
+#          env NO_COLOR disables and env FORCE_COLOR enables colors regardless of the terminal
+console_color_mode = "{{ .ConsoleColorMode}}"
+# Colors of log text on console
+# ["dark","light","high-contrast"] supported
+console_theme = "{{ .ConsoleTheme}}"
+# Whether color each label on console by the hash of its name instead of the theme color
+console_label_colors = {{ .ConsoleLabelColors}}
+# Fixed colors of labels if 'console_label_colors' enabled, as "label=color"
+# Colors are names such as "red" or "bright-red", 256-color numbers such as "208", or "#rrggbb"
+#   eg: ["p2p=bright-green", "rpc=#ff8700"]
+console_label_color_overrides = [{{ range $i, $f := .ConsoleLabelColorOverrides}}{{ if $i}}, {{ end}}"{{ $f}}"{{ end}}]
+# Layout of log text on console
+# ["compact","aligned"] supported
+#   compact - parts separated by a single space
+#   aligned - parts padded or truncated to 'console_part_widths', fields wrapped or elided to 'console_width'
+console_layout = "{{ .ConsoleLayout}}"
+# Widths of parts in "aligned" layout as "part=width", parts not set use the defaults
+#   eg: ["level=3", "label=10", "caller=24"]
+console_part_widths = [{{ range $i, $f := .ConsolePartWidths}}{{ if $i}}, {{ end}}"{{ $f}}"{{ end}}]
+# Width of lines in "aligned" layout, 0 means the width of the terminal or env COLUMNS
+console_width = {{ .ConsoleWidth}}
+# What to do with the fields exceeding 'console_width'
+# ["wrap","elide"] supported
+console_overflow = "{{ .ConsoleOverflow}}"
+# Rendering of messages, errors and stacks of multiple lines on console
+# ["compact","expanded"] supported
+#   compact  - one line per log, newlines in fields are quoted
+#   expanded - the rest of message, multi-line "error" and "stack" printed as indented blocks
+console_multiline = "{{ .ConsoleMultiline}}"
+# Whether print the caller on console as a hyperlink (OSC 8) to 'console_caller_link_format'
+console_caller_links = {{ .ConsoleCallerLinks}}
+# Target of caller hyperlinks, "{path}" is replaced by the absolute path and "{line}" by the line
+#   eg: "vscode://file/{path}:{line}", empty means "file://{path}"
+console_caller_link_format = "{{ .ConsoleCallerLinkFormat}}"
+# Output format on console
+# ["text","logfmt"] supported, the color and layout options above only take effect in "text"
+console_log_format = "{{ .ConsoleLogFormat}}"
+# Lowest level of logs printed to stderr instead of stdout, such as "WARN"
+# Empty means all the logs printed to stdout
+console_stderr_level = "{{ .ConsoleStderrLevel}}"
 # Whether output log to files
 enable_log_files = {{ .EnableLogFiles}}
 # Output format to log files
-# ["text","json"] supported
+# ["text","json","logfmt","ecs","cbor","csv","tsv"] supported
+#   ecs  - JSON of Elastic Common Schema, for Elasticsearch and Filebeat
+#   cbor - binary CBOR, converted back to JSON or text by rzerolog-decode
+#   csv  - rows of 'csv_columns' with a header row at the start of every file
+#   tsv  - csv separated by tabs
 file_log_format = "{{ .FileLogFormat}}"
+# Field names of columns in "csv" and "tsv" log files
+csv_columns = [{{ range $i, $f := .CSVColumns}}{{ if $i}}, {{ end}}"{{ $f}}"{{ end}}]
+# Order of fields in text output, on console and in text log files
+# ["alphabetical","insertion","priority"] supported
+#   alphabetical - sorted by name, "error" first
+#   insertion    - the order fields are added
+#   priority     - 'text_priority_fields' first, then alphabetical
+text_field_order = "{{ .TextFieldOrder}}"
+# Fields printed first in "priority" field order
+text_priority_fields = [{{ range $i, $f := .TextPriorityFields}}{{ if $i}}, {{ end}}"{{ $f}}"{{ end}}]
+# Rendering of object and array fields in text output
+# ["json","flatten","tree"] supported
+#   json    - compact JSON
+#   flatten - a field per member with dotted keys, such as req.header.host=example.com
+#   tree    - indented key/value trees beneath the log line
+text_nested = "{{ .TextNested}}"
+# Depth of members expanded by 'text_nested', deeper ones printed as JSON, 0 means no limit
+text_nested_depth = {{ .TextNestedDepth}}
+# Format of the caller path in text output
+# ["short","relative","full"] supported
+#   short    - the last directory and the file, such as "rzerolog/logger.go:12"
+#   relative - relative to the Go module root in the working directory
+#   full     - the path as it is recorded
+text_caller_format = "{{ .TextCallerFormat}}"
+# Width the label in text output is padded to with spaces, 0 means no padding
+text_label_width = {{ .TextLabelWidth}}
+# Layout of lines in text output in the syntax of Go text/template, without single quotes
+# The fields are .Time, .Level, .Label, .Caller, .Message and .Fields
+# Empty means the parts and fields in the default order
+text_template = '{{ .TextTemplate}}'
 # Whether enable time rolling rules
-# If true set, the final log file name will be parsed by time parser with 'log_file_name'
-#   format:
-#       yyyy - year
-#       MM   - month
-#       dd   - day
-#       HH   - hour
-#       mm   - minute
-#       ss   - second
-#   eg: "rzerolog-yyyyMMddHH.log" -> "rzerolog-2022021510.log"
-#   also support golang format just like "rzerolog-200601021504.log" -> "rzerolog-202202151055.log"
+# If true set, the log file is switched whenever the name rendered from 'log_file_name' changes
 enable_time_rolling = {{ .EnableTimeRolling}}
+# Time zone of time rolling and the time tokens in 'log_file_name'
+# "UTC", "Local" or an IANA time zone name such as "Asia/Shanghai"
+rolling_timezone = "{{ .RollingTimezone}}"
 # Whether enable size rolling rules
 enable_size_rolling = {{ .EnableSizeRolling}}
 # Max size in Kb of each log file
@@ -46,11 +117,94 @@ max_files_count = {{ .MaxFilesCount}}
 # Path of log files
 log_files_path = "{{ .LogFilesPath}}"
 # Name of log files
+# Tokens enclosed in braces are replaced:
+#       {yyyy} {yy} - year
+#       {MM}   - month
+#       {dd}   - day
+#       {HH}   - hour
+#       {mm}   - minute
+#       {ss}   - second
+#       {sss}  - millisecond
+#       {DDD}  - day of year
+#       {ww}   - ISO week number
+#       {GGGG} - ISO week-numbering year
+#       {pid}  - process id
+#       {host} - host name
+#       {label} - logger label, not the ones of the sub loggers
+#       {utc}   - use UTC for the time tokens (default local time)
+#   write "{{"{{"}}" and "{{"}}"}}" for literal braces
+#   eg: "rzerolog-{host}-{yyyy}{MM}{dd}{HH}.log" -> "rzerolog-server1-2022021510.log"
+#   a name without braces is a legacy format parsed only if time rolling enabled,
+#   eg: "rzerolog-yyyyMMddHH.log" -> "rzerolog-2022021510.log"
+#   also support golang format just like "rzerolog-200601021504.log" -> "rzerolog-202202151055.log"
 log_file_name = "{{ .LogFileName}}"
+# Permission of log files in octal, such as "0640"
+# Empty means the default 0666 masked by umask
+log_file_mode = "{{ .LogFileMode}}"
+# Permission of the log files path in octal when it is created, such as "0750"
+# Only applied to the last directory, the parent directories created are masked by umask
+# Empty means the default 0777 masked by umask
+log_dir_mode = "{{ .LogDirMode}}"
+# Group name or id owning the log files and the path created
+# Empty means the primary group of the process
+log_file_group = "{{ .LogFileGroup}}"
 # Logger level
 level = "{{ .Level}}"
 # Logger label
 label = "{{ .Label}}"
+# Whether add the function of caller to logs
+caller_func = {{ .CallerFunc}}
+# Network of the Graylog GELF input, empty means not sending GELF messages
+# ["udp","tcp"] supported
+gelf_network = "{{ .GELFNetwork}}"
+# Address of the Graylog GELF input
+#   eg: "graylog:12201"
+gelf_address = "{{ .GELFAddress}}"
+# zlib compression level of GELF messages over UDP, 0 means no compression
+gelf_compression_level = {{ .GELFCompressionLevel}}
+# Network of the syslog daemon, empty means not sending syslog messages
+# ["unix","udp","tcp"] supported
+syslog_network = "{{ .SyslogNetwork}}"
+# Address of the syslog daemon, or the socket path of "unix"
+#   eg: "localhost:514", empty means "/dev/log" for "unix"
+syslog_address = "{{ .SyslogAddress}}"
+# Format of syslog messages
+# ["rfc5424","rfc3164"] supported
+syslog_format = "{{ .SyslogFormat}}"
+# Facility of syslog messages, such as "user", "daemon" or "local0"
+syslog_facility = "{{ .SyslogFacility}}"
+# Whether send the label as MSGID instead of APP-NAME
+syslog_label_as_msgid = {{ .SyslogLabelAsMsgID}}
+# Whether send logs to systemd-journald, only on linux
+enable_journald = {{ .EnableJournald}}
+# Socket of the native journal protocol, empty means "/run/systemd/journal/socket"
+journald_socket = "{{ .JournaldSocket}}"
+# Network of the collector receiving newline-delimited JSON, empty means not shipping logs
+# ["tcp","tls","unix"] supported
+network_sink_network = "{{ .NetworkSinkNetwork}}"
+# Address of the collector, or the socket path of "unix"
+#   eg: "collector:5170"
+network_sink_address = "{{ .NetworkSinkAddress}}"
+# Size of logs buffered in memory when the collector is unavailable, unit is KB
+network_sink_buffer_kb = {{ .NetworkSinkBufferKB}}
+# Directory of the queue file which logs exceeding the buffer are spilled to, empty means dropping them
+network_sink_spill_path = "{{ .NetworkSinkSpillPath}}"
+# Max size of the queue file, unit is KB, 0 means no limit
+network_sink_spill_max_kb = {{ .NetworkSinkSpillMaxKB}}
+# API which logs are pushed to over HTTP
+# ["loki","elasticsearch"] supported
+http_sink_format = "{{ .HTTPSinkFormat}}"
+# Endpoint of the API, empty means not pushing logs
+#   eg: "http://loki:3100/loki/api/v1/push", "http://es:9200/_bulk"
+http_sink_url = "{{ .HTTPSinkURL}}"
+# Size of logs pushed in a request, unit is KB
+http_sink_batch_kb = {{ .HTTPSinkBatchKB}}
+# Max delay of pushing logs, unit is millisecond
+http_sink_flush_interval_ms = {{ .HTTPSinkFlushIntervalMS}}
+# Fields which are stream labels of Loki besides the label
+http_sink_loki_labels = [{{ range $i, $f := .HTTPSinkLokiLabels}}{{ if $i}}, {{ end}}"{{ $f}}"{{ end}}]
+# Index of Elasticsearch
+http_sink_index = "{{ .HTTPSinkIndex}}"
 `
 
 func WriteConfigToTomlFile(configFilePath string, config *LoggerConfig) error {
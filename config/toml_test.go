@@ -2,6 +2,7 @@ package config
 
 import (
 	"github.com/stretchr/testify/require"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"testing"
@@ -49,3 +50,63 @@ func TestGetLoggerConfigFromFile(t *testing.T) {
 
 	require.Equal(t, cfg, *cfgR)
 }
+
+func TestTomlFileRoundTrip(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "logger.toml")
+	cfg := DefaultLoggerConfig()
+	cfg.ConsoleColorMode = "never"
+	cfg.ConsoleTheme = "high-contrast"
+	cfg.ConsoleLabelColors = true
+	cfg.ConsoleLabelColorOverrides = []string{"p2p=bright-green", "rpc=#ff8700"}
+	cfg.TextLabelWidth = 8
+	cfg.ConsoleLayout = "aligned"
+	cfg.ConsolePartWidths = []string{"label=6", "caller=12"}
+	cfg.ConsoleWidth = 120
+	cfg.ConsoleOverflow = "elide"
+	cfg.ConsoleMultiline = "expanded"
+	cfg.TextNested = "flatten"
+	cfg.TextNestedDepth = 3
+	cfg.TextCallerFormat = "relative"
+	cfg.TextTemplate = `{{.Time}} [{{printf "%-5s" .Level}}] {{.Message}} {{.Fields}}`
+	cfg.ConsoleCallerLinks = true
+	cfg.ConsoleCallerLinkFormat = "vscode://file/{path}:{line}"
+	cfg.CallerFunc = true
+	cfg.ConsoleStderrLevel = "WARN"
+	cfg.ConsoleLogFormat = "logfmt"
+	cfg.FileLogFormat = "ecs"
+	cfg.CSVColumns = []string{"time", "level", "message", "peer_id"}
+	cfg.GELFNetwork = "udp"
+	cfg.GELFAddress = "graylog:12201"
+	cfg.GELFCompressionLevel = 1
+	cfg.SyslogNetwork = "tcp"
+	cfg.SyslogAddress = "localhost:514"
+	cfg.SyslogFormat = "rfc3164"
+	cfg.SyslogFacility = "local0"
+	cfg.SyslogLabelAsMsgID = true
+	cfg.EnableJournald = true
+	cfg.JournaldSocket = "/tmp/journal.sock"
+	cfg.NetworkSinkNetwork = "tls"
+	cfg.NetworkSinkAddress = "collector:5170"
+	cfg.NetworkSinkBufferKB = 512
+	cfg.NetworkSinkSpillPath = "/var/spool/rzerolog"
+	cfg.NetworkSinkSpillMaxKB = 1 << 20
+	cfg.HTTPSinkFormat = "elasticsearch"
+	cfg.HTTPSinkURL = "http://es:9200/_bulk"
+	cfg.HTTPSinkBatchKB = 256
+	cfg.HTTPSinkFlushIntervalMS = 200
+	cfg.HTTPSinkLokiLabels = []string{"level", "node"}
+	cfg.HTTPSinkIndex = "logs-p2p"
+	cfg.TextFieldOrder = "priority"
+	cfg.TextPriorityFields = []string{"request_id", "user"}
+	err := WriteConfigToTomlFile(fileName, &cfg)
+	require.Nil(t, err)
+
+	cfgR, err := GetLoggerConfigFromFile(fileName, nil)
+	require.Nil(t, err)
+	require.Equal(t, cfg, *cfgR)
+
+	// the braces in the comments are written as they are
+	b, err := ioutil.ReadFile(fileName)
+	require.Nil(t, err)
+	require.Contains(t, string(b), `write "{{" and "}}" for literal braces`)
+}
@@ -0,0 +1,520 @@
+package rzerolog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// jsonKind is the kind of a JSON value.
+type jsonKind uint8
+
+const (
+	jsonString jsonKind = iota
+	jsonNumber
+	jsonBool
+	jsonNull
+	jsonObject
+	jsonArray
+)
+
+// eventField is a top level field of a JSON log record.
+type eventField struct {
+	key []byte
+	// value is the unescaped content for strings, and the raw JSON for others.
+	value []byte
+	kind  jsonKind
+}
+
+// consoleEvent is a JSON log record tokenized in place.
+//
+// The keys and values refer to the input or the scratch buffer,
+// so the event is only valid until the input is modified or the event is released.
+type consoleEvent struct {
+	fields []eventField
+	// order is the indexes of fields to be printed.
+	order []int
+	// scratch holds the unescaped strings.
+	scratch []byte
+	// buf is the output buffer.
+	buf []byte
+	// indent is the column of message, where the continuation lines of fields start.
+	indent int
+	// tail is the blocks printed beneath the header line.
+	tail []byte
+	// theme is the theme of the output written to.
+	theme *Theme
+}
+
+var consoleEventPool = sync.Pool{
+	New: func() interface{} {
+		return &consoleEvent{
+			fields:  make([]eventField, 0, 16),
+			order:   make([]int, 0, 16),
+			scratch: make([]byte, 0, 256),
+			buf:     make([]byte, 0, 256),
+			tail:    make([]byte, 0, 64),
+		}
+	},
+}
+
+func getConsoleEvent() *consoleEvent {
+	return consoleEventPool.Get().(*consoleEvent)
+}
+
+func (e *consoleEvent) release() {
+	// do not keep huge buffers in the pool
+	if cap(e.buf) > 64<<10 || cap(e.scratch) > 64<<10 || cap(e.tail) > 64<<10 {
+		return
+	}
+	e.reset()
+	consoleEventPool.Put(e)
+}
+
+// reset clears e to parse another record.
+func (e *consoleEvent) reset() {
+	for i := range e.fields {
+		e.fields[i] = eventField{}
+	}
+	e.fields = e.fields[:0]
+	e.order = e.order[:0]
+	e.scratch = e.scratch[:0]
+	e.buf = e.buf[:0]
+	e.indent = 0
+	e.tail = e.tail[:0]
+	e.theme = nil
+}
+
+// field returns the field with the given key, the last one wins if the key is duplicated.
+func (e *consoleEvent) field(key string) (*eventField, bool) {
+	for i := len(e.fields) - 1; i >= 0; i-- {
+		if string(e.fields[i].key) == key {
+			return &e.fields[i], true
+		}
+	}
+	return nil, false
+}
+
+// sortOrder sorts the order by the field keys, keeps only the last one of duplicated keys.
+func (e *consoleEvent) sortOrder() {
+	sort.Stable(byFieldKey{e})
+	j := 0
+	for i, idx := range e.order {
+		if i+1 < len(e.order) && bytes.Equal(e.fields[idx].key, e.fields[e.order[i+1]].key) {
+			continue
+		}
+		e.order[j] = idx
+		j++
+	}
+	e.order = e.order[:j]
+}
+
+// dedupOrder keeps only the last one of duplicated keys in order, without sorting.
+func (e *consoleEvent) dedupOrder() {
+	j := 0
+	for i, idx := range e.order {
+		dup := false
+		for _, later := range e.order[i+1:] {
+			if bytes.Equal(e.fields[idx].key, e.fields[later].key) {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			e.order[j] = idx
+			j++
+		}
+	}
+	e.order = e.order[:j]
+}
+
+// moveToFront moves the field with the given key to the front of order.
+func (e *consoleEvent) moveToFront(key string) {
+	for i, idx := range e.order {
+		if string(e.fields[idx].key) == key {
+			copy(e.order[1:i+1], e.order[:i])
+			e.order[0] = idx
+			return
+		}
+	}
+}
+
+type byFieldKey struct {
+	e *consoleEvent
+}
+
+func (s byFieldKey) Len() int {
+	return len(s.e.order)
+}
+
+func (s byFieldKey) Less(i, j int) bool {
+	return bytes.Compare(s.e.fields[s.e.order[i]].key, s.e.fields[s.e.order[j]].key) < 0
+}
+
+func (s byFieldKey) Swap(i, j int) {
+	s.e.order[i], s.e.order[j] = s.e.order[j], s.e.order[i]
+}
+
+// interfaceValue decodes the value of f as encoding/json does with UseNumber.
+func (f *eventField) interfaceValue() interface{} {
+	switch f.kind {
+	case jsonString:
+		return string(f.value)
+	case jsonNumber:
+		return json.Number(f.value)
+	case jsonBool:
+		return f.value[0] == 't'
+	case jsonNull:
+		return nil
+	}
+	var v interface{}
+	d := json.NewDecoder(bytes.NewReader(f.value))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		return string(f.value)
+	}
+	return v
+}
+
+var errUnexpectedEnd = errors.New("unexpected end of JSON input")
+
+// parse tokenizes the JSON object p into the event fields.
+func (e *consoleEvent) parse(p []byte) error {
+	i := skipSpace(p, 0)
+	if i >= len(p) {
+		return errUnexpectedEnd
+	}
+	if p[i] != '{' {
+		return fmt.Errorf("invalid character %q looking for beginning of object", p[i])
+	}
+	i = skipSpace(p, i+1)
+	if i < len(p) && p[i] == '}' {
+		return nil
+	}
+	for {
+		if i >= len(p) {
+			return errUnexpectedEnd
+		}
+		if p[i] != '"' {
+			return fmt.Errorf("invalid character %q looking for beginning of object key string", p[i])
+		}
+		var f eventField
+		var err error
+		if f.key, i, err = e.parseString(p, i); err != nil {
+			return err
+		}
+		i = skipSpace(p, i)
+		if i >= len(p) {
+			return errUnexpectedEnd
+		}
+		if p[i] != ':' {
+			return fmt.Errorf("invalid character %q after object key", p[i])
+		}
+		i = skipSpace(p, i+1)
+		if i >= len(p) {
+			return errUnexpectedEnd
+		}
+		if f.kind, f.value, i, err = e.parseValue(p, i); err != nil {
+			return err
+		}
+		e.fields = append(e.fields, f)
+
+		i = skipSpace(p, i)
+		if i >= len(p) {
+			return errUnexpectedEnd
+		}
+		switch p[i] {
+		case ',':
+			i = skipSpace(p, i+1)
+		case '}':
+			return nil
+		default:
+			return fmt.Errorf("invalid character %q after object key:value pair", p[i])
+		}
+	}
+}
+
+// parseValue parses the JSON value at p[i].
+func (e *consoleEvent) parseValue(p []byte, i int) (kind jsonKind, value []byte, next int, err error) {
+	switch c := p[i]; {
+	case c == '"':
+		value, next, err = e.parseString(p, i)
+		return jsonString, value, next, err
+	case c == '{' || c == '[':
+		next, err = skipComposite(p, i)
+		if c == '{' {
+			kind = jsonObject
+		} else {
+			kind = jsonArray
+		}
+		return kind, p[i:next], next, err
+	case c == '-' || (c >= '0' && c <= '9'):
+		next, err = scanNumber(p, i)
+		return jsonNumber, p[i:next], next, err
+	case c == 't':
+		next, err = scanLiteral(p, i, "true")
+		return jsonBool, p[i:next], next, err
+	case c == 'f':
+		next, err = scanLiteral(p, i, "false")
+		return jsonBool, p[i:next], next, err
+	case c == 'n':
+		next, err = scanLiteral(p, i, "null")
+		return jsonNull, p[i:next], next, err
+	}
+	return 0, nil, i, fmt.Errorf("invalid character %q looking for beginning of value", p[i])
+}
+
+// parseString parses the JSON string at p[i] and returns the unescaped content.
+// The content refers to p if no unescaping is needed.
+func (e *consoleEvent) parseString(p []byte, i int) ([]byte, int, error) {
+	start := i + 1
+	for j := start; j < len(p); j++ {
+		switch c := p[j]; {
+		case c == '"':
+			return p[start:j], j + 1, nil
+		case c == '\\' || c >= utf8.RuneSelf:
+			return e.unescapeString(p, start)
+		case c < 0x20:
+			return nil, j, fmt.Errorf("invalid character %q in string literal", c)
+		}
+	}
+	return nil, len(p), errUnexpectedEnd
+}
+
+// unescapeString unescapes the JSON string starting at p[start] into the scratch buffer,
+// invalid UTF-8 and surrogates are replaced by utf8.RuneError as encoding/json does.
+func (e *consoleEvent) unescapeString(p []byte, start int) ([]byte, int, error) {
+	from := len(e.scratch)
+	for j := start; j < len(p); {
+		c := p[j]
+		switch {
+		case c == '"':
+			return e.scratch[from:], j + 1, nil
+		case c < 0x20:
+			return nil, j, fmt.Errorf("invalid character %q in string literal", c)
+		case c == '\\':
+			if j+1 >= len(p) {
+				return nil, len(p), errUnexpectedEnd
+			}
+			switch p[j+1] {
+			case '"', '\\', '/':
+				e.scratch = append(e.scratch, p[j+1])
+			case 'b':
+				e.scratch = append(e.scratch, '\b')
+			case 'f':
+				e.scratch = append(e.scratch, '\f')
+			case 'n':
+				e.scratch = append(e.scratch, '\n')
+			case 'r':
+				e.scratch = append(e.scratch, '\r')
+			case 't':
+				e.scratch = append(e.scratch, '\t')
+			case 'u':
+				r, ok := hex4(p, j+2)
+				if !ok {
+					return nil, j, errors.New("invalid escape sequence in string literal")
+				}
+				j += 6
+				if utf16.IsSurrogate(r) {
+					if r2, ok := hex4(p, j+2); ok && j+1 < len(p) && p[j] == '\\' && p[j+1] == 'u' {
+						if dec := utf16.DecodeRune(r, r2); dec != utf8.RuneError {
+							j += 6
+							e.scratch = appendRune(e.scratch, dec)
+							continue
+						}
+					}
+					r = utf8.RuneError
+				}
+				e.scratch = appendRune(e.scratch, r)
+				continue
+			default:
+				return nil, j, fmt.Errorf("invalid character %q in string escape code", p[j+1])
+			}
+			j += 2
+		case c < utf8.RuneSelf:
+			e.scratch = append(e.scratch, c)
+			j++
+		default:
+			r, size := utf8.DecodeRune(p[j:])
+			e.scratch = appendRune(e.scratch, r)
+			j += size
+		}
+	}
+	return nil, len(p), errUnexpectedEnd
+}
+
+func hex4(p []byte, i int) (rune, bool) {
+	if i+4 > len(p) {
+		return 0, false
+	}
+	var r rune
+	for _, c := range p[i : i+4] {
+		switch {
+		case c >= '0' && c <= '9':
+			c -= '0'
+		case c >= 'a' && c <= 'f':
+			c = c - 'a' + 10
+		case c >= 'A' && c <= 'F':
+			c = c - 'A' + 10
+		default:
+			return 0, false
+		}
+		r = r*16 + rune(c)
+	}
+	return r, true
+}
+
+func appendRune(b []byte, r rune) []byte {
+	var tmp [utf8.UTFMax]byte
+	n := utf8.EncodeRune(tmp[:], r)
+	return append(b, tmp[:n]...)
+}
+
+func skipSpace(p []byte, i int) int {
+	for i < len(p) {
+		switch p[i] {
+		case ' ', '\t', '\n', '\r':
+			i++
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+func scanLiteral(p []byte, i int, lit string) (int, error) {
+	if len(p)-i < len(lit) || string(p[i:i+len(lit)]) != lit {
+		return i, fmt.Errorf("invalid literal, %s expected", lit)
+	}
+	return i + len(lit), nil
+}
+
+func scanNumber(p []byte, i int) (int, error) {
+	start := i
+	if p[i] == '-' {
+		i++
+	}
+	digits := func() int {
+		n := 0
+		for i < len(p) && p[i] >= '0' && p[i] <= '9' {
+			i++
+			n++
+		}
+		return n
+	}
+	if i < len(p) && p[i] == '0' {
+		i++
+	} else if digits() == 0 {
+		return i, fmt.Errorf("invalid number %q", p[start:i])
+	}
+	if i < len(p) && p[i] == '.' {
+		i++
+		if digits() == 0 {
+			return i, fmt.Errorf("invalid number %q", p[start:i])
+		}
+	}
+	if i < len(p) && (p[i] == 'e' || p[i] == 'E') {
+		i++
+		if i < len(p) && (p[i] == '+' || p[i] == '-') {
+			i++
+		}
+		if digits() == 0 {
+			return i, fmt.Errorf("invalid number %q", p[start:i])
+		}
+	}
+	return i, nil
+}
+
+// skipComposite skips the object or array at p[i] and returns the index after it.
+func skipComposite(p []byte, i int) (int, error) {
+	depth := 0
+	for i < len(p) {
+		switch p[i] {
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+			if depth == 0 {
+				return i + 1, nil
+			}
+		case '"':
+			i++
+			for i < len(p) && p[i] != '"' {
+				if p[i] == '\\' {
+					i++
+				}
+				i++
+			}
+		}
+		i++
+	}
+	return i, errUnexpectedEnd
+}
+
+// appendQuoted appends the Go quoted s to dst, as strconv.Quote does.
+func appendQuoted(dst, s []byte) []byte {
+	dst = append(dst, '"')
+	for len(s) > 0 {
+		r, width := rune(s[0]), 1
+		if r >= utf8.RuneSelf {
+			r, width = utf8.DecodeRune(s)
+		}
+		if width == 1 && r == utf8.RuneError {
+			dst = append(dst, `\x`...)
+			dst = append(dst, lowerhex[s[0]>>4], lowerhex[s[0]&0xF])
+			s = s[width:]
+			continue
+		}
+		dst = appendEscapedRune(dst, r)
+		s = s[width:]
+	}
+	return append(dst, '"')
+}
+
+const lowerhex = "0123456789abcdef"
+
+func appendEscapedRune(dst []byte, r rune) []byte {
+	if r == '"' || r == '\\' {
+		return append(dst, '\\', byte(r))
+	}
+	if strconv.IsPrint(r) {
+		return appendRune(dst, r)
+	}
+	switch r {
+	case '\a':
+		return append(dst, `\a`...)
+	case '\b':
+		return append(dst, `\b`...)
+	case '\f':
+		return append(dst, `\f`...)
+	case '\n':
+		return append(dst, `\n`...)
+	case '\r':
+		return append(dst, `\r`...)
+	case '\t':
+		return append(dst, `\t`...)
+	case '\v':
+		return append(dst, `\v`...)
+	}
+	switch {
+	case r < ' ' || r == 0x7f:
+		return append(dst, '\\', 'x', lowerhex[byte(r)>>4], lowerhex[byte(r)&0xF])
+	case r < 0x10000:
+		dst = append(dst, `\u`...)
+		for s := 12; s >= 0; s -= 4 {
+			dst = append(dst, lowerhex[r>>uint(s)&0xF])
+		}
+	default:
+		dst = append(dst, `\U`...)
+		for s := 28; s >= 0; s -= 4 {
+			dst = append(dst, lowerhex[r>>uint(s)&0xF])
+		}
+	}
+	return dst
+}
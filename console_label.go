@@ -0,0 +1,118 @@
+package rzerolog
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// defaultLabelPalette is the palette of label colors for themes without LabelPalette.
+var defaultLabelPalette = []Color{
+	ColorRed, ColorGreen, ColorYellow, ColorBlue, ColorMagenta, ColorCyan,
+	ColorBrightRed, ColorBrightGreen, ColorBrightYellow, ColorBrightBlue, ColorBrightMagenta, ColorBrightCyan,
+}
+
+// WithLabelColors colors each label with a stable color picked from the LabelPalette of the theme
+// by the hash of its name, overrides is the fixed styles of some labels.
+func WithLabelColors(overrides map[string]Style) ConsoleWriterOption {
+	return func(w *ConsoleWriter) {
+		w.LabelColors = true
+		w.LabelStyles = overrides
+	}
+}
+
+// WithLabelWidth pads the label part with spaces to width runes.
+func WithLabelWidth(width int) ConsoleWriterOption {
+	return func(w *ConsoleWriter) {
+		w.LabelWidth = width
+	}
+}
+
+// labelStyle returns the style of label in theme t.
+func (w *ConsoleWriter) labelStyle(t *Theme, label []byte) Style {
+	if !w.LabelColors || t == &noColorTheme {
+		return t.Label
+	}
+	if s, ok := w.LabelStyles[string(label)]; ok {
+		return s
+	}
+	palette := t.LabelPalette
+	if len(palette) == 0 {
+		palette = defaultLabelPalette
+	}
+	return Style{Fg: palette[labelHash(label)%uint32(len(palette))]}
+}
+
+// labelHash returns the 32-bit FNV-1a hash of label.
+func labelHash(label []byte) uint32 {
+	h := uint32(2166136261)
+	for _, c := range label {
+		h ^= uint32(c)
+		h *= 16777619
+	}
+	return h
+}
+
+// visibleWidth returns the count of runes in b which are printed, skipping the ANSI escape sequences.
+func visibleWidth(b []byte) int {
+	n := 0
+	for i := 0; i < len(b); {
+		if j := escapeEnd(b, i); j > i {
+			i = j
+			continue
+		}
+		_, size := utf8.DecodeRune(b[i:])
+		i += size
+		n++
+	}
+	return n
+}
+
+// escapeEnd returns the end of the ANSI CSI or OSC escape sequence starting at b[i], or i if there is none.
+func escapeEnd(b []byte, i int) int {
+	if b[i] != '\x1b' || i+1 >= len(b) {
+		return i
+	}
+	j := i + 2
+	switch b[i+1] {
+	case '[':
+		// CSI sequence ends with a byte in 0x40-0x7e
+		for j < len(b) && (b[j] < 0x40 || b[j] > 0x7e) {
+			j++
+		}
+		if j < len(b) {
+			j++
+		}
+	case ']':
+		// OSC sequence ends with BEL or ST
+		for j < len(b) && b[j] != '\a' && !(b[j] == '\x1b' && j+1 < len(b) && b[j+1] == '\\') {
+			j++
+		}
+		if j < len(b) && b[j] == '\a' {
+			j++
+		} else if j < len(b) {
+			j += 2
+		}
+	default:
+		return i
+	}
+	return j
+}
+
+// ParseLabelStyles parses the label colors given as "label=color" for WithLabelColors,
+// see ParseColor for the format of colors.
+func ParseLabelStyles(pairs []string) (map[string]Style, error) {
+	styles := make(map[string]Style, len(pairs))
+	for _, pair := range pairs {
+		i := strings.LastIndexByte(pair, '=')
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid label color %q: \"label=color\" is expected", pair)
+		}
+		c, err := ParseColor(pair[i+1:])
+		if err != nil {
+			return nil, err
+		}
+		styles[pair[:i]] = Style{Fg: c}
+	}
+	return styles, nil
+}
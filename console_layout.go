@@ -0,0 +1,233 @@
+package rzerolog
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rs/zerolog"
+)
+
+// ConsoleLayout defines how the parts and fields are laid out in a line of ConsoleWriter.
+type ConsoleLayout int
+
+const (
+	// LayoutCompact separates the parts with a single space.
+	LayoutCompact ConsoleLayout = iota
+	// LayoutAligned pads or truncates the parts to the widths in PartWidths,
+	// and wraps or elides the fields exceeding the width of the terminal.
+	LayoutAligned
+)
+
+const (
+	LayoutCompactName = "compact"
+	LayoutAlignedName = "aligned"
+)
+
+// String returns the name of the layout.
+func (l ConsoleLayout) String() string {
+	switch l {
+	case LayoutCompact:
+		return LayoutCompactName
+	case LayoutAligned:
+		return LayoutAlignedName
+	}
+	return fmt.Sprintf("ConsoleLayout(%d)", int(l))
+}
+
+// ParseConsoleLayout returns the layout with the given name.
+// An empty name means LayoutCompact.
+func ParseConsoleLayout(name string) (ConsoleLayout, error) {
+	switch name {
+	case "", LayoutCompactName:
+		return LayoutCompact, nil
+	case LayoutAlignedName:
+		return LayoutAligned, nil
+	}
+	return 0, fmt.Errorf("unsupported console layout %q. supporting: %s %s", name,
+		LayoutCompactName, LayoutAlignedName)
+}
+
+// Overflow defines what LayoutAligned does with the fields exceeding the line width.
+type Overflow int
+
+const (
+	// OverflowWrap moves the fields to continuation lines indented to the message.
+	OverflowWrap Overflow = iota
+	// OverflowElide drops the fields and ends the line with "…".
+	OverflowElide
+)
+
+const (
+	OverflowWrapName  = "wrap"
+	OverflowElideName = "elide"
+)
+
+// String returns the name of the overflow.
+func (o Overflow) String() string {
+	switch o {
+	case OverflowWrap:
+		return OverflowWrapName
+	case OverflowElide:
+		return OverflowElideName
+	}
+	return fmt.Sprintf("Overflow(%d)", int(o))
+}
+
+// ParseOverflow returns the overflow with the given name.
+// An empty name means OverflowWrap.
+func ParseOverflow(name string) (Overflow, error) {
+	switch name {
+	case "", OverflowWrapName:
+		return OverflowWrap, nil
+	case OverflowElideName:
+		return OverflowElide, nil
+	}
+	return 0, fmt.Errorf("unsupported overflow %q. supporting: %s %s", name,
+		OverflowWrapName, OverflowElideName)
+}
+
+// WithAlignedLayout lays out the parts in columns, widths overrides the widths of parts.
+func WithAlignedLayout(widths map[string]int) ConsoleWriterOption {
+	return func(w *ConsoleWriter) {
+		w.Layout = LayoutAligned
+		w.PartWidths = widths
+	}
+}
+
+// WithLineWidth set the width of lines in LayoutAligned and what to do with the fields exceeding it.
+// A width of 0 means the width of the terminal.
+func WithLineWidth(width int, overflow Overflow) ConsoleWriterOption {
+	return func(w *ConsoleWriter) {
+		w.Width = width
+		w.Overflow = overflow
+	}
+}
+
+// ParsePartWidths parses the widths of parts given as "part=width" for WithAlignedLayout.
+func ParsePartWidths(pairs []string) (map[string]int, error) {
+	widths := make(map[string]int, len(pairs))
+	for _, pair := range pairs {
+		i := strings.LastIndexByte(pair, '=')
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid part width %q: \"part=width\" is expected", pair)
+		}
+		n, err := strconv.Atoi(pair[i+1:])
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid part width %q: a non-negative width is expected", pair)
+		}
+		widths[pair[:i]] = n
+	}
+	return widths, nil
+}
+
+// partWidth returns the width part p is padded to, and whether it is truncated to the width.
+func (w *ConsoleWriter) partWidth(p string) (int, bool) {
+	if w.Layout == LayoutAligned {
+		if n, ok := w.PartWidths[p]; ok {
+			return n, true
+		}
+		switch p {
+		case zerolog.LevelFieldName:
+			return 3, true
+		case LabelFieldName:
+			return 10, true
+		case zerolog.CallerFieldName:
+			return 24, true
+		}
+		return 0, false
+	}
+	if p == LabelFieldName {
+		return w.LabelWidth, false
+	}
+	return 0, false
+}
+
+// lineWidth returns the width of lines in LayoutAligned, 0 if it is unlimited.
+//
+// NOTE: Width 0 means the width of the terminal Out, or the COLUMNS environment variable if Out is not a terminal.
+func (w *ConsoleWriter) lineWidth() int {
+	if w.Layout != LayoutAligned {
+		return 0
+	}
+	if w.Width > 0 {
+		return w.Width
+	}
+	if f, ok := w.Out.(interface{ Fd() uintptr }); ok {
+		if n := terminalWidth(f.Fd()); n > 0 {
+			return n
+		}
+	}
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		return n
+	}
+	return 0
+}
+
+// fitWidth pads b[start:] with spaces to width runes, and truncates it to width runes if truncate is set.
+// It is truncated from the left if left is set.
+func fitWidth(b []byte, start, width int, truncate, left bool) []byte {
+	n := visibleWidth(b[start:])
+	if n > width && truncate {
+		return truncateVisible(b, start, width, left)
+	}
+	for ; n < width; n++ {
+		b = append(b, ' ')
+	}
+	return b
+}
+
+const ellipsis = "…"
+
+// truncateVisible truncates b[start:] to width runes ending or beginning with an ellipsis,
+// all the escape sequences are kept so that the styles are still closed.
+func truncateVisible(b []byte, start, width int, left bool) []byte {
+	if width <= 0 {
+		return b[:start]
+	}
+	var tmp [128]byte
+	src := append(tmp[:0], b[start:]...)
+	// visible runes in [from, to) are kept
+	from, to := 0, width-1
+	if left {
+		total := visibleWidth(src)
+		from, to = total-width+1, total
+	}
+	b = b[:start]
+	if left {
+		b = append(b, ellipsis...)
+	}
+	idx := 0
+	for i := 0; i < len(src); {
+		if j := escapeEnd(src, i); j > i {
+			b = append(b, src[i:j]...)
+			i = j
+			continue
+		}
+		_, size := utf8.DecodeRune(src[i:])
+		if idx >= from && idx < to {
+			b = append(b, src[i:i+size]...)
+		} else if !left && idx == to {
+			b = append(b, ellipsis...)
+		}
+		idx++
+		i += size
+	}
+	return b
+}
+
+// breakLine replaces the separator at b[at] with a line break and indent spaces.
+func breakLine(b []byte, at, indent int) []byte {
+	n := len(b)
+	for i := 0; i < indent; i++ {
+		b = append(b, ' ')
+	}
+	copy(b[at+1+indent:], b[at+1:n])
+	b[at] = '\n'
+	for i := at + 1; i < at+1+indent; i++ {
+		b[i] = ' '
+	}
+	return b
+}
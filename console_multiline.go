@@ -0,0 +1,169 @@
+package rzerolog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/rs/zerolog"
+)
+
+// MultilineMode defines how ConsoleWriter renders the messages, errors and stacks of multiple lines.
+type MultilineMode int
+
+const (
+	// MultilineCompact prints every event in one line, newlines in fields are quoted.
+	MultilineCompact MultilineMode = iota
+	// MultilineExpanded prints the first line of the message in the header line,
+	// then the rest of the message, the "error" of multiple lines and the "stack" as indented blocks beneath it.
+	MultilineExpanded
+)
+
+const (
+	MultilineCompactName  = "compact"
+	MultilineExpandedName = "expanded"
+)
+
+// blockIndent is the indent of error and stack blocks in MultilineExpanded.
+const blockIndent = 4
+
+// String returns the name of the multiline mode.
+func (m MultilineMode) String() string {
+	switch m {
+	case MultilineCompact:
+		return MultilineCompactName
+	case MultilineExpanded:
+		return MultilineExpandedName
+	}
+	return fmt.Sprintf("MultilineMode(%d)", int(m))
+}
+
+// ParseMultilineMode returns the multiline mode with the given name.
+// An empty name means MultilineCompact.
+func ParseMultilineMode(name string) (MultilineMode, error) {
+	switch name {
+	case "", MultilineCompactName:
+		return MultilineCompact, nil
+	case MultilineExpandedName:
+		return MultilineExpanded, nil
+	}
+	return 0, fmt.Errorf("unsupported multiline mode %q. supporting: %s %s", name,
+		MultilineCompactName, MultilineExpandedName)
+}
+
+// WithMultiline set how the messages, errors and stacks of multiple lines are rendered.
+func WithMultiline(mode MultilineMode) ConsoleWriterOption {
+	return func(w *ConsoleWriter) {
+		w.Multiline = mode
+	}
+}
+
+// isBlockField reports whether f is printed as a block beneath the header line instead of a field.
+func (w *ConsoleWriter) isBlockField(f *eventField) bool {
+	if w.Multiline != MultilineExpanded {
+		return false
+	}
+	switch string(f.key) {
+	case zerolog.ErrorFieldName:
+		return f.kind == jsonString && bytes.IndexByte(f.value, '\n') >= 0
+	case zerolog.ErrorStackFieldName:
+		return f.kind == jsonString || f.kind == jsonArray
+	}
+	return false
+}
+
+// splitMessage moves the lines after the first one of the message in evt.buf[start:] to evt.tail.
+func (w *ConsoleWriter) splitMessage(evt *consoleEvent, start int) {
+	i := bytes.IndexByte(evt.buf[start:], '\n')
+	if i < 0 {
+		return
+	}
+	evt.tail = appendBlockLines(evt.tail, evt.buf[start+i+1:], evt.indent, Style{})
+	evt.buf = bytes.TrimRight(evt.buf[:start+i], "\r")
+}
+
+// writeBlocks appends the error and stack blocks to evt.tail.
+func (w *ConsoleWriter) writeBlocks(evt *consoleEvent) {
+	if w.Multiline != MultilineExpanded {
+		return
+	}
+	t := evt.theme
+	if f, ok := evt.field(zerolog.ErrorFieldName); ok && w.isBlockField(f) {
+		// the first line follows the header, the others are aligned with it
+		first, rest := f.value, []byte(nil)
+		if i := bytes.IndexByte(f.value, '\n'); i >= 0 {
+			first, rest = f.value[:i], f.value[i+1:]
+		}
+		evt.tail = appendBlockHeader(evt.tail, zerolog.ErrorFieldName, t)
+		evt.tail = append(evt.tail, ' ')
+		evt.tail = t.Error.appendStart(evt.tail)
+		evt.tail = append(evt.tail, bytes.TrimRight(first, "\r")...)
+		evt.tail = t.Error.appendEnd(evt.tail)
+		evt.tail = appendBlockLines(evt.tail, rest, blockIndent+len(zerolog.ErrorFieldName)+2, t.Error)
+	}
+	if f, ok := evt.field(zerolog.ErrorStackFieldName); ok && w.isBlockField(f) {
+		evt.tail = appendBlockHeader(evt.tail, zerolog.ErrorStackFieldName, t)
+		if f.kind == jsonString {
+			evt.tail = appendBlockLines(evt.tail, f.value, blockIndent+2, Style{})
+			return
+		}
+		frames, _ := f.interfaceValue().([]interface{})
+		for _, frame := range frames {
+			evt.tail = appendBlockLines(evt.tail, []byte(formatStackFrame(frame)), blockIndent+2, Style{})
+		}
+	}
+}
+
+// appendBlockHeader appends a new line with the name of a block.
+func appendBlockHeader(dst []byte, name string, t *Theme) []byte {
+	dst = appendIndent(append(dst, '\n'), blockIndent)
+	dst = t.FieldName.appendStart(dst)
+	dst = append(dst, name...)
+	dst = append(dst, ':')
+	return t.FieldName.appendEnd(dst)
+}
+
+// appendBlockLines appends every line of text as a new line with indent and style.
+func appendBlockLines(dst, text []byte, indent int, style Style) []byte {
+	for len(text) > 0 {
+		line := text
+		if i := bytes.IndexByte(text, '\n'); i >= 0 {
+			line, text = text[:i], text[i+1:]
+		} else {
+			text = nil
+		}
+		dst = appendIndent(append(dst, '\n'), indent)
+		dst = style.appendStart(dst)
+		dst = append(dst, bytes.TrimRight(line, "\r")...)
+		dst = style.appendEnd(dst)
+	}
+	return dst
+}
+
+func appendIndent(dst []byte, n int) []byte {
+	for i := 0; i < n; i++ {
+		dst = append(dst, ' ')
+	}
+	return dst
+}
+
+// formatStackFrame formats a frame of stack, such as the ones marshaled by github.com/rs/zerolog/pkgerrors.
+func formatStackFrame(frame interface{}) string {
+	switch fr := frame.(type) {
+	case string:
+		return fr
+	case map[string]interface{}:
+		fn, source, line := fr["func"], fr["source"], fr["line"]
+		if fn != nil && source != nil {
+			if line != nil {
+				return fmt.Sprintf("at %v (%v:%v)", fn, source, line)
+			}
+			return fmt.Sprintf("at %v (%v)", fn, source)
+		}
+	}
+	b, err := json.Marshal(frame)
+	if err != nil {
+		return fmt.Sprint(frame)
+	}
+	return string(b)
+}
@@ -0,0 +1,173 @@
+package rzerolog
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// NestedMode defines how ConsoleWriter renders the fields of objects and arrays.
+type NestedMode int
+
+const (
+	// NestedJSON prints objects and arrays as compact JSON.
+	NestedJSON NestedMode = iota
+	// NestedFlatten prints every member of objects and arrays as a field with a dotted key,
+	// such as "req.header.host=example.com" and "ids.0=1".
+	NestedFlatten
+	// NestedTree prints objects and arrays as indented key/value trees beneath the header line.
+	NestedTree
+)
+
+const (
+	NestedJSONName    = "json"
+	NestedFlattenName = "flatten"
+	NestedTreeName    = "tree"
+)
+
+// String returns the name of the nested mode.
+func (m NestedMode) String() string {
+	switch m {
+	case NestedJSON:
+		return NestedJSONName
+	case NestedFlatten:
+		return NestedFlattenName
+	case NestedTree:
+		return NestedTreeName
+	}
+	return fmt.Sprintf("NestedMode(%d)", int(m))
+}
+
+// ParseNestedMode returns the nested mode with the given name.
+// An empty name means NestedJSON.
+func ParseNestedMode(name string) (NestedMode, error) {
+	switch name {
+	case "", NestedJSONName:
+		return NestedJSON, nil
+	case NestedFlattenName:
+		return NestedFlatten, nil
+	case NestedTreeName:
+		return NestedTree, nil
+	}
+	return 0, fmt.Errorf("unsupported nested mode %q. supporting: %s %s %s", name,
+		NestedJSONName, NestedFlattenName, NestedTreeName)
+}
+
+// WithNested set how the fields of objects and arrays are rendered,
+// the members deeper than depth are printed as compact JSON, no limit if depth is 0.
+func WithNested(mode NestedMode, depth int) ConsoleWriterOption {
+	return func(w *ConsoleWriter) {
+		w.Nested = mode
+		w.NestedDepth = depth
+	}
+}
+
+// isNestedField reports whether f is rendered by the nested renderer.
+func (w *ConsoleWriter) isNestedField(f *eventField) bool {
+	return w.Nested != NestedJSON && (f.kind == jsonObject || f.kind == jsonArray) && !w.isEmptyComposite(f.value)
+}
+
+// isEmptyComposite reports whether the object or array raw has no members.
+func (w *ConsoleWriter) isEmptyComposite(raw []byte) bool {
+	i := skipSpace(raw, 1)
+	return i < len(raw) && (raw[i] == '}' || raw[i] == ']')
+}
+
+// expandable reports whether a member at depth is expanded rather than printed as JSON.
+func (w *ConsoleWriter) expandable(kind jsonKind, raw []byte, depth int) bool {
+	return (kind == jsonObject || kind == jsonArray) && !w.isEmptyComposite(raw) &&
+		(w.NestedDepth <= 0 || depth < w.NestedDepth)
+}
+
+// appendFlattened appends the members of the object or array raw as fields with keys prefixed by path.
+func (w *ConsoleWriter) appendFlattened(evt *consoleEvent, dst, path, raw []byte, depth int, t *Theme) []byte {
+	first := true
+	_ = evt.forEachMember(raw, func(key []byte, kind jsonKind, value []byte) {
+		if !first {
+			dst = append(dst, ' ')
+		}
+		first = false
+		sub := append(append(path[:len(path):len(path)], '.'), key...)
+		if w.expandable(kind, value, depth+1) {
+			dst = w.appendFlattened(evt, dst, sub, value, depth+1, t)
+			return
+		}
+		dst = t.FieldName.appendStart(dst)
+		dst = append(dst, sub...)
+		dst = append(dst, '=')
+		dst = t.FieldName.appendEnd(dst)
+		dst = w.appendNestedValue(dst, kind, value, t)
+	})
+	return dst
+}
+
+// appendTree appends the members of the object or array raw as lines indented by indent.
+func (w *ConsoleWriter) appendTree(evt *consoleEvent, dst, raw []byte, indent, depth int, t *Theme) []byte {
+	_ = evt.forEachMember(raw, func(key []byte, kind jsonKind, value []byte) {
+		dst = appendIndent(append(dst, '\n'), indent)
+		dst = t.FieldName.appendStart(dst)
+		dst = append(dst, key...)
+		dst = append(dst, ':')
+		dst = t.FieldName.appendEnd(dst)
+		if w.expandable(kind, value, depth+1) {
+			dst = w.appendTree(evt, dst, value, indent+2, depth+1, t)
+			return
+		}
+		dst = append(dst, ' ')
+		dst = w.appendNestedValue(dst, kind, value, t)
+	})
+	return dst
+}
+
+// appendNestedValue appends a member value as the value of fields.
+func (w *ConsoleWriter) appendNestedValue(dst []byte, kind jsonKind, value []byte, t *Theme) []byte {
+	dst = t.FieldValue.appendStart(dst)
+	if kind == jsonString {
+		if needsQuoteBytes(value) {
+			dst = appendQuoted(dst, value)
+		} else {
+			dst = append(dst, value...)
+		}
+	} else {
+		// objects and arrays are printed as they are
+		dst = append(dst, value...)
+	}
+	return t.FieldValue.appendEnd(dst)
+}
+
+// forEachMember calls fn with every member of the object or element of the array raw.
+// The key of an element is its index.
+func (e *consoleEvent) forEachMember(raw []byte, fn func(key []byte, kind jsonKind, value []byte)) error {
+	isArray := raw[0] == '['
+	i := skipSpace(raw, 1)
+	var index []byte
+	for n := 0; i < len(raw) && raw[i] != '}' && raw[i] != ']'; n++ {
+		var key []byte
+		var err error
+		if isArray {
+			index = strconv.AppendInt(index[:0], int64(n), 10)
+			key = index
+		} else {
+			if key, i, err = e.parseString(raw, i); err != nil {
+				return err
+			}
+			i = skipSpace(raw, i)
+			if i >= len(raw) || raw[i] != ':' {
+				return errUnexpectedEnd
+			}
+			i = skipSpace(raw, i+1)
+			if i >= len(raw) {
+				return errUnexpectedEnd
+			}
+		}
+		kind, value, next, err := e.parseValue(raw, i)
+		if err != nil {
+			return err
+		}
+		fn(key, kind, value)
+		i = skipSpace(raw, next)
+		if i < len(raw) && raw[i] == ',' {
+			i = skipSpace(raw, i+1)
+		}
+	}
+	return nil
+}
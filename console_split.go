@@ -0,0 +1,86 @@
+package rzerolog
+
+import (
+	"io"
+	"os"
+	"sync/atomic"
+
+	"github.com/rs/zerolog"
+)
+
+// WithErrOutput writes the events at or above level to out instead of Out,
+// such as warnings and errors to stderr. A nil out means os.Stderr.
+func WithErrOutput(out io.Writer, level Level) ConsoleWriterOption {
+	return func(w *ConsoleWriter) {
+		if out == nil {
+			out = os.Stderr
+		}
+		w.ErrOut = out
+		w.ErrLevel = level
+	}
+}
+
+// output returns the writer of the event and whether it is ErrOut.
+func (w *ConsoleWriter) output(evt *consoleEvent) (io.Writer, bool) {
+	return splitOutput(evt, w.Out, w.ErrOut, w.ErrLevel)
+}
+
+// switchOutput records the stream written now, and flushes the other one if the stream is switched,
+// so that the order of events is kept as much as possible when the streams are buffered.
+func (w *ConsoleWriter) switchOutput(toErr bool) {
+	switchOutput(&w.lastErr, toErr, w.Out, w.ErrOut)
+}
+
+// splitOutput returns errOut if the event is at or above errLevel, otherwise out, and whether it is errOut.
+func splitOutput(evt *consoleEvent, out, errOut io.Writer, errLevel Level) (io.Writer, bool) {
+	if errOut == nil {
+		return out, false
+	}
+	f, ok := evt.field(zerolog.LevelFieldName)
+	if !ok || f.kind != jsonString {
+		return out, false
+	}
+	if l, ok := levelOf(f.value); ok && l >= errLevel {
+		return errOut, true
+	}
+	return out, false
+}
+
+// switchOutput records the stream written now in lastErr, and flushes the other one if it is switched.
+func switchOutput(lastErr *uint32, toErr bool, out, errOut io.Writer) {
+	var curr uint32
+	if toErr {
+		curr = 1
+	}
+	if prev := atomic.SwapUint32(lastErr, curr); prev == curr {
+		return
+	}
+	prevOut := errOut
+	if toErr {
+		prevOut = out
+	}
+	if f, ok := prevOut.(interface{ Flush() error }); ok {
+		_ = f.Flush()
+	}
+}
+
+// levelOf returns the level of the zerolog level name l.
+func levelOf(l []byte) (Level, bool) {
+	switch string(l) {
+	case zerolog.LevelTraceValue:
+		return TraceLevel, true
+	case zerolog.LevelDebugValue:
+		return DebugLevel, true
+	case zerolog.LevelInfoValue:
+		return InfoLevel, true
+	case zerolog.LevelWarnValue:
+		return WarnLevel, true
+	case zerolog.LevelErrorValue:
+		return ErrorLevel, true
+	case zerolog.LevelFatalValue:
+		return FatalLevel, true
+	case zerolog.LevelPanicValue:
+		return PanicLevel, true
+	}
+	return NoLevel, false
+}
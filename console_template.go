@@ -0,0 +1,233 @@
+package rzerolog
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/rs/zerolog"
+)
+
+// TextTemplateData is the data a TextTemplate is executed with, each part formatted as in the text format.
+type TextTemplateData struct {
+	Time    string
+	Level   string
+	Label   string
+	Caller  string
+	Message string
+	// Fields is the fields other than the parts, separated by spaces.
+	Fields string
+}
+
+// templateFields is the fields of TextTemplateData and their parts, "" for Fields.
+var templateFields = []struct{ name, part string }{
+	{"Time", zerolog.TimestampFieldName},
+	{"Level", zerolog.LevelFieldName},
+	{"Label", LabelFieldName},
+	{"Caller", zerolog.CallerFieldName},
+	{"Message", zerolog.MessageFieldName},
+	{"Fields", ""},
+}
+
+// TextTemplate is a line layout of the text format in the syntax of text/template, such as
+//
+//	{{.Time}} [{{.Level}}] {{with .Label}}<{{.}}> {{end}}{{.Message}} {{.Fields}}
+//
+// The layouts only made of text and the fields of TextTemplateData are rendered directly,
+// the others are executed by text/template. In MultilineExpanded, the continuation lines of the message
+// are aligned with its first line as printed, or the start of the line if the template changes it.
+type TextTemplate struct {
+	layout string
+	tmpl   *template.Template
+	// segments is the compiled layout if it is rendered directly, nil otherwise
+	segments []templateSegment
+}
+
+// templateSegment is a text, or a part if isPart is set.
+type templateSegment struct {
+	text   string
+	isPart bool
+}
+
+// ParseTextTemplate compiles the line layout, which must not end with a newline.
+func ParseTextTemplate(layout string) (*TextTemplate, error) {
+	tmpl, err := template.New("text").Parse(layout)
+	if err != nil {
+		return nil, err
+	}
+	// the references to unknown fields only fail on execution
+	sample := TextTemplateData{"t", "l", "b", "c", "m", "f"}
+	if err = tmpl.Execute(ioutil.Discard, &sample); err != nil {
+		return nil, err
+	}
+
+	t := &TextTemplate{layout: layout, tmpl: tmpl}
+	for _, node := range tmpl.Tree.Root.Nodes {
+		if text, ok := node.(*parse.TextNode); ok {
+			t.segments = append(t.segments, templateSegment{text: string(text.Text)})
+			continue
+		}
+		if part, ok := templatePartOf(node); ok {
+			t.segments = append(t.segments, templateSegment{text: part, isPart: true})
+			continue
+		}
+		t.segments = nil
+		break
+	}
+	return t, nil
+}
+
+// templatePartOf returns the part of the field of TextTemplateData if node is an action only printing it.
+func templatePartOf(node parse.Node) (string, bool) {
+	action, ok := node.(*parse.ActionNode)
+	if !ok || len(action.Pipe.Decl) > 0 || len(action.Pipe.Cmds) != 1 || len(action.Pipe.Cmds[0].Args) != 1 {
+		return "", false
+	}
+	field, ok := action.Pipe.Cmds[0].Args[0].(*parse.FieldNode)
+	if !ok || len(field.Ident) != 1 {
+		return "", false
+	}
+	for _, tf := range templateFields {
+		if tf.name == field.Ident[0] {
+			return tf.part, true
+		}
+	}
+	return "", false
+}
+
+// String returns the layout of t.
+func (t *TextTemplate) String() string {
+	return t.layout
+}
+
+// WithTemplate set the line layout, which replaces PartsOrder and the fields.
+func WithTemplate(t *TextTemplate) ConsoleWriterOption {
+	return func(w *ConsoleWriter) {
+		w.Template = t
+	}
+}
+
+// writeTemplate appends evt rendered by w.Template to evt.buf.
+func (w *ConsoleWriter) writeTemplate(evt *consoleEvent) error {
+	t := w.Template
+	if t.segments != nil {
+		for _, s := range t.segments {
+			if !s.isPart {
+				evt.buf = append(evt.buf, s.text...)
+			} else if s.text == "" {
+				w.appendTemplateFields(evt)
+			} else {
+				start := len(evt.buf)
+				w.appendTemplatePart(evt, s.text)
+				if s.text == zerolog.MessageFieldName && w.Multiline == MultilineExpanded {
+					w.splitMessage(evt, start)
+				}
+			}
+		}
+		return nil
+	}
+
+	var data TextTemplateData
+	// rest is the continuation lines of the message, inserted into evt.tail at tailAt once its column is known
+	var rest []byte
+	tailAt := 0
+	for _, tf := range templateFields {
+		start := len(evt.buf)
+		if tf.part == "" {
+			w.appendTemplateFields(evt)
+		} else {
+			w.appendTemplatePart(evt, tf.part)
+		}
+		value := string(evt.buf[start:])
+		evt.buf = evt.buf[:start]
+		if tf.part == zerolog.MessageFieldName && w.Multiline == MultilineExpanded {
+			if i := strings.IndexByte(value, '\n'); i >= 0 {
+				rest = []byte(value[i+1:])
+				value = strings.TrimRight(value[:i], "\r")
+				tailAt = len(evt.tail)
+			}
+		}
+		switch tf.name {
+		case "Time":
+			data.Time = value
+		case "Level":
+			data.Level = value
+		case "Label":
+			data.Label = value
+		case "Caller":
+			data.Caller = value
+		case "Message":
+			data.Message = value
+		case "Fields":
+			data.Fields = value
+		}
+	}
+	out := appendWriter{evt.buf}
+	if err := t.tmpl.Execute(&out, &data); err != nil {
+		return fmt.Errorf("cannot render template: %s", err)
+	}
+	evt.buf = out.buf
+	if rest != nil {
+		evt.indent = templateIndent(evt.buf, data.Message, evt.indent)
+		after := append([]byte(nil), evt.tail[tailAt:]...)
+		evt.tail = appendBlockLines(evt.tail[:tailAt], rest, evt.indent, Style{})
+		evt.tail = append(evt.tail, after...)
+	}
+	return nil
+}
+
+// templateIndent returns the column of the first line of the message in the rendered line,
+// indent if the template does not print it as is.
+func templateIndent(line []byte, message string, indent int) int {
+	i := bytes.Index(line, []byte(message))
+	if message == "" || i < 0 {
+		return indent
+	}
+	return visibleWidth(line[bytes.LastIndexByte(line[:i], '\n')+1 : i])
+}
+
+// appendTemplatePart appends part p formatted as in PartsOrder, nothing if it is absent.
+func (w *ConsoleWriter) appendTemplatePart(evt *consoleEvent, p string) {
+	f, ok := evt.field(p)
+	if !ok || f.kind == jsonNull {
+		f = nil
+	}
+	if p == zerolog.MessageFieldName {
+		evt.indent = visibleWidth(evt.buf)
+	}
+	if formatter := w.partFormatter(p); formatter != nil {
+		evt.buf = append(evt.buf, formatter(partValue(f))...)
+	} else if p == zerolog.CallerFieldName && f != nil && f.kind == jsonString {
+		// the layout has its own separators
+		var fn []byte
+		if ff, ok := evt.field(CallerFuncFieldName); ok && ff.kind == jsonString {
+			fn = ff.value
+		}
+		evt.buf = w.appendCallerPath(evt.buf, string(f.value), fn, evt.theme)
+	} else if f != nil {
+		evt.buf = w.appendDefaultPart(evt, evt.buf, p, f)
+	}
+}
+
+// appendTemplateFields appends the fields without the leading space.
+func (w *ConsoleWriter) appendTemplateFields(evt *consoleEvent) {
+	start := len(evt.buf)
+	evt.order = evt.order[:0]
+	w.writeFields(evt)
+	if len(evt.buf) > start && evt.buf[start] == ' ' {
+		evt.buf = append(evt.buf[:start], evt.buf[start+1:]...)
+	}
+}
+
+// appendWriter appends the written bytes to buf.
+type appendWriter struct {
+	buf []byte
+}
+
+func (w *appendWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	return len(p), nil
+}
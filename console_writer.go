@@ -1,43 +1,20 @@
 package rzerolog
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
 	"github.com/rs/zerolog"
 	"io"
 	"os"
-	"path/filepath"
-	"sort"
 	"strconv"
 	"strings"
-	"sync"
+	"sync/atomic"
 	"time"
 )
 
-const (
-	colorBlack = iota + 30
-	colorRed
-	colorGreen
-	colorYellow
-	colorBlue
-	colorMagenta
-	colorCyan
-	colorWhite
-
-	colorBold     = 1
-	colorDarkGray = 90
-)
-
 var (
 	_ io.Writer  = (*ConsoleWriter)(nil)
 	_ FileWriter = (*ConsoleWriter)(nil)
-
-	consoleBufPool = sync.Pool{
-		New: func() interface{} {
-			return bytes.NewBuffer(make([]byte, 0, 100))
-		},
-	}
 )
 
 const (
@@ -54,18 +31,77 @@ type ConsoleWriter struct {
 	// Out is the output destination.
 	Out io.Writer
 
-	// NoColor disables the colorized output.
+	// NoColor disables the colorized output regardless of ColorMode.
 	NoColor bool
 
+	// ColorMode defines when the output is colorized, ColorAlways if it is not set.
+	// NewConsoleWriter sets it to ColorAuto.
+	ColorMode ColorMode
+	// colorState caches ColorAuto resolved for Out
+	colorState uint32
+
+	// Theme defines the colors of output, DarkTheme if it is nil.
+	Theme *Theme
+
+	// LabelColors colors each label by the hash of its name instead of Theme.Label.
+	LabelColors bool
+	// LabelStyles overrides the styles of labels if LabelColors is set.
+	LabelStyles map[string]Style
+	// LabelWidth is the width the label part is padded to, no padding if it is 0.
+	LabelWidth int
+
+	// Layout defines how the parts and fields are laid out, LayoutCompact by default.
+	Layout ConsoleLayout
+	// PartWidths overrides the widths of parts in LayoutAligned.
+	PartWidths map[string]int
+	// Width is the width of lines in LayoutAligned, the width of the terminal if it is 0.
+	Width int
+	// Overflow defines what to do with the fields exceeding Width in LayoutAligned.
+	Overflow Overflow
+
+	// CallerFormat defines how the file path of caller is printed.
+	CallerFormat CallerFormat
+	// CallerLinks prints the caller as an OSC 8 hyperlink to CallerLinkFormat if the colors are enabled.
+	CallerLinks bool
+	// CallerLinkFormat is the target of caller hyperlinks, DefaultCallerLinkFormat if it is empty.
+	CallerLinkFormat string
+
+	// ErrOut is the output of the events at or above ErrLevel, all the events are written to Out if it is nil.
+	ErrOut io.Writer
+	// ErrLevel is the lowest level of the events written to ErrOut.
+	ErrLevel Level
+	// errColorState caches ColorAuto resolved for ErrOut
+	errColorState uint32
+	// lastErr is 1 if the last event was written to ErrOut
+	lastErr uint32
+
+	// Multiline defines how the messages, errors and stacks of multiple lines are rendered.
+	Multiline MultilineMode
+
+	// Nested defines how the fields of objects and arrays are rendered,
+	// it takes effect only if FormatFieldName and FormatFieldValue are not set.
+	Nested NestedMode
+	// NestedDepth is the depth of members expanded by Nested, no limit if it is 0.
+	NestedDepth int
+
 	// TimeFormat specifies the format for timestamp in output.
 	TimeFormat string
 
 	// PartsOrder defines the order of parts in output.
 	PartsOrder []string
 
+	// Template defines the layout of lines, which replaces PartsOrder and the order of fields if it is set.
+	Template *TextTemplate
+
 	// PartsExclude defines parts to not display in output.
 	PartsExclude []string
 
+	// FieldOrder defines the order of fields after the parts in output.
+	FieldOrder FieldOrder
+
+	// PriorityFields defines the fields printed first in FieldOrderPriority order.
+	PriorityFields []string
+
 	FormatTimestamp     Formatter
 	FormatLevel         Formatter
 	FormatLabel         Formatter
@@ -91,10 +127,33 @@ func WithNoColor() ConsoleWriterOption {
 	}
 }
 
+// WithColorMode set when the output is colorized.
+func WithColorMode(mode ColorMode) ConsoleWriterOption {
+	return func(w *ConsoleWriter) {
+		w.ColorMode = mode
+	}
+}
+
+// WithTheme set the colors of output.
+func WithTheme(t *Theme) ConsoleWriterOption {
+	return func(w *ConsoleWriter) {
+		w.Theme = t
+	}
+}
+
+// WithFieldOrder set the order of fields, priority is the fields pinned first in FieldOrderPriority order.
+func WithFieldOrder(order FieldOrder, priority ...string) ConsoleWriterOption {
+	return func(w *ConsoleWriter) {
+		w.FieldOrder = order
+		w.PriorityFields = priority
+	}
+}
+
 // NewConsoleWriter creates and initializes a new ConsoleWriter.
 func NewConsoleWriter(options ...ConsoleWriterOption) ConsoleWriter {
 	w := ConsoleWriter{
 		Out:        os.Stdout,
+		ColorMode:  ColorAuto,
 		TimeFormat: consoleDefaultTimeFormat,
 		PartsOrder: consoleDefaultPartsOrder(),
 	}
@@ -106,12 +165,16 @@ func NewConsoleWriter(options ...ConsoleWriterOption) ConsoleWriter {
 	return w
 }
 
-func (w *ConsoleWriter) SetOutput(file *os.File) error {
-	w.Out = file
+func (w *ConsoleWriter) SetOutput(out io.WriteCloser) error {
+	w.Out = out
+	atomic.StoreUint32(&w.colorState, colorStateUnresolved)
 	return nil
 }
 
 // Write transforms the JSON input with formatters and appends to w.Out.
+//
+// The input is tokenized in place without decoding it into a map,
+// so writing an event with the default formatters allocates almost nothing.
 func (w *ConsoleWriter) Write(p []byte) (n int, err error) {
 	if !w.Enable {
 		return len(p), nil
@@ -120,129 +183,182 @@ func (w *ConsoleWriter) Write(p []byte) (n int, err error) {
 		w.PartsOrder = consoleDefaultPartsOrder()
 	}
 
-	var buf = consoleBufPool.Get().(*bytes.Buffer)
-	defer func() {
-		buf.Reset()
-		consoleBufPool.Put(buf)
-	}()
+	evt := getConsoleEvent()
+	defer evt.release()
 
-	var evt map[string]interface{}
-	d := json.NewDecoder(bytes.NewReader(p))
-	d.UseNumber()
-	err = d.Decode(&evt)
-	if err != nil {
+	if err = evt.parse(p); err != nil {
 		return n, fmt.Errorf("cannot decode event: %s", err)
 	}
 
-	for _, p := range w.PartsOrder {
-		w.writePart(buf, evt, p)
-	}
+	out, toErr := w.output(evt)
+	evt.theme = w.themeFor(toErr)
 
-	w.writeFields(evt, buf)
+	if w.Template != nil {
+		if err = w.writeTemplate(evt); err != nil {
+			return n, err
+		}
+	} else {
+		for _, p := range w.PartsOrder {
+			w.writePart(evt, p)
+		}
+		w.writeFields(evt)
+	}
+	w.writeBlocks(evt)
 
-	err = buf.WriteByte('\n')
-	if err != nil {
-		return n, err
+	evt.buf = append(evt.buf, evt.tail...)
+	evt.buf = append(evt.buf, '\n')
+	if w.ErrOut != nil {
+		w.switchOutput(toErr)
 	}
-	_, err = buf.WriteTo(w.Out)
+	_, err = out.Write(evt.buf)
 	return len(p), err
 }
 
-// writeFields appends formatted key-value pairs to buf.
-func (w *ConsoleWriter) writeFields(evt map[string]interface{}, buf *bytes.Buffer) {
-	var fields = make([]string, 0, len(evt))
-	for field := range evt {
-		switch field {
+// writeFields appends formatted key-value pairs to evt.buf.
+func (w *ConsoleWriter) writeFields(evt *consoleEvent) {
+	for i := range evt.fields {
+		switch string(evt.fields[i].key) {
 		case zerolog.LevelFieldName,
 			zerolog.TimestampFieldName,
 			zerolog.MessageFieldName,
 			zerolog.CallerFieldName,
+			CallerFuncFieldName,
 			LabelFieldName:
 			continue
 		}
-		fields = append(fields, field)
-	}
-	sort.Strings(fields)
-
-	if len(fields) > 0 {
-		buf.WriteByte(' ')
+		if w.isBlockField(&evt.fields[i]) {
+			continue
+		}
+		evt.order = append(evt.order, i)
 	}
+	w.sortFields(evt)
+
+	t := evt.theme
+	width := w.lineWidth()
+	lineStart := 0
+	for _, idx := range evt.order {
+		f := &evt.fields[idx]
+		isErr := string(f.key) == zerolog.ErrorFieldName
+
+		nested := !isErr && w.FormatFieldName == nil && w.FormatFieldValue == nil && w.isNestedField(f)
+		if nested && w.Nested == NestedTree {
+			evt.tail = appendBlockHeader(evt.tail, string(f.key), t)
+			evt.tail = w.appendTree(evt, evt.tail, f.value, blockIndent+2, 0, t)
+			continue
+		}
 
-	// Move the "error" field to the front
-	ei := sort.Search(len(fields), func(i int) bool { return fields[i] >= zerolog.ErrorFieldName })
-	if ei < len(fields) && fields[ei] == zerolog.ErrorFieldName {
-		fields[ei] = ""
-		fields = append([]string{zerolog.ErrorFieldName}, fields...)
-		var xfields = make([]string, 0, len(fields))
-		for _, field := range fields {
-			if field == "" { // Skip empty fields
-				continue
-			}
-			xfields = append(xfields, field)
+		evt.buf = append(evt.buf, ' ')
+		fieldStart := len(evt.buf)
+		if nested {
+			evt.buf = w.appendFlattened(evt, evt.buf, f.key, f.value, 0, t)
+			w.wrapField(evt, fieldStart, width, &lineStart)
+			continue
 		}
-		fields = xfields
-	}
 
-	for i, field := range fields {
-		var fn Formatter
-		var fv Formatter
+		var fn, fv Formatter
+		nameStyle, valueStyle := t.FieldName, t.FieldValue
+		if isErr {
+			fn, fv = w.FormatErrFieldName, w.FormatErrFieldValue
+			nameStyle, valueStyle = t.Error, t.Error
+		} else {
+			fn, fv = w.FormatFieldName, w.FormatFieldValue
+		}
 
-		if field == zerolog.ErrorFieldName {
-			if w.FormatErrFieldName == nil {
-				fn = consoleDefaultFormatErrFieldName(w.NoColor)
-			} else {
-				fn = w.FormatErrFieldName
-			}
+		if fn != nil {
+			evt.buf = append(evt.buf, fn(string(f.key))...)
+		} else {
+			evt.buf = nameStyle.appendStart(evt.buf)
+			evt.buf = append(evt.buf, f.key...)
+			evt.buf = append(evt.buf, '=')
+			evt.buf = nameStyle.appendEnd(evt.buf)
+		}
 
-			if w.FormatErrFieldValue == nil {
-				fv = consoleDefaultFormatErrFieldValue(w.NoColor)
-			} else {
-				fv = w.FormatErrFieldValue
+		if fv != nil || (isErr && f.kind != jsonString && f.kind != jsonNumber) {
+			if fv == nil {
+				fv = consoleDefaultFormatErrFieldValue(t)
 			}
+			evt.buf = w.appendFormattedValue(evt.buf, f, fv, t)
 		} else {
-			if w.FormatFieldName == nil {
-				fn = consoleDefaultFormatFieldName(w.NoColor)
-			} else {
-				fn = w.FormatFieldName
-			}
+			evt.buf = valueStyle.appendStart(evt.buf)
+			evt.buf = w.appendFieldValue(evt.buf, f, t)
+			evt.buf = valueStyle.appendEnd(evt.buf)
+		}
 
-			if w.FormatFieldValue == nil {
-				fv = consoleDefaultFormatFieldValue
-			} else {
-				fv = w.FormatFieldValue
-			}
+		if !w.wrapField(evt, fieldStart, width, &lineStart) {
+			return
 		}
+	}
+}
+
+// wrapField moves the field at evt.buf[fieldStart:] to a continuation line if the line exceeds width,
+// or elides it and reports false if the fields are elided.
+func (w *ConsoleWriter) wrapField(evt *consoleEvent, fieldStart, width int, lineStart *int) bool {
+	if width <= 0 || visibleWidth(evt.buf[*lineStart:]) <= width || fieldStart-1 <= *lineStart {
+		return true
+	}
+	// the separator before the field is at fieldStart-1
+	if w.Overflow == OverflowElide {
+		evt.buf = append(evt.buf[:fieldStart], ellipsis...)
+		return false
+	}
+	evt.buf = breakLine(evt.buf, fieldStart-1, evt.indent)
+	*lineStart = fieldStart
+	return true
+}
 
-		buf.WriteString(fn(field))
+// sortFields sorts evt.order in the FieldOrder of w.
+func (w *ConsoleWriter) sortFields(evt *consoleEvent) {
+	if w.FieldOrder == FieldOrderInsertion {
+		evt.dedupOrder()
+		return
+	}
 
-		switch fValue := evt[field].(type) {
-		case string:
-			if needsQuote(fValue) {
-				buf.WriteString(fv(strconv.Quote(fValue)))
-			} else {
-				buf.WriteString(fv(fValue))
-			}
-		case json.Number:
-			buf.WriteString(fv(fValue))
-		default:
-			b, err := json.Marshal(fValue)
-			if err != nil {
-				fmt.Fprintf(buf, colorize("[error: %v]", colorRed, w.NoColor), err)
-			} else {
-				fmt.Fprint(buf, fv(b))
-			}
+	evt.sortOrder()
+	// Move the "error" field to the front
+	evt.moveToFront(zerolog.ErrorFieldName)
+
+	if w.FieldOrder == FieldOrderPriority {
+		for i := len(w.PriorityFields) - 1; i >= 0; i-- {
+			evt.moveToFront(w.PriorityFields[i])
 		}
+	}
+}
 
-		if i < len(fields)-1 { // Skip space for last field
-			buf.WriteByte(' ')
+// appendFieldValue appends the field value formatted by the default formatter.
+func (w *ConsoleWriter) appendFieldValue(dst []byte, f *eventField, t *Theme) []byte {
+	switch f.kind {
+	case jsonString:
+		if needsQuoteBytes(f.value) {
+			return appendQuoted(dst, f.value)
 		}
+		return append(dst, f.value...)
+	case jsonNumber, jsonBool, jsonNull:
+		return append(dst, f.value...)
 	}
+	return w.appendFormattedValue(dst, f, consoleDefaultFormatFieldValue, t)
 }
 
-// writePart appends a formatted part to buf.
-func (w *ConsoleWriter) writePart(buf *bytes.Buffer, evt map[string]interface{}, p string) {
-	var f Formatter
+// appendFormattedValue appends the field value formatted by fv.
+func (w *ConsoleWriter) appendFormattedValue(dst []byte, f *eventField, fv Formatter, t *Theme) []byte {
+	switch fValue := f.interfaceValue().(type) {
+	case string:
+		if needsQuote(fValue) {
+			return append(dst, fv(strconv.Quote(fValue))...)
+		}
+		return append(dst, fv(fValue)...)
+	case json.Number:
+		return append(dst, fv(fValue)...)
+	default:
+		b, err := json.Marshal(fValue)
+		if err != nil {
+			return append(dst, fmt.Sprintf(t.Error.render("[error: %v]"), err)...)
+		}
+		return append(dst, fv(b)...)
+	}
+}
 
+// writePart appends a formatted part to evt.buf.
+func (w *ConsoleWriter) writePart(evt *consoleEvent, p string) {
 	if w.PartsExclude != nil && len(w.PartsExclude) > 0 {
 		for _, exclude := range w.PartsExclude {
 			if exclude == p {
@@ -251,53 +367,209 @@ func (w *ConsoleWriter) writePart(buf *bytes.Buffer, evt map[string]interface{},
 		}
 	}
 
+	f, ok := evt.field(p)
+	if !ok || f.kind == jsonNull {
+		// null is decoded as an absent value
+		f = nil
+	}
+
+	start := len(evt.buf)
+	if p == zerolog.MessageFieldName {
+		evt.indent = visibleWidth(evt.buf)
+	}
+	if formatter := w.partFormatter(p); formatter != nil {
+		evt.buf = append(evt.buf, formatter(partValue(f))...)
+	} else {
+		evt.buf = w.appendDefaultPart(evt, evt.buf, p, f)
+	}
+
+	if p == zerolog.MessageFieldName && w.Multiline == MultilineExpanded {
+		w.splitMessage(evt, start)
+	}
+
+	if width, truncate := w.partWidth(p); width > 0 {
+		evt.buf = fitWidth(evt.buf, start, width, truncate, p == zerolog.CallerFieldName)
+	}
+
+	if len(evt.buf) > start {
+		if p != w.PartsOrder[len(w.PartsOrder)-1] { // Skip space for last part
+			evt.buf = append(evt.buf, ' ')
+		}
+	}
+}
+
+// partFormatter returns the custom formatter of part p, or nil if it is not set.
+func (w *ConsoleWriter) partFormatter(p string) Formatter {
 	switch p {
 	case zerolog.LevelFieldName:
-		if w.FormatLevel == nil {
-			f = consoleDefaultFormatLevel(w.NoColor)
-		} else {
-			f = w.FormatLevel
+		return w.FormatLevel
+	case zerolog.TimestampFieldName:
+		return w.FormatTimestamp
+	case zerolog.MessageFieldName:
+		return w.FormatMessage
+	case LabelFieldName:
+		return w.FormatLabel
+	case zerolog.CallerFieldName:
+		return w.FormatCaller
+	default:
+		return w.FormatFieldValue
+	}
+}
+
+// appendDefaultPart appends part p formatted by the default formatter, f is nil if the part is absent.
+// Strings are appended directly, other values fall back to the Formatter of the part.
+func (w *ConsoleWriter) appendDefaultPart(evt *consoleEvent, dst []byte, p string, f *eventField) []byte {
+	t := evt.theme
+	isString := f != nil && f.kind == jsonString
+	switch p {
+	case zerolog.LevelFieldName:
+		if f == nil || isString {
+			return appendLevel(dst, f, t)
 		}
+		return append(dst, consoleDefaultFormatLevel(t)(f.interfaceValue())...)
 	case zerolog.TimestampFieldName:
-		if w.FormatTimestamp == nil {
-			f = consoleDefaultFormatTimestamp(w.TimeFormat, w.NoColor)
-		} else {
-			f = w.FormatTimestamp
+		if isString && isDefaultTimestamp(f.value, w.TimeFormat) {
+			dst = t.Timestamp.appendStart(dst)
+			dst = append(dst, f.value...)
+			return t.Timestamp.appendEnd(dst)
 		}
+		return append(dst, consoleDefaultFormatTimestamp(w.TimeFormat, t)(partValue(f))...)
 	case zerolog.MessageFieldName:
-		if w.FormatMessage == nil {
-			f = consoleDefaultFormatMessage
-		} else {
-			f = w.FormatMessage
+		if isString {
+			return append(dst, f.value...)
 		}
+		return append(dst, consoleDefaultFormatMessage(partValue(f))...)
 	case LabelFieldName:
-		if w.FormatLabel == nil {
-			f = consoleDefaultFormatLabel(w.NoColor)
-		} else {
-			f = w.FormatMessage
+		if isString {
+			style := w.labelStyle(t, f.value)
+			dst = style.appendStart(dst)
+			dst = append(dst, f.value...)
+			return style.appendEnd(dst)
 		}
+		return append(dst, consoleDefaultFormatLabel(t)(partValue(f))...)
 	case zerolog.CallerFieldName:
-		if w.FormatCaller == nil {
-			f = consoleDefaultFormatCaller(w.NoColor)
-		} else {
-			f = w.FormatCaller
+		if isString {
+			var fn []byte
+			if ff, ok := evt.field(CallerFuncFieldName); ok && ff.kind == jsonString {
+				fn = ff.value
+			}
+			return w.appendCaller(dst, string(f.value), fn, t)
 		}
+		return append(dst, consoleDefaultFormatCaller(t)(partValue(f))...)
 	default:
-		if w.FormatFieldValue == nil {
-			f = consoleDefaultFormatFieldValue
-		} else {
-			f = w.FormatFieldValue
+		return append(dst, consoleDefaultFormatFieldValue(partValue(f))...)
+	}
+}
+
+// partValue returns the decoded value of the part f, or nil if it is absent.
+func partValue(f *eventField) interface{} {
+	if f == nil {
+		return nil
+	}
+	return f.interfaceValue()
+}
+
+// appendLevel appends the level abbreviation as consoleDefaultFormatLevel does.
+func appendLevel(dst []byte, f *eventField, t *Theme) []byte {
+	l, style := "???", t.LevelUnknown
+	if f != nil {
+		if abbr, ok := levelAbbr(string(f.value)); ok {
+			l, style = abbr, t.level(string(f.value))
 		}
 	}
+	dst = style.appendStart(dst)
+	dst = append(dst, l...)
+	return style.appendEnd(dst)
+}
 
-	var s = f(evt[p])
+// levelAbbr returns the abbreviation of the zerolog level name l.
+func levelAbbr(l string) (string, bool) {
+	switch l {
+	case "trace":
+		return "TRC", true
+	case "debug":
+		return "DBG", true
+	case "info":
+		return "INF", true
+	case "warn":
+		return "WRN", true
+	case "error":
+		return "ERR", true
+	case "fatal":
+		return "FTL", true
+	case "panic":
+		return "PNC", true
+	}
+	return "???", false
+}
 
-	if len(s) > 0 {
-		buf.WriteString(s)
-		if p != w.PartsOrder[len(w.PartsOrder)-1] { // Skip space for last part
-			buf.WriteByte(' ')
+// isDefaultTimestamp reports whether the timestamp b is printed as is,
+// that is, it is a valid time in DefaultTimeFormat and the output format is the same.
+func isDefaultTimestamp(b []byte, timeFormat string) bool {
+	if zerolog.TimeFieldFormat != DefaultTimeFormat ||
+		(timeFormat != "" && timeFormat != DefaultTimeFormat) {
+		return false
+	}
+	// 2006-01-02 15:04:05.000
+	if len(b) != len(DefaultTimeFormat) {
+		return false
+	}
+	for i, c := range b {
+		switch i {
+		case 4, 7:
+			if c != '-' {
+				return false
+			}
+		case 10:
+			if c != ' ' {
+				return false
+			}
+		case 13, 16:
+			if c != ':' {
+				return false
+			}
+		case 19:
+			if c != '.' {
+				return false
+			}
+		default:
+			if c < '0' || c > '9' {
+				return false
+			}
+		}
+	}
+	num := func(i, j int) int {
+		n := 0
+		for _, c := range b[i:j] {
+			n = n*10 + int(c-'0')
 		}
+		return n
+	}
+	year, month, day := num(0, 4), num(5, 7), num(8, 10)
+	if month < 1 || month > 12 || day < 1 || num(11, 13) > 23 || num(14, 16) > 59 || num(17, 19) > 59 {
+		return false
 	}
+	return day <= time.Date(year, time.Month(month)+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// theme returns the theme of Out, or a theme without colors if the colors are disabled.
+func (w *ConsoleWriter) theme() *Theme {
+	return w.themeFor(false)
+}
+
+// themeFor returns the theme of ErrOut if toErr is set, otherwise of Out.
+func (w *ConsoleWriter) themeFor(toErr bool) *Theme {
+	disabled := w.colorDisabled()
+	if toErr {
+		disabled = w.colorDisabledFor(w.ErrOut, &w.errColorState)
+	}
+	if disabled {
+		return &noColorTheme
+	}
+	if w.Theme == nil {
+		return &darkTheme
+	}
+	return w.Theme
 }
 
 // needsQuote returns true when the string s should be quoted in output.
@@ -310,12 +582,14 @@ func needsQuote(s string) bool {
 	return false
 }
 
-// colorize returns the string s wrapped in ANSI code c, unless disabled is true.
-func colorize(s interface{}, c int, disabled bool) string {
-	if disabled {
-		return fmt.Sprintf("%s", s)
+// needsQuoteBytes is needsQuote for bytes.
+func needsQuoteBytes(s []byte) bool {
+	for _, c := range s {
+		if c < 0x20 || c > 0x7e || c == ' ' || c == '\\' || c == '"' {
+			return true
+		}
 	}
-	return fmt.Sprintf("\x1b[%dm%v\x1b[0m", c, s)
+	return false
 }
 
 // ----- DEFAULT FORMATTERS ---------------------------------------------------
@@ -330,7 +604,7 @@ func consoleDefaultPartsOrder() []string {
 	}
 }
 
-func consoleDefaultFormatTimestamp(timeFormat string, noColor bool) Formatter {
+func consoleDefaultFormatTimestamp(timeFormat string, theme *Theme) Formatter {
 	if timeFormat == "" {
 		timeFormat = consoleDefaultTimeFormat
 	}
@@ -362,35 +636,19 @@ func consoleDefaultFormatTimestamp(timeFormat string, noColor bool) Formatter {
 				t = ts.Format(timeFormat)
 			}
 		}
-		return colorize(t, colorDarkGray, noColor)
+		return theme.Timestamp.render(t)
 	}
 }
 
-func consoleDefaultFormatLevel(noColor bool) Formatter {
+func consoleDefaultFormatLevel(theme *Theme) Formatter {
 	return func(i interface{}) string {
 		var l string
 		if ll, ok := i.(string); ok {
-			switch ll {
-			case "trace":
-				l = colorize("TRC", colorMagenta, noColor)
-			case "debug":
-				l = colorize("DBG", colorYellow, noColor)
-			case "info":
-				l = colorize("INF", colorGreen, noColor)
-			case "warn":
-				l = colorize("WRN", colorRed, noColor)
-			case "error":
-				l = colorize(colorize("ERR", colorRed, noColor), colorBold, noColor)
-			case "fatal":
-				l = colorize(colorize("FTL", colorRed, noColor), colorBold, noColor)
-			case "panic":
-				l = colorize(colorize("PNC", colorRed, noColor), colorBold, noColor)
-			default:
-				l = colorize("???", colorBold, noColor)
-			}
+			abbr, _ := levelAbbr(ll)
+			l = theme.level(ll).render(abbr)
 		} else {
 			if i == nil {
-				l = colorize("???", colorBold, noColor)
+				l = theme.LevelUnknown.render("???")
 			} else {
 				l = strings.ToUpper(fmt.Sprintf("%s", i))[0:3]
 			}
@@ -399,33 +657,23 @@ func consoleDefaultFormatLevel(noColor bool) Formatter {
 	}
 }
 
-func consoleDefaultFormatLabel(noColor bool) Formatter {
+func consoleDefaultFormatLabel(theme *Theme) Formatter {
 	return func(i interface{}) string {
 		if i == nil {
 			return ""
 		}
-		return colorize(fmt.Sprintf("%s", i), colorBlue, noColor)
+		return theme.Label.render(fmt.Sprintf("%s", i))
 	}
 }
 
-func consoleDefaultFormatCaller(noColor bool) Formatter {
+func consoleDefaultFormatCaller(theme *Theme) Formatter {
 	return func(i interface{}) string {
 		var c string
 		if cc, ok := i.(string); ok {
 			c = cc
 		}
 		if len(c) > 0 {
-			if cwd, err := os.Getwd(); err == nil {
-				if rel, err := filepath.Rel(cwd, c); err == nil {
-					c = rel
-					ss := strings.Split(c, string(filepath.Separator))
-					ssl := len(ss)
-					if ssl > 1 {
-						c = ss[ssl-2] + "/" + ss[ssl-1]
-					}
-				}
-			}
-			c = colorize(c, colorBold, noColor) + colorize(" >", colorCyan, noColor)
+			c = theme.Caller.render(formatCallerPath(c, CallerShort)) + theme.FieldName.render(" >")
 		}
 		return c
 	}
@@ -438,9 +686,9 @@ func consoleDefaultFormatMessage(i interface{}) string {
 	return fmt.Sprintf("%s", i)
 }
 
-func consoleDefaultFormatFieldName(noColor bool) Formatter {
+func consoleDefaultFormatFieldName(theme *Theme) Formatter {
 	return func(i interface{}) string {
-		return colorize(fmt.Sprintf("%s=", i), colorCyan, noColor)
+		return theme.FieldName.render(fmt.Sprintf("%s=", i))
 	}
 }
 
@@ -448,14 +696,14 @@ func consoleDefaultFormatFieldValue(i interface{}) string {
 	return fmt.Sprintf("%s", i)
 }
 
-func consoleDefaultFormatErrFieldName(noColor bool) Formatter {
+func consoleDefaultFormatErrFieldName(theme *Theme) Formatter {
 	return func(i interface{}) string {
-		return colorize(fmt.Sprintf("%s=", i), colorRed, noColor)
+		return theme.Error.render(fmt.Sprintf("%s=", i))
 	}
 }
 
-func consoleDefaultFormatErrFieldValue(noColor bool) Formatter {
+func consoleDefaultFormatErrFieldValue(theme *Theme) Formatter {
 	return func(i interface{}) string {
-		return colorize(fmt.Sprintf("%s", i), colorRed, noColor)
+		return theme.Error.render(fmt.Sprintf("%s", i))
 	}
 }
@@ -0,0 +1,795 @@
+package rzerolog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// legacyConsoleWrite is the map based implementation of ConsoleWriter.Write,
+// which is the reference of the output.
+func legacyConsoleWrite(w *ConsoleWriter, p []byte) (string, error) {
+	if w.PartsOrder == nil {
+		w.PartsOrder = consoleDefaultPartsOrder()
+	}
+	buf := &bytes.Buffer{}
+
+	var evt map[string]interface{}
+	d := json.NewDecoder(bytes.NewReader(p))
+	d.UseNumber()
+	if err := d.Decode(&evt); err != nil {
+		return "", fmt.Errorf("cannot decode event: %s", err)
+	}
+
+	for _, p := range w.PartsOrder {
+		legacyWritePart(w, buf, evt, p)
+	}
+	legacyWriteFields(w, evt, buf)
+	buf.WriteByte('\n')
+	return buf.String(), nil
+}
+
+func legacyWriteFields(w *ConsoleWriter, evt map[string]interface{}, buf *bytes.Buffer) {
+	var fields = make([]string, 0, len(evt))
+	for field := range evt {
+		switch field {
+		case zerolog.LevelFieldName,
+			zerolog.TimestampFieldName,
+			zerolog.MessageFieldName,
+			zerolog.CallerFieldName,
+			LabelFieldName:
+			continue
+		}
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	if len(fields) > 0 {
+		buf.WriteByte(' ')
+	}
+
+	// Move the "error" field to the front
+	ei := sort.Search(len(fields), func(i int) bool { return fields[i] >= zerolog.ErrorFieldName })
+	if ei < len(fields) && fields[ei] == zerolog.ErrorFieldName {
+		fields[ei] = ""
+		fields = append([]string{zerolog.ErrorFieldName}, fields...)
+		var xfields = make([]string, 0, len(fields))
+		for _, field := range fields {
+			if field == "" { // Skip empty fields
+				continue
+			}
+			xfields = append(xfields, field)
+		}
+		fields = xfields
+	}
+
+	for i, field := range fields {
+		var fn Formatter
+		var fv Formatter
+
+		if field == zerolog.ErrorFieldName {
+			if w.FormatErrFieldName == nil {
+				fn = consoleDefaultFormatErrFieldName(w.theme())
+			} else {
+				fn = w.FormatErrFieldName
+			}
+
+			if w.FormatErrFieldValue == nil {
+				fv = consoleDefaultFormatErrFieldValue(w.theme())
+			} else {
+				fv = w.FormatErrFieldValue
+			}
+		} else {
+			if w.FormatFieldName == nil {
+				fn = consoleDefaultFormatFieldName(w.theme())
+			} else {
+				fn = w.FormatFieldName
+			}
+
+			if w.FormatFieldValue == nil {
+				fv = consoleDefaultFormatFieldValue
+			} else {
+				fv = w.FormatFieldValue
+			}
+		}
+
+		buf.WriteString(fn(field))
+
+		switch fValue := evt[field].(type) {
+		case string:
+			if needsQuote(fValue) {
+				buf.WriteString(fv(strconv.Quote(fValue)))
+			} else {
+				buf.WriteString(fv(fValue))
+			}
+		case json.Number:
+			buf.WriteString(fv(fValue))
+		default:
+			b, err := json.Marshal(fValue)
+			if err != nil {
+				fmt.Fprintf(buf, w.theme().Error.render("[error: %v]"), err)
+			} else {
+				fmt.Fprint(buf, fv(b))
+			}
+		}
+
+		if i < len(fields)-1 { // Skip space for last field
+			buf.WriteByte(' ')
+		}
+	}
+}
+
+func legacyWritePart(w *ConsoleWriter, buf *bytes.Buffer, evt map[string]interface{}, p string) {
+	var f Formatter
+
+	if w.PartsExclude != nil && len(w.PartsExclude) > 0 {
+		for _, exclude := range w.PartsExclude {
+			if exclude == p {
+				return
+			}
+		}
+	}
+
+	switch p {
+	case zerolog.LevelFieldName:
+		if w.FormatLevel == nil {
+			f = consoleDefaultFormatLevel(w.theme())
+		} else {
+			f = w.FormatLevel
+		}
+	case zerolog.TimestampFieldName:
+		if w.FormatTimestamp == nil {
+			f = consoleDefaultFormatTimestamp(w.TimeFormat, w.theme())
+		} else {
+			f = w.FormatTimestamp
+		}
+	case zerolog.MessageFieldName:
+		if w.FormatMessage == nil {
+			f = consoleDefaultFormatMessage
+		} else {
+			f = w.FormatMessage
+		}
+	case LabelFieldName:
+		if w.FormatLabel == nil {
+			f = consoleDefaultFormatLabel(w.theme())
+		} else {
+			f = w.FormatLabel
+		}
+	case zerolog.CallerFieldName:
+		if w.FormatCaller == nil {
+			f = consoleDefaultFormatCaller(w.theme())
+		} else {
+			f = w.FormatCaller
+		}
+	default:
+		if w.FormatFieldValue == nil {
+			f = consoleDefaultFormatFieldValue
+		} else {
+			f = w.FormatFieldValue
+		}
+	}
+
+	var s = f(evt[p])
+
+	if len(s) > 0 {
+		buf.WriteString(s)
+		if p != w.PartsOrder[len(w.PartsOrder)-1] { // Skip space for last part
+			buf.WriteByte(' ')
+		}
+	}
+}
+
+type testObject struct{}
+
+func (testObject) MarshalZerologObject(e *zerolog.Event) {
+	e.Str("name", "<obj>").Int("size", 3).Bool("ok", true)
+}
+
+// consoleTestEvents returns JSON log records covering the field types.
+func consoleTestEvents() [][]byte {
+	var lines [][]byte
+	capture := &captureWriter{lines: &lines}
+	logger := NewRZeroLogger(DisableConsolePrint())
+	logger.Logger = logger.Logger.Output(capture)
+	sub := logger.GetLabeledSubLogger("p2p")
+
+	sub.Info().Str("peer", "node-1").Int("count", 42).Msg("connected")
+	sub.Warn().Str("quoted", `say "hi"`).Str("space", "a b").Str("unicode", "héllo 世界").
+		Str("newline", "line1\nline2").Str("tab", "a\tb").Str("empty", "").Msg("strings")
+	logger.Error().Err(errors.New("boom: failed")).Float64("ratio", 0.125).Float64("big", 1e21).
+		Bool("flag", false).Uint64("max", 1<<63).Msg("with error")
+	logger.Debug().Dict("req", &Event{Event: zerolog.Dict().Str("host", "example.com").Int("port", 80)}).
+		Ints("ids", []int{3, 1, 2}).Strs("names", []string{"b", "a <&>"}).Msg("nested")
+	logger.Trace().Interface("map", map[string]interface{}{"z": 1, "a": []interface{}{nil, "x"}}).
+		Interface("nil", nil).Object("obj", testObject{}).RawJSON("raw", []byte(`{"b":1,"a":"<tag>"}`)).Msg("")
+	logger.Log().Str("dup", "first").Str("dup", "second").Msg("no level")
+	logger.Info().Errs("errors", []error{errors.New("e1"), errors.New("e2")}).Dur("elapsed", 1500*time.Millisecond).Send()
+	logger.Info().AnErr("error", nil).Str("error", "plain").Msg("error string")
+	logger.WithLevel(FatalLevel).Msg("fatal")
+	logger.WithLevel(PanicLevel).Msg("panic")
+	logger.WithLevel(ErrorLevel).Bytes("bytes", []byte("\x00\x01\xff")).Msg("bytes")
+
+	handmade := []string{
+		`{}`,
+		`{"message":"only message"}`,
+		` { "level" : "info" , "time" : "2022-02-11 16:37:40.789" , "message" : "spaces" } `,
+		`{"level":"custom","time":"2022-02-30 16:37:40.789","message":"bad day"}`,
+		`{"level":"info","time":"2022-02-11T16:37:40Z","message":"other layout"}`,
+		`{"level":"info","time":1644597460,"message":"unix time"}`,
+		`{"level":null,"time":null,"message":null,"label":null,"caller":null}`,
+		`{"level":"info","message":12.5,"label":7}`,
+		`{"message":"escapes é 😀 \ud800 \/ \b\f","key":"v "}`,
+		`{"message":"invalid \xff utf8","x":"\xfe"}`,
+		`{"a":-0.5e-3,"b":[],"c":{},"d":[{"y":2,"x":1}],"e":true,"f":null}`,
+		`{"error":{"code":1},"a":"1"}`,
+		`{"error":false,"zzz":1}`,
+		`{"dup":1,"dup":{"b":2},"dup":"last","message":"first","message":"second"}`,
+	}
+	for _, h := range handmade {
+		lines = append(lines, []byte(h))
+	}
+	return lines
+}
+
+type captureWriter struct {
+	lines *[][]byte
+}
+
+func (c *captureWriter) Write(p []byte) (int, error) {
+	*c.lines = append(*c.lines, append([]byte(nil), p...))
+	return len(p), nil
+}
+
+func TestConsoleWriterMatchesLegacyOutput(t *testing.T) {
+	upper := func(i interface{}) string { return strings.ToUpper(fmt.Sprint(i)) }
+	writers := []ConsoleWriter{
+		{Enable: true, NoColor: true},
+		{Enable: true, NoColor: false},
+		{Enable: true, NoColor: true, TimeFormat: time.Kitchen, PartsExclude: []string{zerolog.CallerFieldName}},
+		{Enable: true, NoColor: true, PartsOrder: []string{"message", "peer", "level", "missing"}},
+		{Enable: true, NoColor: false, FormatLevel: upper, FormatMessage: upper, FormatLabel: upper,
+			FormatTimestamp: upper, FormatCaller: upper, FormatFieldName: upper, FormatFieldValue: upper,
+			FormatErrFieldName: upper, FormatErrFieldValue: upper},
+	}
+	for _, line := range consoleTestEvents() {
+		for i := range writers {
+			out := &bytes.Buffer{}
+			w := writers[i]
+			w.Out = out
+			want, wantErr := legacyConsoleWrite(&w, line)
+			_, err := w.Write(line)
+			require.Equal(t, wantErr == nil, err == nil, "%d: %s", i, line)
+			require.Equal(t, want, out.String(), "%d: %s", i, line)
+		}
+	}
+}
+
+func TestConsoleWriterInvalidInput(t *testing.T) {
+	w := ConsoleWriter{Enable: true, Out: ioutil.Discard}
+	for _, s := range []string{``, `[]`, `{"a"}`, `{"a":}`, `{"a":1`, `{"a":"b}`, `{"a":tru}`, `{"a":"\x}`, `{"a":-}`} {
+		_, err := w.Write([]byte(s))
+		require.Error(t, err, s)
+	}
+}
+
+func benchmarkEvent() []byte {
+	return []byte(`{"level":"info","label":"p2p","peer":"node-1","count":42,"ratio":0.125,` +
+		`"ok":true,"time":"2022-02-11 16:37:40.789","message":"connected to peer"}`)
+}
+
+func BenchmarkConsoleWriter(b *testing.B) {
+	w := ConsoleWriter{Enable: true, Out: ioutil.Discard}
+	line := benchmarkEvent()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = w.Write(line)
+	}
+}
+
+func BenchmarkConsoleWriterLegacy(b *testing.B) {
+	w := ConsoleWriter{Enable: true, Out: ioutil.Discard}
+	line := benchmarkEvent()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = legacyConsoleWrite(&w, line)
+	}
+}
+
+func TestConsoleWriterAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("sync.Pool drops items under the race detector")
+	}
+	w := ConsoleWriter{Enable: true, Out: ioutil.Discard}
+	line := benchmarkEvent()
+	allocs := testing.AllocsPerRun(100, func() {
+		_, _ = w.Write(line)
+	})
+	require.Zero(t, allocs)
+}
+
+func TestConsoleWriterFieldOrder(t *testing.T) {
+	line := []byte(`{"level":"info","zeta":1,"error":"boom","alpha":"a","user":"u1","dup":1,"request_id":7,"dup":2,"message":"hi"}`)
+	for _, tc := range []struct {
+		opt  ConsoleWriterOption
+		want string
+	}{
+		{WithFieldOrder(FieldOrderAlphabetical), "<nil> INF hi error=boom alpha=a dup=2 request_id=7 user=u1 zeta=1\n"},
+		{WithFieldOrder(FieldOrderInsertion), "<nil> INF hi zeta=1 error=boom alpha=a user=u1 request_id=7 dup=2\n"},
+		{WithFieldOrder(FieldOrderPriority, "request_id", "missing", "user"), "<nil> INF hi request_id=7 user=u1 error=boom alpha=a dup=2 zeta=1\n"},
+	} {
+		out := &bytes.Buffer{}
+		w := NewConsoleWriter(EnableConsoleWriter(), WithNoColor(), tc.opt)
+		w.Out = out
+		_, err := w.Write(line)
+		require.Nil(t, err)
+		require.Equal(t, tc.want, out.String())
+	}
+
+	order, err := ParseFieldOrder("insertion")
+	require.Nil(t, err)
+	require.Equal(t, FieldOrderInsertion, order)
+	_, err = ParseFieldOrder("random")
+	require.Error(t, err)
+}
+
+func TestTextFieldOrderOption(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.apply(WithLogFormat(LogFormatConsoleText), WithTextFieldOrder(FieldOrderPriority, "user"))
+	cfg.applyTextOptions()
+	require.Equal(t, FieldOrderPriority, cfg.cw.FieldOrder)
+	require.Equal(t, FieldOrderPriority, cfg.fw.writer.(*ConsoleWriter).FieldOrder)
+	require.Equal(t, []string{"user"}, cfg.fw.writer.(*ConsoleWriter).PriorityFields)
+}
+
+func TestConsoleWriterColorMode(t *testing.T) {
+	setenv := func(key, value string) {
+		old, ok := os.LookupEnv(key)
+		if value == "" {
+			_ = os.Unsetenv(key)
+		} else {
+			_ = os.Setenv(key, value)
+		}
+		t.Cleanup(func() {
+			if ok {
+				_ = os.Setenv(key, old)
+			} else {
+				_ = os.Unsetenv(key)
+			}
+		})
+	}
+	setenv("NO_COLOR", "")
+	setenv("FORCE_COLOR", "")
+
+	f, err := os.Create(filepath.Join(t.TempDir(), "out.log"))
+	require.Nil(t, err)
+	defer f.Close()
+	require.False(t, isTerminal(f))
+	require.False(t, isTerminal(&bytes.Buffer{}))
+
+	colored := func(w *ConsoleWriter) bool {
+		return !w.colorDisabled()
+	}
+	// the zero value keeps the colors regardless of the output
+	require.True(t, colored(&ConsoleWriter{Enable: true, Out: f}))
+
+	w := NewConsoleWriter(EnableConsoleWriter())
+	require.Equal(t, ColorAuto, w.ColorMode)
+	require.Nil(t, w.SetOutput(f))
+	require.False(t, colored(&w))
+
+	setenv("FORCE_COLOR", "1")
+	require.False(t, colored(&w), "auto mode is resolved once per output")
+	require.Nil(t, w.SetOutput(f))
+	require.True(t, colored(&w))
+
+	setenv("NO_COLOR", "1")
+	require.Nil(t, w.SetOutput(f))
+	require.False(t, colored(&w))
+
+	w = NewConsoleWriter(EnableConsoleWriter(), WithColorMode(ColorAlways))
+	require.True(t, colored(&w))
+	w = NewConsoleWriter(EnableConsoleWriter(), WithColorMode(ColorAlways), WithNoColor())
+	require.False(t, colored(&w))
+
+	setenv("NO_COLOR", "")
+	setenv("FORCE_COLOR", "0")
+	w = NewConsoleWriter(EnableConsoleWriter())
+	require.False(t, colored(&w))
+	w = NewConsoleWriter(EnableConsoleWriter(), WithColorMode(ColorNever))
+	require.False(t, colored(&w))
+
+	mode, err := ParseColorMode("always")
+	require.Nil(t, err)
+	require.Equal(t, ColorAlways, mode)
+	_, err = ParseColorMode("sometimes")
+	require.Error(t, err)
+}
+
+func TestConsoleWriterTheme(t *testing.T) {
+	line := []byte(`{"level":"error","time":"2022-02-11 16:37:40.789","label":"p2p","caller":"a/b/c.go:1",` +
+		`"message":"hi","n":1,"error":"boom"}`)
+	write := func(opts ...ConsoleWriterOption) string {
+		out := &bytes.Buffer{}
+		w := NewConsoleWriter(append([]ConsoleWriterOption{EnableConsoleWriter(), WithColorMode(ColorAlways)}, opts...)...)
+		w.Out = out
+		_, err := w.Write(line)
+		require.Nil(t, err)
+		return out.String()
+	}
+
+	// the default theme keeps the colors before themes
+	dark := "\x1b[90m2022-02-11 16:37:40.789\x1b[0m \x1b[1m\x1b[31mERR\x1b[0m\x1b[0m \x1b[34mp2p\x1b[0m " +
+		"\x1b[1ma/b/c.go:1\x1b[0m\x1b[36m >\x1b[0m hi \x1b[31merror=\x1b[0m\x1b[31mboom\x1b[0m \x1b[36mn=\x1b[0m1\n"
+	require.Equal(t, dark, write())
+	require.Equal(t, dark, write(WithTheme(DarkTheme())))
+
+	light, err := ThemeByName(ThemeLightName)
+	require.Nil(t, err)
+	require.Equal(t, "\x1b[3m\x1b[38;5;244m2022-02-11 16:37:40.789\x1b[0m\x1b[0m \x1b[1m\x1b[38;5;160mERR\x1b[0m\x1b[0m "+
+		"\x1b[38;5;25mp2p\x1b[0m \x1b[1ma/b/c.go:1\x1b[0m\x1b[38;5;30m >\x1b[0m hi "+
+		"\x1b[38;5;160merror=\x1b[0m\x1b[38;5;160mboom\x1b[0m \x1b[38;5;30mn=\x1b[0m1\n", write(WithTheme(light)))
+
+	custom := &Theme{
+		LevelError: Style{Fg: ColorRGB(255, 0, 128), Dim: true},
+		FieldValue: Style{Fg: ColorBrightWhite, Italic: true},
+	}
+	require.Equal(t, "2022-02-11 16:37:40.789 \x1b[2m\x1b[38;2;255;0;128mERR\x1b[0m\x1b[0m p2p a/b/c.go:1 > hi "+
+		"error=boom n=\x1b[3m\x1b[97m1\x1b[0m\x1b[0m\n", write(WithTheme(custom)))
+
+	require.Equal(t, "2022-02-11 16:37:40.789 ERR p2p a/b/c.go:1 > hi error=boom n=1\n",
+		write(WithTheme(HighContrastTheme()), WithColorMode(ColorNever)))
+
+	_, err = ThemeByName("solarized")
+	require.Error(t, err)
+}
+
+func TestConsoleWriterLabelColors(t *testing.T) {
+	write := func(label string, opts ...ConsoleWriterOption) string {
+		out := &bytes.Buffer{}
+		w := NewConsoleWriter(append([]ConsoleWriterOption{EnableConsoleWriter()}, opts...)...)
+		w.PartsOrder = []string{LabelFieldName, zerolog.MessageFieldName}
+		w.Out = out
+		_, err := w.Write([]byte(`{"label":"` + label + `","message":"hi"}`))
+		require.Nil(t, err)
+		return out.String()
+	}
+
+	overrides, err := ParseLabelStyles([]string{"rpc=#ff8700", "db=bright-green"})
+	require.Nil(t, err)
+	colored := []ConsoleWriterOption{WithColorMode(ColorAlways), WithLabelColors(overrides)}
+	require.Equal(t, write("p2p", colored...), write("p2p", colored...), "colors are stable")
+	seen := map[string]bool{}
+	for _, label := range []string{"p2p", "consensus", "mempool", "state", "evidence", "blocksync"} {
+		out := write(label, colored...)
+		require.NotContains(t, out, "\x1b[34m")
+		seen[out[:strings.IndexByte(out, 'm')]] = true
+	}
+	require.Greater(t, len(seen), 1)
+	require.Equal(t, "\x1b[38;2;255;135;0mrpc\x1b[0m hi\n", write("rpc", colored...))
+	require.Equal(t, "\x1b[92mdb\x1b[0m hi\n", write("db", colored...))
+	require.Equal(t, "\x1b[34mp2p\x1b[0m hi\n", write("p2p", WithColorMode(ColorAlways)))
+	require.Equal(t, "p2p hi\n", write("p2p", WithColorMode(ColorNever), WithLabelColors(nil)))
+
+	require.Equal(t, "p2p      hi\n", write("p2p", WithColorMode(ColorNever), WithLabelWidth(8)))
+	require.Equal(t, "\x1b[38;2;255;135;0mrpc\x1b[0m      hi\n", write("rpc", append(colored, WithLabelWidth(8))...))
+	require.Equal(t, "consensus hi\n", write("consensus", WithColorMode(ColorNever), WithLabelWidth(4)))
+	require.Equal(t, "         hi\n", write("", WithColorMode(ColorNever), WithLabelWidth(8)))
+
+	_, err = ParseLabelStyles([]string{"rpc"})
+	require.Error(t, err)
+	_, err = ParseLabelStyles([]string{"rpc=#ff87"})
+	require.Error(t, err)
+}
+
+func TestParseColor(t *testing.T) {
+	for s, want := range map[string]Color{
+		"red": ColorRed, "Bright-Cyan": ColorBrightCyan, "208": Color256(208), "#00ff80": ColorRGB(0, 255, 128),
+	} {
+		c, err := ParseColor(s)
+		require.Nil(t, err, s)
+		require.Equal(t, want, c, s)
+	}
+	for _, s := range []string{"", "pink", "256", "#12345", "#gggggg"} {
+		_, err := ParseColor(s)
+		require.Error(t, err, s)
+	}
+}
+
+func TestConsoleWriterAlignedLayout(t *testing.T) {
+	write := func(line string, opts ...ConsoleWriterOption) string {
+		out := &bytes.Buffer{}
+		w := NewConsoleWriter(append([]ConsoleWriterOption{EnableConsoleWriter(), WithColorMode(ColorNever)}, opts...)...)
+		w.PartsOrder = []string{zerolog.LevelFieldName, LabelFieldName, zerolog.CallerFieldName, zerolog.MessageFieldName}
+		w.Out = out
+		_, err := w.Write([]byte(line))
+		require.Nil(t, err)
+		return out.String()
+	}
+	widths := map[string]int{LabelFieldName: 6, zerolog.CallerFieldName: 12}
+	aligned := []ConsoleWriterOption{WithAlignedLayout(widths), WithLineWidth(200, OverflowWrap)}
+
+	require.Equal(t, "INF p2p    pkg/a.go:1 > hi\n",
+		write(`{"level":"info","label":"p2p","caller":"pkg/a.go:1","message":"hi"}`, aligned...))
+	require.Equal(t, "WRN conse… …er.go:123 > hi\n",
+		write(`{"level":"warn","label":"consensus","caller":"rpc/server.go:123","message":"hi"}`, aligned...))
+	require.Equal(t, "DBG                     hi\n",
+		write(`{"level":"debug","message":"hi"}`, aligned...))
+
+	// truncation keeps the styles closed
+	colored := write(`{"level":"info","label":"consensus","message":"hi"}`,
+		WithColorMode(ColorAlways), WithAlignedLayout(widths))
+	require.Contains(t, colored, "\x1b[34mconse…\x1b[0m ")
+
+	line := `{"level":"info","label":"p2p","caller":"pkg/a.go:1","message":"connected",` +
+		`"peer":"node-1","address":"10.0.0.1:26656","height":123456,"round":0}`
+	require.Equal(t, "INF p2p    pkg/a.go:1 > connected address=10.0.0.1:26656\n"+
+		"                        height=123456 peer=node-1 round=0\n",
+		write(line, WithAlignedLayout(widths), WithLineWidth(60, OverflowWrap)))
+	require.Equal(t, "INF p2p    pkg/a.go:1 > connected\n"+
+		"                        address=10.0.0.1:26656\n"+
+		"                        height=123456 peer=node-1\n"+
+		"                        round=0\n",
+		write(line, WithAlignedLayout(widths), WithLineWidth(50, OverflowWrap)))
+	require.Equal(t, "INF p2p    pkg/a.go:1 > connected address=10.0.0.1:26656 …\n",
+		write(line, WithAlignedLayout(widths), WithLineWidth(60, OverflowElide)))
+
+	// the compact layout is not affected by the widths
+	require.Equal(t, "INF p2p pkg/a.go:1 > connected address=10.0.0.1:26656 height=123456 peer=node-1 round=0\n",
+		write(line, WithLineWidth(60, OverflowWrap)))
+
+	layout, err := ParseConsoleLayout("aligned")
+	require.Nil(t, err)
+	require.Equal(t, LayoutAligned, layout)
+	overflow, err := ParseOverflow("elide")
+	require.Nil(t, err)
+	require.Equal(t, OverflowElide, overflow)
+	parsed, err := ParsePartWidths([]string{"label=6", "caller=12"})
+	require.Nil(t, err)
+	require.Equal(t, widths, parsed)
+	_, err = ParsePartWidths([]string{"label=-1"})
+	require.Error(t, err)
+}
+
+func TestConsoleWriterMultiline(t *testing.T) {
+	line := []byte(`{"level":"error","message":"request failed\nretrying later","peer":"n1",` +
+		`"stack":[{"func":"main.serve","line":"42","source":"main.go"},{"func":"main.main","line":"12","source":"main.go"}],` +
+		`"error":"dial tcp: refused\ncaused by: timeout"}`)
+	write := func(line []byte, opts ...ConsoleWriterOption) string {
+		out := &bytes.Buffer{}
+		w := NewConsoleWriter(append([]ConsoleWriterOption{EnableConsoleWriter(), WithColorMode(ColorNever)}, opts...)...)
+		w.PartsOrder = []string{zerolog.LevelFieldName, zerolog.MessageFieldName}
+		w.Out = out
+		_, err := w.Write(line)
+		require.Nil(t, err)
+		return out.String()
+	}
+
+	require.Equal(t, "ERR request failed\nretrying later "+
+		`error="dial tcp: refused\ncaused by: timeout" peer=n1 `+
+		`stack=[{"func":"main.serve","line":"42","source":"main.go"},{"func":"main.main","line":"12","source":"main.go"}]`+"\n",
+		write(line))
+	require.Equal(t, "ERR request failed peer=n1\n"+
+		"    retrying later\n"+
+		"    error: dial tcp: refused\n"+
+		"           caused by: timeout\n"+
+		"    stack:\n"+
+		"      at main.serve (main.go:42)\n"+
+		"      at main.main (main.go:12)\n",
+		write(line, WithMultiline(MultilineExpanded)))
+
+	// errors of a single line stay in the fields
+	require.Equal(t, "ERR done error=refused\n    stack:\n      goroutine 1\n      main.main()\n",
+		write([]byte(`{"level":"error","message":"done","error":"refused","stack":"goroutine 1\nmain.main()"}`),
+			WithMultiline(MultilineExpanded)))
+
+	mode, err := ParseMultilineMode("expanded")
+	require.Nil(t, err)
+	require.Equal(t, MultilineExpanded, mode)
+	_, err = ParseMultilineMode("folded")
+	require.Error(t, err)
+}
+
+func TestConsoleWriterNested(t *testing.T) {
+	line := []byte(`{"level":"info","message":"req","req":{"method":"GET","header":{"host":"example.com","agent":"go client"}},` +
+		`"ids":[1,2],"empty":{},"n":1}`)
+	write := func(opts ...ConsoleWriterOption) string {
+		out := &bytes.Buffer{}
+		w := NewConsoleWriter(append([]ConsoleWriterOption{EnableConsoleWriter(), WithColorMode(ColorNever)}, opts...)...)
+		w.PartsOrder = []string{zerolog.LevelFieldName, zerolog.MessageFieldName}
+		w.Out = out
+		_, err := w.Write(line)
+		require.Nil(t, err)
+		return out.String()
+	}
+
+	require.Equal(t, `INF req empty={} ids=[1,2] n=1 req={"header":{"agent":"go client","host":"example.com"},"method":"GET"}`+"\n",
+		write())
+	require.Equal(t, `INF req empty={} ids.0=1 ids.1=2 n=1 req.method=GET req.header.host=example.com req.header.agent="go client"`+"\n",
+		write(WithNested(NestedFlatten, 0)))
+	require.Equal(t, `INF req empty={} ids.0=1 ids.1=2 n=1 req.method=GET req.header={"host":"example.com","agent":"go client"}`+"\n",
+		write(WithNested(NestedFlatten, 1)))
+	require.Equal(t, "INF req empty={} n=1\n"+
+		"    ids:\n"+
+		"      0: 1\n"+
+		"      1: 2\n"+
+		"    req:\n"+
+		"      method: GET\n"+
+		"      header:\n"+
+		"        host: example.com\n"+
+		"        agent: \"go client\"\n",
+		write(WithNested(NestedTree, 0)))
+	require.Equal(t, "INF req empty={} n=1\n"+
+		"    ids:\n"+
+		"      0: 1\n"+
+		"      1: 2\n"+
+		"    req:\n"+
+		"      method: GET\n"+
+		"      header: {\"host\":\"example.com\",\"agent\":\"go client\"}\n",
+		write(WithNested(NestedTree, 1)))
+
+	mode, err := ParseNestedMode("tree")
+	require.Nil(t, err)
+	require.Equal(t, NestedTree, mode)
+	_, err = ParseNestedMode("yaml")
+	require.Error(t, err)
+}
+
+func TestConsoleWriterCaller(t *testing.T) {
+	cwd, err := os.Getwd()
+	require.Nil(t, err)
+	caller := filepath.Join(cwd, "a", "b", "c.go") + ":12"
+	require.Equal(t, "b/c.go:12", formatCallerPath(caller, CallerShort))
+	require.Equal(t, "a/b/c.go:12", formatCallerPath(caller, CallerRelative))
+	require.Equal(t, caller, formatCallerPath(caller, CallerFull))
+	require.Equal(t, "/elsewhere/c.go:1", formatCallerPath("/elsewhere/c.go:1", CallerRelative))
+
+	write := func(line string, opts ...ConsoleWriterOption) string {
+		out := &bytes.Buffer{}
+		w := NewConsoleWriter(append([]ConsoleWriterOption{EnableConsoleWriter(), WithColorMode(ColorNever)}, opts...)...)
+		w.PartsOrder = []string{zerolog.CallerFieldName, zerolog.MessageFieldName}
+		w.Out = out
+		_, err := w.Write([]byte(line))
+		require.Nil(t, err)
+		return out.String()
+	}
+	line := `{"caller":"` + caller + `","caller_func":"b.Serve","message":"hi"}`
+	require.Equal(t, "b/c.go:12 b.Serve > hi\n", write(line))
+	require.Equal(t, "a/b/c.go:12 b.Serve > hi\n", write(line, WithCallerFormat(CallerRelative)))
+	// links are printed only if the colors are enabled
+	require.Equal(t, "b/c.go:12 b.Serve > hi\n", write(line, WithCallerLinks("")))
+
+	linked := write(line, WithColorMode(ColorAlways), WithTheme(&Theme{}), WithCallerLinks("vscode://file/{path}:{line}"))
+	require.Equal(t, "\x1b]8;;vscode://file/"+filepath.ToSlash(cwd)+"/a/b/c.go:12\x1b\\b/c.go:12\x1b]8;;\x1b\\ b.Serve > hi\n", linked)
+	require.Equal(t, len("b/c.go:12 b.Serve > hi\n"), visibleWidth([]byte(linked)))
+
+	out := &bytes.Buffer{}
+	cfg := defaultConfig()
+	cfg.apply(WithCallerFunc(), WithConsoleColorMode(ColorNever), WithTextCallerFormat(CallerRelative))
+	cfg.cw.Out = out
+	logger := newRZeroLogger(cfg)
+	logger.Info().Msg("hi")
+	logger.GetLabeledSubLogger("sub").Warn().Msgf("%s", "there")
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2)
+	require.Contains(t, lines[0], " INF console_writer_test.go:")
+	require.Contains(t, lines[0], " rzerolog.TestConsoleWriterCaller > hi")
+	require.Contains(t, lines[1], " WRN sub console_writer_test.go:")
+	require.Contains(t, lines[1], " rzerolog.TestConsoleWriterCaller > there")
+}
+
+type flushBuffer struct {
+	bytes.Buffer
+	dst     *bytes.Buffer
+	flushes int
+}
+
+func (b *flushBuffer) Flush() error {
+	b.flushes++
+	_, err := b.WriteTo(b.dst)
+	return err
+}
+
+func TestConsoleWriterErrOutput(t *testing.T) {
+	merged := &bytes.Buffer{}
+	out := &flushBuffer{dst: merged}
+	errOut := &flushBuffer{dst: merged}
+	w := NewConsoleWriter(EnableConsoleWriter(), WithColorMode(ColorNever), WithErrOutput(errOut, WarnLevel))
+	w.PartsOrder = []string{zerolog.LevelFieldName, zerolog.MessageFieldName}
+	w.Out = out
+	for _, line := range []string{
+		`{"level":"info","message":"1"}`,
+		`{"level":"debug","message":"2"}`,
+		`{"level":"warn","message":"3"}`,
+		`{"level":"error","message":"4"}`,
+		`{"message":"5"}`,
+	} {
+		_, err := w.Write([]byte(line))
+		require.Nil(t, err)
+	}
+	require.Nil(t, out.Flush())
+	// the buffered streams are flushed when switched, so the order is kept
+	require.Equal(t, "INF 1\nDBG 2\nWRN 3\nERR 4\n??? 5\n", merged.String())
+	require.Equal(t, 1, errOut.flushes)
+
+	// colors are resolved for each stream
+	cOut, cErr := &bytes.Buffer{}, &bytes.Buffer{}
+	w = NewConsoleWriter(EnableConsoleWriter(), WithErrOutput(cErr, ErrorLevel))
+	w.PartsOrder = []string{zerolog.LevelFieldName}
+	w.Out = cOut
+	w.errColorState = colorStateOn
+	_, _ = w.Write([]byte(`{"level":"info"}`))
+	_, _ = w.Write([]byte(`{"level":"error"}`))
+	require.Equal(t, "INF\n", cOut.String())
+	require.Equal(t, "\x1b[1m\x1b[31mERR\x1b[0m\x1b[0m\n", cErr.String())
+
+	w = NewConsoleWriter(WithErrOutput(nil, WarnLevel))
+	require.Equal(t, os.Stderr, w.ErrOut)
+	l, err := ParseLevel("WARN")
+	require.Nil(t, err)
+	require.Equal(t, WarnLevel, l)
+}
+
+func TestConsoleWriterTemplate(t *testing.T) {
+	line := []byte(`{"level":"warn","label":"p2p","time":"2022-02-11 16:04:05","caller":"/src/p2p/host.go:12",` +
+		`"message":"slow peer","peer":"n1","rtt":120}`)
+	write := func(line []byte, layout string, opts ...ConsoleWriterOption) string {
+		tmpl, err := ParseTextTemplate(layout)
+		require.Nil(t, err)
+		out := &bytes.Buffer{}
+		w := NewConsoleWriter(append([]ConsoleWriterOption{EnableConsoleWriter(), WithColorMode(ColorNever), WithTemplate(tmpl)}, opts...)...)
+		w.Out = out
+		_, err = w.Write(line)
+		require.Nil(t, err)
+		return out.String()
+	}
+
+	// rendered directly
+	layout := `{{.Time}} {{.Level}} [{{.Label}}] {{.Caller}} - {{.Message}} | {{.Fields}}`
+	tmpl, err := ParseTextTemplate(layout)
+	require.Nil(t, err)
+	require.NotNil(t, tmpl.segments)
+	require.Equal(t, layout, tmpl.String())
+	require.Equal(t, "2022-02-11 16:04:05 WRN [p2p] p2p/host.go:12 - slow peer | peer=n1 rtt=120\n", write(line, layout))
+	require.Equal(t, "WRN  | peer=n1 rtt=120\n",
+		write([]byte(`{"level":"warn","peer":"n1","rtt":120}`), `{{.Level}} {{.Message}} | {{.Fields}}`))
+
+	// executed by text/template
+	layout = `{{.Level}}{{with .Label}} <{{.}}>{{end}} {{printf "%q" .Message}}{{if .Fields}} {{.Fields}}{{end}}`
+	tmpl, err = ParseTextTemplate(layout)
+	require.Nil(t, err)
+	require.Nil(t, tmpl.segments)
+	require.Equal(t, `WRN <p2p> "slow peer" peer=n1 rtt=120`+"\n", write(line, layout))
+	require.Equal(t, `INF "done"`+"\n", write([]byte(`{"level":"info","message":"done"}`), layout))
+
+	// the blocks follow the line
+	require.Equal(t, "ERR failed\n    retrying\n    error: refused\n           timeout\n",
+		write([]byte(`{"level":"error","message":"failed\nretrying","error":"refused\ntimeout"}`),
+			`{{.Level}} {{.Message}}{{.Fields}}`, WithMultiline(MultilineExpanded)))
+	// the continuation lines are aligned with the message rendered by text/template
+	require.Equal(t, "ERR <p2p> \"failed\"\n           retrying\n    error: refused\n           timeout\n",
+		write([]byte(`{"level":"error","label":"p2p","message":"failed\nretrying","error":"refused\ntimeout"}`),
+			`{{.Level}}{{with .Label}} <{{.}}>{{end}} {{printf "%q" .Message}}{{.Fields}}`, WithMultiline(MultilineExpanded)))
+
+	for _, layout := range []string{`{{.Level`, `{{.Host}}`, `{{range .Fields}}{{end}}`, `{{template "x"}}`} {
+		_, err = ParseTextTemplate(layout)
+		require.Error(t, err, layout)
+	}
+	require.Panics(t, func() { WithTextTemplate(`{{.Host}}`) })
+
+	cfg := defaultConfig()
+	cfg.apply(WithTextTemplate(`{{.Level}} {{.Message}}`))
+	require.Len(t, cfg.textOpts, 1)
+}
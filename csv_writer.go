@@ -0,0 +1,154 @@
+package rzerolog
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync/atomic"
+
+	"github.com/rs/zerolog"
+)
+
+var (
+	_ io.Writer  = (*CSVWriter)(nil)
+	_ FileWriter = (*CSVWriter)(nil)
+)
+
+// DefaultCSVColumns is the columns of CSV output if they are not set.
+var DefaultCSVColumns = []string{zerolog.TimestampFieldName, zerolog.LevelFieldName, LabelFieldName, zerolog.MessageFieldName}
+
+// CSVWriter parses the JSON input and writes the fields of Columns as a row of CSV, or TSV if Comma is '\t'.
+// A header row of the column names is written first, and at the start of every new file after rotation.
+//
+// The values are quoted as RFC 4180 if they contain the delimiter, quotes or newlines,
+// the absent fields and null are empty, and objects and arrays are written as JSON.
+// The strings starting with '=', '+', '-', '@', a tab or a carriage return are prefixed with "'",
+// so that spreadsheets do not evaluate them as formulas.
+type CSVWriter struct {
+	Enable bool
+	// Out is the output destination.
+	Out io.Writer
+	// Comma is the field delimiter, ',' if it is 0.
+	Comma byte
+	// Columns is the field names of columns, DefaultCSVColumns if it is empty.
+	Columns []string
+
+	// header is 1 if the header row is written before the next row
+	header uint32
+}
+
+// NewCSVWriter creates a CSVWriter writing to out with the delimiter comma, the header row is written before the first row.
+func NewCSVWriter(out io.Writer, comma byte, columns ...string) *CSVWriter {
+	return &CSVWriter{Enable: true, Out: out, Comma: comma, Columns: columns, header: 1}
+}
+
+// SetOutput set the output, the header row is written before the next row if it is a new or empty file,
+// which is decided by the file under the storage wrappers, such as a gzip stream appended to a file.
+func (w *CSVWriter) SetOutput(out io.WriteCloser) error {
+	w.Out = out
+	if isNewFile(out) {
+		atomic.StoreUint32(&w.header, 1)
+	} else {
+		atomic.StoreUint32(&w.header, 0)
+	}
+	return nil
+}
+
+// Write transforms the JSON input into a row and appends it to w.Out.
+func (w *CSVWriter) Write(p []byte) (n int, err error) {
+	if !w.Enable {
+		return len(p), nil
+	}
+
+	evt := getConsoleEvent()
+	defer evt.release()
+
+	if err = evt.parse(p); err != nil {
+		return n, fmt.Errorf("cannot decode event: %s", err)
+	}
+	comma, columns := w.delimiter(), w.columns()
+	header := atomic.CompareAndSwapUint32(&w.header, 1, 0)
+	if header {
+		for i, col := range columns {
+			if i > 0 {
+				evt.buf = append(evt.buf, comma)
+			}
+			evt.buf = appendCSVField(evt.buf, []byte(col), comma)
+		}
+		evt.buf = append(evt.buf, '\n')
+	}
+	// formula is the value prefixed to be shown as text
+	var formula []byte
+	for i, col := range columns {
+		if i > 0 {
+			evt.buf = append(evt.buf, comma)
+		}
+		if f, ok := evt.field(col); ok && f.kind != jsonNull {
+			value := f.value
+			if f.kind == jsonString && isCSVFormula(value) {
+				formula = append(append(formula[:0], '\''), value...)
+				value = formula
+			}
+			evt.buf = appendCSVField(evt.buf, value, comma)
+		}
+	}
+	evt.buf = append(evt.buf, '\n')
+	if _, err = w.Out.Write(evt.buf); err != nil && header {
+		// the header is written with the next row
+		atomic.StoreUint32(&w.header, 1)
+	}
+	return len(p), err
+}
+
+// isCSVFormula reports whether a spreadsheet evaluates value as a formula.
+func isCSVFormula(value []byte) bool {
+	if len(value) == 0 {
+		return false
+	}
+	switch value[0] {
+	case '=', '+', '-', '@', '\t', '\r':
+		return true
+	}
+	return false
+}
+
+func (w *CSVWriter) delimiter() byte {
+	if w.Comma == 0 {
+		return ','
+	}
+	return w.Comma
+}
+
+func (w *CSVWriter) columns() []string {
+	if len(w.Columns) == 0 {
+		return DefaultCSVColumns
+	}
+	return w.Columns
+}
+
+// appendCSVField appends the value, quoted if it contains the delimiter, quotes or newlines,
+// or starts with a space, as encoding/csv does.
+func appendCSVField(dst, value []byte, comma byte) []byte {
+	needsQuote := len(value) > 0 && (value[0] == ' ' || value[0] == '\t')
+	for _, c := range value {
+		if c == comma || c == '"' || c == '\r' || c == '\n' {
+			needsQuote = true
+			break
+		}
+	}
+	if !needsQuote {
+		return append(dst, value...)
+	}
+	dst = append(dst, '"')
+	for {
+		i := bytes.IndexByte(value, '"')
+		if i < 0 {
+			break
+		}
+		dst = append(dst, value[:i+1]...)
+		dst = append(dst, '"')
+		value = value[i+1:]
+	}
+	dst = append(dst, value...)
+	return append(dst, '"')
+}
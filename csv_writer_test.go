@@ -0,0 +1,121 @@
+package rzerolog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCSVWriter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := NewCSVWriter(buf, 0)
+	for _, line := range []string{
+		`{"level":"info","label":"p2p","time":"2022-02-11 16:04:05","message":"a, \"b\"\nc"}`,
+		`{"level":"warn","message":" padded","label":null}`,
+	} {
+		_, err := w.Write([]byte(line))
+		require.Nil(t, err)
+	}
+	require.Equal(t, "time,level,label,message\n"+
+		"2022-02-11 16:04:05,info,p2p,\"a, \"\"b\"\"\nc\"\n"+
+		",warn,,\" padded\"\n", buf.String())
+	records, err := csv.NewReader(bytes.NewReader(buf.Bytes())).ReadAll()
+	require.Nil(t, err)
+	require.Equal(t, []string{"2022-02-11 16:04:05", "info", "p2p", "a, \"b\"\nc"}, records[1])
+
+	buf.Reset()
+	w = NewCSVWriter(buf, '\t', "level", "n", "tags", "note")
+	_, err = w.Write([]byte(`{"level":"info","n":1.5,"tags":["x","y"],"note":"a\tb, c"}`))
+	require.Nil(t, err)
+	require.Equal(t, "level\tn\ttags\tnote\ninfo\t1.5\t\"[\"\"x\"\",\"\"y\"\"]\"\t\"a\tb, c\"\n", buf.String())
+
+	_, err = w.Write([]byte(`{"level":`))
+	require.Error(t, err)
+
+	// the formulas are written as text, the numbers are not
+	buf.Reset()
+	w = NewCSVWriter(buf, 0, "a", "b", "c", "d", "n")
+	_, err = w.Write([]byte(`{"a":"=1+2","b":"+x","c":"@SUM(A1)","d":"-2, \"x\"","n":-2}`))
+	require.Nil(t, err)
+	require.Equal(t, "a,b,c,d,n\n'=1+2,'+x,'@SUM(A1),\"'-2, \"\"x\"\"\",-2\n", buf.String())
+
+	// the header is written again if the row is not
+	fw := &failWriter{}
+	w = NewCSVWriter(fw, 0, "level")
+	fw.err = errors.New("disk full")
+	_, err = w.Write([]byte(`{"level":"info"}`))
+	require.Error(t, err)
+	fw.err = nil
+	_, err = w.Write([]byte(`{"level":"warn"}`))
+	require.Nil(t, err)
+	require.Equal(t, "level\nwarn\n", fw.buf.String())
+}
+
+// failWriter fails the writes while err is set.
+type failWriter struct {
+	buf bytes.Buffer
+	err error
+}
+
+func (w *failWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	return w.buf.Write(p)
+}
+
+func TestCSVLogFile(t *testing.T) {
+	fs := NewMemFileSystem()
+	w := newMemLogFileWriter(t, fs, WithLogFormat(LogFormatTSV), WithSizeRolling(1, 3))
+	w.fileSize = 80
+
+	for _, msg := range []string{"first", "second", "third"} {
+		_, err := w.Write([]byte(`{"level":"info","message":"` + msg + `"}`))
+		require.Nil(t, err)
+	}
+	require.Nil(t, w.Close())
+	// the header is not repeated in the reopened file
+	_, err := w.Write([]byte(`{"level":"warn","message":"fourth"}`))
+	require.Nil(t, err)
+	require.Nil(t, w.Close())
+
+	b, err := fs.ReadFile("logs/app.log.2")
+	require.Nil(t, err)
+	require.Equal(t, "time\tlevel\tlabel\tmessage\n\tinfo\t\tfirst\n\tinfo\t\tsecond\n", string(b))
+	b, err = fs.ReadFile("logs/app.log.1")
+	require.Nil(t, err)
+	require.Equal(t, "time\tlevel\tlabel\tmessage\n\tinfo\t\tthird\n\twarn\t\tfourth\n", string(b))
+	// the header is written with the first row, not in the files without rows
+	b, err = fs.ReadFile("logs/app.log")
+	require.Nil(t, err)
+	require.Empty(t, b)
+
+	// the header is not repeated in the gzip member appended to the reopened file
+	w = newMemLogFileWriter(t, fs, WithLogFileName("app.csv.gz"), WithLogFormat(LogFormatCSV),
+		WithStorageWrapper(GzipStorage(gzip.BestSpeed)))
+	for _, msg := range []string{"first", "second"} {
+		_, err = w.Write([]byte(`{"level":"info","message":"` + msg + `"}`))
+		require.Nil(t, err)
+		require.Nil(t, w.Close())
+	}
+	b, err = fs.ReadFile("logs/app.csv.gz")
+	require.Nil(t, err)
+	zr, err := gzip.NewReader(bytes.NewReader(b))
+	require.Nil(t, err)
+	b, err = ioutil.ReadAll(zr)
+	require.Nil(t, err)
+	require.Equal(t, "time,level,label,message\n,info,,first\n,info,,second\n", string(b))
+
+	cfg := defaultConfig()
+	cfg.apply(WithLogFormat(LogFormatCSV), WithCSVColumns("level", "peer_id"))
+	cfg.applyTextOptions()
+	cw, ok := cfg.fw.writer.(*CSVWriter)
+	require.True(t, ok)
+	require.Equal(t, byte(0), cw.Comma)
+	require.Equal(t, []string{"level", "peer_id"}, cw.Columns)
+}
@@ -0,0 +1,321 @@
+package rzerolog
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+	"unicode/utf8"
+
+	"github.com/rs/zerolog"
+)
+
+var (
+	_ io.Writer  = (*ECSWriter)(nil)
+	_ FileWriter = (*ECSWriter)(nil)
+)
+
+// ECSVersion is the version of Elastic Common Schema written in "ecs.version".
+const ECSVersion = "1.6.0"
+
+// ecsTimeFormat is the format of "@timestamp", ISO 8601 with milliseconds and the zone offset.
+const ecsTimeFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// ECSWriter parses the JSON input and writes it as the JSON of Elastic Common Schema to Out,
+// which can be ingested by Elasticsearch and Filebeat without ingest pipelines:
+//
+//	time    => @timestamp
+//	level   => log.level
+//	label   => log.logger
+//	caller  => log.origin.file.name, log.origin.file.line
+//	caller_func => log.origin.function
+//	error   => error.message
+//	stack   => error.stack_trace
+//
+// The message is kept as "message", the other fields are kept at the top level in the order they are added.
+// The fields named as the ECS objects above, such as "log", "log.level" and "ecs", or "fields",
+// are written in the "fields" object instead, as Filebeat does with the custom fields.
+type ECSWriter struct {
+	Enable bool
+	// Out is the output destination.
+	Out io.Writer
+	// TimeFormat is the layout of the input time, DefaultTimeFormat if it is empty.
+	// The time is parsed in Location, and written as it is if it cannot be parsed.
+	TimeFormat string
+	// Location is the time zone of the input time, time.Local if it is nil.
+	Location *time.Location
+}
+
+// NewECSWriter creates an ECSWriter writing to out.
+func NewECSWriter(out io.Writer) *ECSWriter {
+	return &ECSWriter{Enable: true, Out: out}
+}
+
+func (w *ECSWriter) SetOutput(out io.WriteCloser) error {
+	w.Out = out
+	return nil
+}
+
+// Write transforms the JSON input into an ECS record and appends it to w.Out.
+func (w *ECSWriter) Write(p []byte) (n int, err error) {
+	if !w.Enable {
+		return len(p), nil
+	}
+
+	evt := getConsoleEvent()
+	defer evt.release()
+
+	if err = evt.parse(p); err != nil {
+		return n, fmt.Errorf("cannot decode event: %s", err)
+	}
+	w.appendEvent(evt)
+	evt.buf = append(evt.buf, '\n')
+	_, err = w.Out.Write(evt.buf)
+	return len(p), err
+}
+
+// appendEvent appends the ECS record of evt to evt.buf.
+func (w *ECSWriter) appendEvent(evt *consoleEvent) {
+	evt.buf = append(evt.buf, '{')
+	if f, ok := evt.field(zerolog.TimestampFieldName); ok {
+		evt.buf = append(evt.buf, `"@timestamp":`...)
+		evt.buf = w.appendTimestamp(evt.buf, f)
+		evt.buf = append(evt.buf, ',')
+	}
+	evt.buf = w.appendLog(evt)
+	if f, ok := evt.field(zerolog.MessageFieldName); ok {
+		evt.buf = append(evt.buf, `"message":`...)
+		evt.buf = appendECSValue(evt.buf, f)
+		evt.buf = append(evt.buf, ',')
+	}
+	evt.buf = w.appendError(evt)
+	evt.buf = append(evt.buf, `"ecs":{"version":"`+ECSVersion+`"}`...)
+
+	for i := range evt.fields {
+		if isECSMappedKey(evt.fields[i].key) {
+			continue
+		}
+		evt.order = append(evt.order, i)
+	}
+	evt.dedupOrder()
+	colliding := 0
+	for _, idx := range evt.order {
+		f := &evt.fields[idx]
+		if isECSReservedKey(f.key) {
+			colliding++
+			continue
+		}
+		evt.buf = append(evt.buf, ',')
+		evt.buf = appendJSONString(evt.buf, f.key)
+		evt.buf = append(evt.buf, ':')
+		evt.buf = appendECSValue(evt.buf, f)
+	}
+	if colliding > 0 {
+		evt.buf = append(evt.buf, `,"fields":{`...)
+		sep := false
+		for _, idx := range evt.order {
+			f := &evt.fields[idx]
+			if !isECSReservedKey(f.key) {
+				continue
+			}
+			if sep {
+				evt.buf = append(evt.buf, ',')
+			}
+			evt.buf = appendJSONString(evt.buf, f.key)
+			evt.buf = append(evt.buf, ':')
+			evt.buf = appendECSValue(evt.buf, f)
+			sep = true
+		}
+		evt.buf = append(evt.buf, '}')
+	}
+
+	evt.buf = append(evt.buf, '}')
+}
+
+// appendTimestamp appends the time f in ecsTimeFormat.
+func (w *ECSWriter) appendTimestamp(dst []byte, f *eventField) []byte {
+	if f.kind != jsonString {
+		return append(dst, f.value...)
+	}
+	layout, loc := w.TimeFormat, w.Location
+	if layout == "" {
+		layout = DefaultTimeFormat
+	}
+	if loc == nil {
+		loc = time.Local
+	}
+	t, err := time.ParseInLocation(layout, string(f.value), loc)
+	if err != nil {
+		return appendJSONString(dst, f.value)
+	}
+	dst = append(dst, '"')
+	dst = t.AppendFormat(dst, ecsTimeFormat)
+	return append(dst, '"')
+}
+
+// appendLog appends the "log" object with the level, label and caller of evt.
+func (w *ECSWriter) appendLog(evt *consoleEvent) []byte {
+	dst := evt.buf
+	level, hasLevel := evt.field(zerolog.LevelFieldName)
+	label, hasLabel := evt.field(LabelFieldName)
+	caller, hasCaller := evt.field(zerolog.CallerFieldName)
+	fn, hasFunc := evt.field(CallerFuncFieldName)
+	if !hasLevel && !hasLabel && !hasCaller && !hasFunc {
+		return dst
+	}
+
+	dst = append(dst, `"log":{`...)
+	sep := false
+	if hasLevel {
+		dst = append(dst, `"level":`...)
+		dst = appendECSValue(dst, level)
+		sep = true
+	}
+	if hasLabel {
+		if sep {
+			dst = append(dst, ',')
+		}
+		dst = append(dst, `"logger":`...)
+		dst = appendECSValue(dst, label)
+		sep = true
+	}
+	if hasCaller || hasFunc {
+		if sep {
+			dst = append(dst, ',')
+		}
+		dst = append(dst, `"origin":{`...)
+		if hasCaller {
+			file, line := caller.value, []byte(nil)
+			if i := bytes.LastIndexByte(caller.value, ':'); i > 0 && isDigits(caller.value[i+1:]) {
+				file, line = caller.value[:i], caller.value[i+1:]
+			}
+			dst = append(dst, `"file":{"name":`...)
+			dst = appendJSONString(dst, file)
+			if line != nil {
+				dst = append(dst, `,"line":`...)
+				dst = append(dst, line...)
+			}
+			dst = append(dst, '}')
+		}
+		if hasFunc {
+			if hasCaller {
+				dst = append(dst, ',')
+			}
+			dst = append(dst, `"function":`...)
+			dst = appendECSValue(dst, fn)
+		}
+		dst = append(dst, '}')
+	}
+	return append(dst, '}', ',')
+}
+
+// appendError appends the "error" object with the error and the stack of evt.
+func (w *ECSWriter) appendError(evt *consoleEvent) []byte {
+	dst := evt.buf
+	msg, hasMsg := evt.field(zerolog.ErrorFieldName)
+	stack, hasStack := evt.field(zerolog.ErrorStackFieldName)
+	if !hasMsg && !hasStack {
+		return dst
+	}
+	dst = append(dst, `"error":{`...)
+	if hasMsg {
+		// error.message is text, the errors marshaled as objects are kept as their JSON
+		dst = append(dst, `"message":`...)
+		dst = appendJSONString(dst, msg.value)
+	}
+	if hasStack {
+		if hasMsg {
+			dst = append(dst, ',')
+		}
+		dst = append(dst, `"stack_trace":`...)
+		dst = appendJSONString(dst, stack.value)
+	}
+	return append(dst, '}', ',')
+}
+
+func isECSMappedKey(key []byte) bool {
+	switch string(key) {
+	case zerolog.TimestampFieldName,
+		zerolog.LevelFieldName,
+		LabelFieldName,
+		zerolog.CallerFieldName,
+		CallerFuncFieldName,
+		zerolog.MessageFieldName,
+		zerolog.ErrorFieldName,
+		zerolog.ErrorStackFieldName:
+		return true
+	}
+	return false
+}
+
+// isECSReservedKey reports whether the field key would collide with the top level keys of the record.
+func isECSReservedKey(key []byte) bool {
+	name := key
+	if i := bytes.IndexByte(key, '.'); i >= 0 {
+		name = key[:i]
+	}
+	switch string(name) {
+	case "log", "error", "ecs", "fields":
+		return true
+	}
+	switch string(key) {
+	case "@timestamp", "message":
+		return true
+	}
+	return false
+}
+
+func isDigits(b []byte) bool {
+	if len(b) == 0 {
+		return false
+	}
+	for _, c := range b {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// appendECSValue appends the value of f as JSON.
+func appendECSValue(dst []byte, f *eventField) []byte {
+	if f.kind == jsonString {
+		return appendJSONString(dst, f.value)
+	}
+	return append(dst, f.value...)
+}
+
+// appendJSONString appends s as a JSON string, the invalid UTF-8 is replaced by U+FFFD.
+func appendJSONString(dst, s []byte) []byte {
+	dst = append(dst, '"')
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			switch {
+			case c == '"' || c == '\\':
+				dst = append(dst, '\\', c)
+			case c == '\n':
+				dst = append(dst, `\n`...)
+			case c == '\r':
+				dst = append(dst, `\r`...)
+			case c == '\t':
+				dst = append(dst, `\t`...)
+			case c < 0x20:
+				dst = append(dst, `\u00`...)
+				dst = append(dst, lowerhex[c>>4], lowerhex[c&0xF])
+			default:
+				dst = append(dst, c)
+			}
+			i++
+			continue
+		}
+		r, width := utf8.DecodeRune(s[i:])
+		if r == utf8.RuneError && width == 1 {
+			dst = append(dst, `\ufffd`...)
+		} else {
+			dst = append(dst, s[i:i+width]...)
+		}
+		i += width
+	}
+	return append(dst, '"')
+}
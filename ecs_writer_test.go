@@ -0,0 +1,66 @@
+package rzerolog
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestECSWriter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := NewECSWriter(buf)
+	w.Location = time.FixedZone("", 8*3600)
+	_, err := w.Write([]byte(`{"level":"error","label":"p2p","user":"bob","time":"2022-02-11 16:04:05.123",` +
+		`"caller":"/src/p2p/host.go:12","caller_func":"p2p.(*Host).Connect","error":"dial \"a\"\n",` +
+		`"stack":["a","b"],"message":"connect failed","n":1}`))
+	require.Nil(t, err)
+	require.Equal(t, `{"@timestamp":"2022-02-11T16:04:05.123+08:00",`+
+		`"log":{"level":"error","logger":"p2p","origin":{"file":{"name":"/src/p2p/host.go","line":12},"function":"p2p.(*Host).Connect"}},`+
+		`"message":"connect failed","error":{"message":"dial \"a\"\n","stack_trace":"[\"a\",\"b\"]"},`+
+		`"ecs":{"version":"1.6.0"},"user":"bob","n":1}`+"\n", buf.String())
+	require.True(t, json.Valid(buf.Bytes()))
+
+	// the unknown time layout is kept as it is
+	buf.Reset()
+	_, err = w.Write([]byte(`{"time":"yesterday","message":"x\u0001😀"}`))
+	require.Nil(t, err)
+	require.Equal(t, `{"@timestamp":"yesterday","message":"x\u0001😀","ecs":{"version":"1.6.0"}}`+"\n", buf.String())
+
+	for _, c := range []struct{ input, want string }{
+		// the caller without a line is the file name
+		{`{"caller":"main.go"}`, `{"log":{"origin":{"file":{"name":"main.go"}}},"ecs":{"version":"1.6.0"}}`},
+		{`{"caller":"c:/src/main.go:7"}`, `{"log":{"origin":{"file":{"name":"c:/src/main.go","line":7}}},"ecs":{"version":"1.6.0"}}`},
+		{`{"caller_func":"main.run"}`, `{"log":{"origin":{"function":"main.run"}},"ecs":{"version":"1.6.0"}}`},
+		{`{"level":"warn","caller_func":"main.run"}`,
+			`{"log":{"level":"warn","origin":{"function":"main.run"}},"ecs":{"version":"1.6.0"}}`},
+		// the errors marshaled as objects are kept as their JSON text
+		{`{"error":{"code":1}}`, `{"error":{"message":"{\"code\":1}"},"ecs":{"version":"1.6.0"}}`},
+		{`{"stack":"main.go:7\nmain.go:3"}`, `{"error":{"stack_trace":"main.go:7\nmain.go:3"},"ecs":{"version":"1.6.0"}}`},
+		// the fields colliding with the ECS objects are moved
+		{`{"message":"m","log":"x","ecs":1,"log.level":"y","fields":[1],"logs":2}`,
+			`{"message":"m","ecs":{"version":"1.6.0"},"logs":2,"fields":{"log":"x","ecs":1,"log.level":"y","fields":[1]}}`},
+	} {
+		buf.Reset()
+		_, err = w.Write([]byte(c.input))
+		require.Nil(t, err)
+		require.Equal(t, c.want+"\n", buf.String(), c.input)
+		require.True(t, json.Valid(buf.Bytes()))
+	}
+
+	// the renamed message field does not collide with "message"
+	defer func(name string) { zerolog.MessageFieldName = name }(zerolog.MessageFieldName)
+	zerolog.MessageFieldName = "msg"
+	buf.Reset()
+	_, err = w.Write([]byte(`{"msg":"m","message":"user","@timestamp":1}`))
+	require.Nil(t, err)
+	require.Equal(t, `{"message":"m","ecs":{"version":"1.6.0"},"fields":{"message":"user","@timestamp":1}}`+"\n", buf.String())
+
+	cfg := defaultConfig()
+	cfg.apply(WithLogFormat(LogFormatECS))
+	_, ok := cfg.fw.writer.(*ECSWriter)
+	require.True(t, ok)
+}
@@ -9,7 +9,8 @@ import (
 
 type Event struct {
 	*zerolog.Event
-	label string
+	label      string
+	callerFunc bool
 }
 
 // Msg sends the *Event with msg added as the message field if not empty.
@@ -17,22 +18,14 @@ type Event struct {
 // NOTICE: once this method is called, the *Event should be disposed.
 // Calling Msg twice can have unexpected result.
 func (e *Event) Msg(msg string) {
-	if e.label != "" {
-		e.Event.CallerSkipFrame(1).Str(LabelFieldName, e.label).Msg(msg)
-	} else {
-		e.Event.CallerSkipFrame(1).Msg(msg)
-	}
+	e.withContext().Msg(msg)
 }
 
 // Send is equivalent to calling Msg("").
 //
 // NOTICE: once this method is called, the *Event should be disposed.
 func (e *Event) Send() {
-	if e.label != "" {
-		e.Event.CallerSkipFrame(1).Str(LabelFieldName, e.label).Msg("")
-	} else {
-		e.Event.CallerSkipFrame(1).Msg("")
-	}
+	e.withContext().Msg("")
 }
 
 // Msgf sends the event with formatted msg added as the message field if not empty.
@@ -40,11 +33,21 @@ func (e *Event) Send() {
 // NOTICE: once this method is called, the *Event should be disposed.
 // Calling Msgf twice can have unexpected result.
 func (e *Event) Msgf(format string, v ...interface{}) {
+	e.withContext().Msg(fmt.Sprintf(format, v...))
+}
+
+// withContext adds the label and the caller function to the event to be sent.
+//
+// NOTE: It must be called by Msg, Send or Msgf directly, so that the caller is 3 frames above.
+func (e *Event) withContext() *zerolog.Event {
+	ev := e.Event.CallerSkipFrame(1)
 	if e.label != "" {
-		e.Event.CallerSkipFrame(1).Str(LabelFieldName, e.label).Msg(fmt.Sprintf(format, v...))
-	} else {
-		e.Event.CallerSkipFrame(1).Msg(fmt.Sprintf(format, v...))
+		ev = ev.Str(LabelFieldName, e.label)
+	}
+	if e.callerFunc && ev != nil {
+		ev = ev.Str(CallerFuncFieldName, callerFuncName(2))
 	}
+	return ev
 }
 
 // Discard disables the event so Msg(f) won't print it.
@@ -0,0 +1,50 @@
+package rzerolog
+
+import "fmt"
+
+// FieldOrder defines the order of fields in text output.
+type FieldOrder int
+
+const (
+	// FieldOrderAlphabetical sorts the fields by name, and the "error" field is printed first.
+	FieldOrderAlphabetical FieldOrder = iota
+	// FieldOrderInsertion keeps the order in which the fields are added to the event.
+	FieldOrderInsertion
+	// FieldOrderPriority prints the priority fields first in the given order,
+	// then the others in FieldOrderAlphabetical order.
+	FieldOrderPriority
+)
+
+const (
+	FieldOrderAlphabeticalName = "alphabetical"
+	FieldOrderInsertionName    = "insertion"
+	FieldOrderPriorityName     = "priority"
+)
+
+// String returns the name of the field order.
+func (o FieldOrder) String() string {
+	switch o {
+	case FieldOrderAlphabetical:
+		return FieldOrderAlphabeticalName
+	case FieldOrderInsertion:
+		return FieldOrderInsertionName
+	case FieldOrderPriority:
+		return FieldOrderPriorityName
+	}
+	return fmt.Sprintf("FieldOrder(%d)", int(o))
+}
+
+// ParseFieldOrder returns the field order with the given name.
+// An empty name means FieldOrderAlphabetical.
+func ParseFieldOrder(name string) (FieldOrder, error) {
+	switch name {
+	case "", FieldOrderAlphabeticalName:
+		return FieldOrderAlphabetical, nil
+	case FieldOrderInsertionName:
+		return FieldOrderInsertion, nil
+	case FieldOrderPriorityName:
+		return FieldOrderPriority, nil
+	}
+	return 0, fmt.Errorf("unsupported field order %q. supporting: %s %s %s", name,
+		FieldOrderAlphabeticalName, FieldOrderInsertionName, FieldOrderPriorityName)
+}
@@ -0,0 +1,108 @@
+package rzerolog
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"strconv"
+)
+
+var (
+	_ FileSystem     = OSFileSystem{}
+	_ PermFileSystem = OSFileSystem{}
+	_ File           = (*os.File)(nil)
+)
+
+// File is a log file opened by a FileSystem.
+type File interface {
+	io.Writer
+	io.Closer
+	// Name returns the name of the file as presented to OpenFile.
+	Name() string
+	// Stat returns the FileInfo describing the file.
+	Stat() (os.FileInfo, error)
+	// Sync commits the written content to the stable storage.
+	Sync() error
+}
+
+// FileSystem is the file system which log files are written to.
+type FileSystem interface {
+	// OpenFile opens the named file with specified flag and perm, as os.OpenFile.
+	OpenFile(name string, flag int, perm os.FileMode) (File, error)
+	// Rename renames oldpath to newpath, as os.Rename.
+	Rename(oldpath, newpath string) error
+	// Remove removes the named file, as os.Remove.
+	Remove(name string) error
+	// Stat returns the FileInfo describing the named file, as os.Stat.
+	Stat(name string) (os.FileInfo, error)
+	// MkdirAll creates a directory and all parents, as os.MkdirAll.
+	MkdirAll(path string, perm os.FileMode) error
+}
+
+// PermFileSystem is a FileSystem which can change the permission and group of files,
+// which is required by WithLogFileMode, WithLogDirMode and WithLogFileGroup.
+type PermFileSystem interface {
+	FileSystem
+	// Chmod changes the mode of the named file, as os.Chmod.
+	Chmod(name string, mode os.FileMode) error
+	// Chown changes the numeric uid and gid of the named file, as os.Chown.
+	// A uid or gid of -1 means to not change that value.
+	Chown(name string, uid, gid int) error
+}
+
+// OSFileSystem is the FileSystem of the operating system.
+type OSFileSystem struct{}
+
+func (OSFileSystem) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	f, err := os.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (OSFileSystem) Rename(oldpath, newpath string) error {
+	return os.Rename(oldpath, newpath)
+}
+
+func (OSFileSystem) Remove(name string) error {
+	return os.Remove(name)
+}
+
+func (OSFileSystem) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (OSFileSystem) MkdirAll(path string, perm os.FileMode) error {
+	return os.MkdirAll(path, perm)
+}
+
+func (OSFileSystem) Chmod(name string, mode os.FileMode) error {
+	return os.Chmod(name, mode)
+}
+
+func (OSFileSystem) Chown(name string, uid, gid int) error {
+	return os.Chown(name, uid, gid)
+}
+
+// ParseFileMode parses an octal permission string such as "0640".
+func ParseFileMode(s string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, fmt.Errorf("invalid file mode %q: an octal permission such as \"0640\" is expected", s)
+	}
+	return os.FileMode(mode), nil
+}
+
+// LookupGroupID returns the numeric id of the group given by name or id.
+func LookupGroupID(group string) (int, error) {
+	if gid, err := strconv.Atoi(group); err == nil {
+		return gid, nil
+	}
+	g, err := user.LookupGroup(group)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(g.Gid)
+}
@@ -1,7 +1,10 @@
 package rzerolog
 
 import (
+	"fmt"
+	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -24,7 +27,21 @@ const (
 )
 
 // ParseTimeFormat parse time format string.
+//
+// NOTE: Every occurrence of the tokens is replaced, even inside the literal text.
+// Use FileNameTemplate with braced tokens to avoid it.
 func ParseTimeFormat(format string) string {
+	return parseTimeFormat(format, time.Now())
+}
+
+func parseTimeFormat(format string, now time.Time) string {
+	dir, file := filepath.Split(legacyTimeLayout(format))
+	file = now.Format(file)
+	return filepath.Join(dir, file)
+}
+
+// legacyTimeLayout converts the letter tokens of format into a golang time layout.
+func legacyTimeLayout(format string) string {
 	dir, file := filepath.Split(format)
 	file = strings.ReplaceAll(file, year, yearT)
 	file = strings.ReplaceAll(file, month, monthT)
@@ -33,6 +50,262 @@ func ParseTimeFormat(format string) string {
 	file = strings.ReplaceAll(file, minute, minuteT)
 	file = strings.ReplaceAll(file, microsecond, microsecondT)
 	file = strings.ReplaceAll(file, second, secondT)
-	file = time.Now().Format(file)
 	return filepath.Join(dir, file)
 }
+
+// tokenKind identifies a file name template token.
+type tokenKind int
+
+const (
+	tokenLiteral tokenKind = iota
+	tokenYear
+	tokenYearShort
+	tokenMonth
+	tokenDay
+	tokenHour
+	tokenMinute
+	tokenSecond
+	tokenMillisecond
+	tokenDayOfYear
+	tokenISOWeek
+	tokenISOYear
+	tokenPID
+	tokenHost
+	tokenLabel
+	tokenUTC
+	tokenLocal
+)
+
+var fileNameTokens = map[string]tokenKind{
+	"yyyy":  tokenYear,
+	"yy":    tokenYearShort,
+	"MM":    tokenMonth,
+	"dd":    tokenDay,
+	"HH":    tokenHour,
+	"mm":    tokenMinute,
+	"ss":    tokenSecond,
+	"sss":   tokenMillisecond,
+	"DDD":   tokenDayOfYear,
+	"ww":    tokenISOWeek,
+	"GGGG":  tokenISOYear,
+	"pid":   tokenPID,
+	"host":  tokenHost,
+	"label": tokenLabel,
+	"utc":   tokenUTC,
+	"local": tokenLocal,
+}
+
+type nameToken struct {
+	kind tokenKind
+	text string
+}
+
+// FileNameTemplate is a compiled log file name.
+//
+// A template is a file name with tokens enclosed in braces:
+//
+//	{yyyy} {yy}  - year
+//	{MM}         - month
+//	{dd}         - day of month
+//	{HH}         - hour
+//	{mm}         - minute
+//	{ss}         - second
+//	{sss}        - millisecond
+//	{DDD}        - day of year
+//	{ww}         - ISO 8601 week number
+//	{GGGG}       - ISO 8601 week-numbering year
+//	{pid}        - process id
+//	{host}       - host name
+//	{label}      - label of the root logger, the sub loggers share its files
+//	{utc}        - render time tokens in UTC, prints nothing
+//	{local}      - render time tokens in local time, prints nothing (default)
+//
+// Literal braces are written as "{{" and "}}".
+// eg:
+// "node-{host}-{yyyy}{MM}{dd}.log" => "node-server1-20220211.log"
+//
+// A name without any brace is treated as a legacy format which
+// is parsed by ParseTimeFormat.
+type FileNameTemplate struct {
+	raw    string
+	tokens []nameToken
+	timed  bool
+	utc    bool
+	// layout is the golang time layout of a legacy format.
+	layout string
+	pid    string
+	host   string
+}
+
+// ParseFileNameTemplate compiles the file name template s.
+func ParseFileNameTemplate(s string) (*FileNameTemplate, error) {
+	t := &FileNameTemplate{raw: s}
+	if s == "" {
+		return nil, fmt.Errorf("invalid log file name template: empty file name")
+	}
+	if !strings.ContainsAny(s, "{}") {
+		t.layout = legacyTimeLayout(s)
+		t.timed = true
+		return t, nil
+	}
+
+	var lit strings.Builder
+	flush := func() {
+		if lit.Len() > 0 {
+			t.tokens = append(t.tokens, nameToken{kind: tokenLiteral, text: lit.String()})
+			lit.Reset()
+		}
+	}
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '{':
+			if i+1 < len(s) && s[i+1] == '{' {
+				lit.WriteByte('{')
+				i++
+				continue
+			}
+			end := strings.IndexByte(s[i+1:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid log file name template %q: unclosed '{' at offset %d", s, i)
+			}
+			name := s[i+1 : i+1+end]
+			kind, ok := fileNameTokens[name]
+			if !ok {
+				return nil, fmt.Errorf("invalid log file name template %q: unknown token {%s} at offset %d", s, name, i)
+			}
+			i += end + 1
+			switch kind {
+			case tokenUTC:
+				t.utc = true
+				continue
+			case tokenLocal:
+				t.utc = false
+				continue
+			case tokenPID:
+				t.pid = strconv.Itoa(os.Getpid())
+			case tokenHost:
+				t.host = sanitizeFileNamePart(hostName())
+			case tokenLabel:
+			default:
+				t.timed = true
+			}
+			flush()
+			t.tokens = append(t.tokens, nameToken{kind: kind})
+		case '}':
+			if i+1 < len(s) && s[i+1] == '}' {
+				lit.WriteByte('}')
+				i++
+				continue
+			}
+			return nil, fmt.Errorf("invalid log file name template %q: unexpected '}' at offset %d, use \"}}\" for a literal brace", s, i)
+		default:
+			lit.WriteByte(c)
+		}
+	}
+	flush()
+	if len(t.tokens) == 0 {
+		return nil, fmt.Errorf("invalid log file name template %q: empty file name", s)
+	}
+	return t, nil
+}
+
+// MustParseFileNameTemplate is like ParseFileNameTemplate but panics if s cannot be parsed.
+func MustParseFileNameTemplate(s string) *FileNameTemplate {
+	t, err := ParseFileNameTemplate(s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// String returns the source text of the template.
+func (t *FileNameTemplate) String() string {
+	return t.raw
+}
+
+// HasTimeTokens reports whether the rendered name depends on the time.
+func (t *FileNameTemplate) HasTimeTokens() bool {
+	return t.timed
+}
+
+// isLegacy reports whether the template is a legacy format without braces.
+func (t *FileNameTemplate) isLegacy() bool {
+	return t.layout != ""
+}
+
+// Execute renders the file name with the time now and the logger label.
+func (t *FileNameTemplate) Execute(now time.Time, label string) string {
+	if t.isLegacy() {
+		dir, file := filepath.Split(t.layout)
+		return filepath.Join(dir, now.Format(file))
+	}
+	if t.utc {
+		now = now.UTC()
+	}
+
+	var b strings.Builder
+	for _, tk := range t.tokens {
+		switch tk.kind {
+		case tokenLiteral:
+			b.WriteString(tk.text)
+		case tokenYear:
+			b.WriteString(padInt(now.Year(), 4))
+		case tokenYearShort:
+			b.WriteString(padInt(now.Year()%100, 2))
+		case tokenMonth:
+			b.WriteString(padInt(int(now.Month()), 2))
+		case tokenDay:
+			b.WriteString(padInt(now.Day(), 2))
+		case tokenHour:
+			b.WriteString(padInt(now.Hour(), 2))
+		case tokenMinute:
+			b.WriteString(padInt(now.Minute(), 2))
+		case tokenSecond:
+			b.WriteString(padInt(now.Second(), 2))
+		case tokenMillisecond:
+			b.WriteString(padInt(now.Nanosecond()/int(time.Millisecond), 3))
+		case tokenDayOfYear:
+			b.WriteString(padInt(now.YearDay(), 3))
+		case tokenISOWeek:
+			_, week := now.ISOWeek()
+			b.WriteString(padInt(week, 2))
+		case tokenISOYear:
+			isoYear, _ := now.ISOWeek()
+			b.WriteString(padInt(isoYear, 4))
+		case tokenPID:
+			b.WriteString(t.pid)
+		case tokenHost:
+			b.WriteString(t.host)
+		case tokenLabel:
+			b.WriteString(sanitizeFileNamePart(label))
+		}
+	}
+	return b.String()
+}
+
+func padInt(i, width int) string {
+	s := strconv.Itoa(i)
+	if len(s) < width {
+		s = strings.Repeat("0", width-len(s)) + s
+	}
+	return s
+}
+
+func hostName() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		return "unknown"
+	}
+	return host
+}
+
+// sanitizeFileNamePart replaces characters which must not appear in a file name.
+func sanitizeFileNamePart(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', '*', '?', '"', '<', '>', '|', ' ':
+			return '_'
+		}
+		return r
+	}, s)
+}
@@ -2,8 +2,11 @@ package rzerolog
 
 import (
 	"github.com/stretchr/testify/require"
+	"os"
 	"path/filepath"
+	"strconv"
 	"testing"
+	"time"
 )
 
 func TestParseFormat(t *testing.T) {
@@ -11,10 +14,71 @@ func TestParseFormat(t *testing.T) {
 	res := ParseTimeFormat(tmp)
 	require.Equal(t, filepath.Join(tmp), res)
 
+	now := time.Date(2022, 2, 11, 16, 4, 5, 0, time.Local)
 	tmp = "/A/B/C/yyyy-MM-dd HH.log"
-	res = ParseTimeFormat(tmp)
+	res = parseTimeFormat(tmp, now)
+	require.Equal(t, "/A/B/C/2022-02-11 16.log", res)
+	tmp = "/A/B/C/yyyyyMMMdddHHH.log"
+	res = parseTimeFormat(tmp, now)
+	require.Equal(t, "/A/B/C/2022y02M11d16H.log", res)
+}
+
+func TestLegacyTimeLayout(t *testing.T) {
+	tmp := "/A/B/C/file.log"
+	res := legacyTimeLayout(tmp)
+	require.Equal(t, filepath.Join(tmp), res)
+
+	tmp = "/A/B/C/yyyy-MM-dd HH.log"
+	res = legacyTimeLayout(tmp)
 	require.Equal(t, filepath.Join("/A/B/C/2006-01-02 15.log"), res)
 	tmp = "/A/B/C/yyyyyMMMdddHHH.log"
-	res = ParseTimeFormat(tmp)
+	res = legacyTimeLayout(tmp)
 	require.Equal(t, filepath.Join("/A/B/C/2006y01M02d15H.log"), res)
 }
+
+func TestFileNameTemplate(t *testing.T) {
+	now := time.Date(2021, 1, 3, 16, 5, 9, 42*int(time.Millisecond), time.UTC)
+
+	tmpl, err := ParseFileNameTemplate("yyyy-MM-dd HH.log")
+	require.Nil(t, err)
+	require.Equal(t, "2021-01-03 16.log", tmpl.Execute(now, ""))
+
+	tmpl, err = ParseFileNameTemplate("yyyyy-{yyyy}{MM}{dd}{HH}{mm}{ss}.{sss}.log")
+	require.Nil(t, err)
+	require.Equal(t, "yyyyy-20210103160509.042.log", tmpl.Execute(now, ""))
+
+	// 2021-01-03 belongs to the 53rd week of 2020
+	tmpl, err = ParseFileNameTemplate("{GGGG}-W{ww}-{DDD}-{yy}.log")
+	require.Nil(t, err)
+	require.Equal(t, "2020-W53-003-21.log", tmpl.Execute(now, ""))
+
+	tmpl, err = ParseFileNameTemplate("{{{label}}}-{pid}-{host}.log")
+	require.Nil(t, err)
+	require.False(t, tmpl.HasTimeTokens())
+	host, _ := os.Hostname()
+	require.Equal(t, "{p2p_net}-"+strconv.Itoa(os.Getpid())+"-"+sanitizeFileNamePart(host)+".log",
+		tmpl.Execute(now, "p2p/net"))
+
+	shanghai := time.FixedZone("CST", 8*3600)
+	tmpl, err = ParseFileNameTemplate("{utc}{dd}{HH}.log")
+	require.Nil(t, err)
+	require.Equal(t, "0316.log", tmpl.Execute(now.In(shanghai), ""))
+	tmpl, err = ParseFileNameTemplate("{dd}{HH}.log")
+	require.Nil(t, err)
+	require.Equal(t, "0400.log", tmpl.Execute(now.In(shanghai), ""))
+}
+
+func TestFileNameTemplateErrors(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"{yyyy.log",
+		"{year}.log",
+		"file}.log",
+		"{}.log",
+		"{utc}",
+	} {
+		_, err := ParseFileNameTemplate(s)
+		require.Error(t, err, s)
+	}
+	require.Panics(t, func() { WithLogFileName("{unknown}.log") })
+}
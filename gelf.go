@@ -0,0 +1,359 @@
+package rzerolog
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+var _ io.WriteCloser = (*GELFWriter)(nil)
+
+const (
+	GELFNetworkUDP = "udp"
+	GELFNetworkTCP = "tcp"
+)
+
+const (
+	// DefaultGELFChunkSize is the size of UDP chunks, which fits the MTU of most networks.
+	DefaultGELFChunkSize = 1420
+	// gelfMaxChunks is the maximum count of chunks of a message allowed by GELF.
+	gelfMaxChunks = 128
+	// gelfChunkHeaderSize is the size of the magic bytes, message id, sequence number and sequence count.
+	gelfChunkHeaderSize = 12
+)
+
+// ErrGELFMessageTooLarge is returned if a message cannot be sent in gelfMaxChunks UDP chunks.
+var ErrGELFMessageTooLarge = errors.New("gelf: message too large")
+
+// GELFWriter parses the JSON input and sends it to Graylog as a GELF 1.1 message.
+//
+// The message is sent as "short_message", and also as "full_message" if it has multiple lines or a stack.
+// The level is sent as the syslog severity, the label and other fields as additional fields
+// prefixed by '_', such as "_label".
+//
+// Over UDP, the messages larger than ChunkSize are chunked, and compressed with zlib if Compression is set.
+// Over TCP, the messages are terminated by a null byte.
+// The connection is dialed on the first write, and redialed on the next write if it is broken.
+// After failed dialing or a write timeout, it is redialed with exponential backoff and the events are dropped
+// with ErrSinkBackoff until then. Dialing and writing time out in a few seconds, so an unreachable collector
+// does not block the logger.
+type GELFWriter struct {
+	Enable bool
+	// Network is GELFNetworkUDP or GELFNetworkTCP.
+	Network string
+	// Addr is the address of the GELF input, such as "graylog:12201".
+	Addr string
+	// Host is the "host" of messages, the host name if it is empty.
+	Host string
+	// ChunkSize is the size of UDP chunks including the header, DefaultGELFChunkSize if it is 0.
+	ChunkSize int
+	// Compression is the zlib compression level of UDP messages, no compression if it is 0.
+	Compression int
+	// TimeFormat is the layout of the input time, DefaultTimeFormat if it is empty.
+	// The numeric times are in the unit of zerolog.TimeFieldFormat.
+	TimeFormat string
+
+	mu    sync.Mutex
+	conn  sinkConn
+	zbuf  bytes.Buffer
+	chunk []byte
+	// msgID is the id of the last chunked message
+	msgID uint64
+}
+
+// GELFOption configures a GELFWriter.
+type GELFOption func(w *GELFWriter)
+
+// WithGELFHost set the "host" of messages.
+func WithGELFHost(host string) GELFOption {
+	return func(w *GELFWriter) {
+		w.Host = host
+	}
+}
+
+// WithGELFChunkSize set the size of UDP chunks including the 12 bytes header.
+func WithGELFChunkSize(size int) GELFOption {
+	if size <= gelfChunkHeaderSize {
+		panic(fmt.Sprintf("invalid gelf chunk size %d", size))
+	}
+	return func(w *GELFWriter) {
+		w.ChunkSize = size
+	}
+}
+
+// WithGELFCompression compresses UDP messages with zlib in level, such as zlib.BestSpeed.
+func WithGELFCompression(level int) GELFOption {
+	if level < zlib.HuffmanOnly || level > zlib.BestCompression {
+		panic(fmt.Sprintf("invalid gelf compression level %d", level))
+	}
+	return func(w *GELFWriter) {
+		w.Compression = level
+	}
+}
+
+// NewGELFWriter creates a GELFWriter sending to addr over network, GELFNetworkUDP or GELFNetworkTCP.
+func NewGELFWriter(network, addr string, opts ...GELFOption) (*GELFWriter, error) {
+	switch network {
+	case GELFNetworkUDP, GELFNetworkTCP:
+	default:
+		return nil, fmt.Errorf("unsupported gelf network %q. supporting: %s %s", network, GELFNetworkUDP, GELFNetworkTCP)
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return nil, fmt.Errorf("invalid gelf address %q: %v", addr, err)
+	}
+	w := &GELFWriter{Enable: true, Network: network, Addr: addr}
+	for _, opt := range opts {
+		opt(w)
+	}
+	if w.Host == "" {
+		w.Host, _ = os.Hostname()
+	}
+	if w.Host == "" {
+		w.Host = "unknown"
+	}
+	var id [8]byte
+	_, _ = rand.Read(id[:])
+	w.msgID = binary.BigEndian.Uint64(id[:])
+	return w, nil
+}
+
+// Write sends the JSON input as a GELF message.
+func (w *GELFWriter) Write(p []byte) (n int, err error) {
+	if !w.Enable {
+		return len(p), nil
+	}
+
+	evt := getConsoleEvent()
+	defer evt.release()
+
+	if err = evt.parse(p); err != nil {
+		return n, fmt.Errorf("cannot decode event: %s", err)
+	}
+	evt.buf = w.appendMessage(evt.buf, evt)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err = w.send(evt.buf); err != nil {
+		return n, err
+	}
+	return len(p), nil
+}
+
+// Close closes the connection.
+func (w *GELFWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.conn.close()
+}
+
+// send sends the encoded message over the connection.
+func (w *GELFWriter) send(msg []byte) error {
+	if _, err := w.conn.get(func(d *net.Dialer) (net.Conn, error) { return d.Dial(w.Network, w.Addr) }); err != nil {
+		return err
+	}
+	if w.Network == GELFNetworkTCP {
+		// the null byte frames the messages, it never appears in the JSON
+		return w.conn.write(append(msg, 0))
+	}
+
+	if w.Compression != 0 {
+		w.zbuf.Reset()
+		zw, err := zlib.NewWriterLevel(&w.zbuf, w.Compression)
+		if err != nil {
+			return err
+		}
+		if _, err = zw.Write(msg); err != nil {
+			return err
+		}
+		if err = zw.Close(); err != nil {
+			return err
+		}
+		msg = w.zbuf.Bytes()
+	}
+	size := w.ChunkSize
+	if size <= 0 {
+		size = DefaultGELFChunkSize
+	}
+	if len(msg) <= size {
+		return w.conn.write(msg)
+	}
+	return w.sendChunks(msg, size-gelfChunkHeaderSize)
+}
+
+// sendChunks sends msg in chunks of size bytes of data.
+func (w *GELFWriter) sendChunks(msg []byte, size int) error {
+	count := (len(msg) + size - 1) / size
+	if count > gelfMaxChunks {
+		return ErrGELFMessageTooLarge
+	}
+	id := atomic.AddUint64(&w.msgID, 1)
+	for seq := 0; seq < count; seq++ {
+		data := msg[seq*size:]
+		if len(data) > size {
+			data = data[:size]
+		}
+		w.chunk = append(w.chunk[:0], 0x1e, 0x0f, 0, 0, 0, 0, 0, 0, 0, 0, byte(seq), byte(count))
+		binary.BigEndian.PutUint64(w.chunk[2:10], id)
+		w.chunk = append(w.chunk, data...)
+		if err := w.conn.write(w.chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appendMessage appends evt encoded as a GELF message.
+func (w *GELFWriter) appendMessage(dst []byte, evt *consoleEvent) []byte {
+	dst = append(dst, `{"version":"1.1","host":`...)
+	dst = appendJSONString(dst, []byte(w.Host))
+
+	msg, _ := evt.field(zerolog.MessageFieldName)
+	stack, hasStack := evt.field(zerolog.ErrorStackFieldName)
+	var short, full []byte
+	if msg != nil {
+		short = msg.value
+		if i := bytes.IndexByte(short, '\n'); i >= 0 {
+			short, full = bytes.TrimRight(short[:i], "\r"), msg.value
+		}
+	}
+	if len(short) == 0 {
+		// short_message must not be empty
+		if f, ok := evt.field(zerolog.ErrorFieldName); ok && len(f.value) > 0 {
+			short = f.value
+		} else {
+			short = []byte("-")
+		}
+	}
+	dst = append(dst, `,"short_message":`...)
+	dst = appendJSONString(dst, short)
+	if full != nil || hasStack {
+		dst = append(dst, `,"full_message":`...)
+		dst = w.appendFullMessage(dst, msg, stack)
+	}
+
+	dst = append(dst, `,"timestamp":`...)
+	dst = w.appendTimestamp(dst, evt)
+	dst = append(dst, `,"level":`...)
+	level := 6
+	if f, ok := evt.field(zerolog.LevelFieldName); ok {
+		if l, ok := levelOf(f.value); ok {
+			level = syslogSeverity(l)
+		}
+	}
+	dst = strconv.AppendInt(dst, int64(level), 10)
+
+	for i := range evt.fields {
+		switch string(evt.fields[i].key) {
+		case zerolog.MessageFieldName, zerolog.ErrorStackFieldName, zerolog.TimestampFieldName, zerolog.LevelFieldName:
+			continue
+		}
+		if evt.fields[i].kind == jsonNull {
+			continue
+		}
+		evt.order = append(evt.order, i)
+	}
+	evt.dedupOrder()
+	for _, idx := range evt.order {
+		f := &evt.fields[idx]
+		dst = append(dst, ',', '"', '_')
+		dst = appendGELFFieldName(dst, f.key)
+		dst = append(dst, '"', ':')
+		if f.kind == jsonNumber {
+			dst = append(dst, f.value...)
+		} else {
+			// additional fields are strings or numbers
+			dst = appendJSONString(dst, f.value)
+		}
+	}
+	return append(dst, '}')
+}
+
+// appendFullMessage appends the message and the stack as the full message.
+func (w *GELFWriter) appendFullMessage(dst []byte, msg, stack *eventField) []byte {
+	start := len(dst)
+	if msg != nil {
+		dst = append(dst, msg.value...)
+	}
+	if stack != nil {
+		if len(dst) > start {
+			dst = append(dst, '\n')
+		}
+		dst = append(dst, stack.value...)
+	}
+	// the raw text is encoded in place after it
+	n := len(dst) - start
+	dst = appendJSONString(dst, dst[start:])
+	return append(dst[:start], dst[start+n:]...)
+}
+
+// appendTimestamp appends the time of evt as seconds since the epoch with milliseconds.
+func (w *GELFWriter) appendTimestamp(dst []byte, evt *consoleEvent) []byte {
+	t := time.Now()
+	if f, ok := evt.field(zerolog.TimestampFieldName); ok {
+		layout := w.TimeFormat
+		if layout == "" {
+			layout = DefaultTimeFormat
+		}
+		switch f.kind {
+		case jsonString:
+			if pt, err := time.ParseInLocation(layout, string(f.value), time.Local); err == nil {
+				t = pt
+			}
+		case jsonNumber:
+			// the unix time formats of zerolog are integers in the unit of zerolog.TimeFieldFormat
+			n, err := strconv.ParseInt(string(f.value), 10, 64)
+			if err != nil {
+				// seconds with a fraction already
+				return append(dst, f.value...)
+			}
+			switch zerolog.TimeFieldFormat {
+			case zerolog.TimeFormatUnixMs:
+				t = time.Unix(0, n*int64(time.Millisecond))
+			case zerolog.TimeFormatUnixMicro:
+				t = time.Unix(0, n*int64(time.Microsecond))
+			default:
+				t = time.Unix(n, 0)
+			}
+		}
+	}
+	ms := t.UnixNano() / int64(time.Millisecond)
+	dst = strconv.AppendInt(dst, ms/1000, 10)
+	dst = append(dst, '.')
+	frac := ms % 1000
+	if frac < 100 {
+		dst = append(dst, '0')
+	}
+	if frac < 10 {
+		dst = append(dst, '0')
+	}
+	return strconv.AppendInt(dst, frac, 10)
+}
+
+// appendGELFFieldName appends the additional field name, the bytes not in [\w.-] are replaced by '_'.
+func appendGELFFieldName(dst, key []byte) []byte {
+	// "_id" is reserved by Graylog
+	if string(key) == "id" {
+		return append(dst, "_id"...)
+	}
+	for _, c := range key {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '_', c == '.', c == '-':
+		default:
+			c = '_'
+		}
+		dst = append(dst, c)
+	}
+	return dst
+}
@@ -0,0 +1,154 @@
+package rzerolog
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGELFMessage(t *testing.T) {
+	w, err := NewGELFWriter(GELFNetworkUDP, "127.0.0.1:12201", WithGELFHost("node1"))
+	require.Nil(t, err)
+	evt := getConsoleEvent()
+	defer evt.release()
+	require.Nil(t, evt.parse([]byte(`{"level":"warn","label":"p2p","time":1644566645.123,"id":7,"a b":true,`+
+		`"message":"dial failed\nretrying","stack":"main.go:1","nil":null}`)))
+	msg := w.appendMessage(nil, evt)
+	require.Equal(t, `{"version":"1.1","host":"node1","short_message":"dial failed",`+
+		`"full_message":"dial failed\nretrying\nmain.go:1","timestamp":1644566645.123,"level":4,`+
+		`"_label":"p2p","__id":7,"_a_b":"true"}`, string(msg))
+	require.True(t, json.Valid(msg))
+
+	// the unix times are seconds with milliseconds
+	defer func(format string) { zerolog.TimeFieldFormat = format }(zerolog.TimeFieldFormat)
+	for _, c := range []struct{ format, time, want string }{
+		{zerolog.TimeFormatUnix, "1644566645", "1644566645.000"},
+		{zerolog.TimeFormatUnixMs, "1644566645123", "1644566645.123"},
+		{zerolog.TimeFormatUnixMicro, "1644566645123456", "1644566645.123"},
+		{zerolog.TimeFormatUnixMs, "5", "0.005"},
+	} {
+		zerolog.TimeFieldFormat = c.format
+		evt.reset()
+		require.Nil(t, evt.parse([]byte(`{"time":`+c.time+`}`)))
+		require.Equal(t, c.want, string(w.appendTimestamp(nil, evt)), c.format)
+	}
+
+	_, err = NewGELFWriter("unix", "127.0.0.1:12201")
+	require.NotNil(t, err)
+	_, err = NewGELFWriter(GELFNetworkTCP, "graylog")
+	require.NotNil(t, err)
+}
+
+func TestGELFWriterUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer conn.Close()
+
+	w, err := NewGELFWriter(GELFNetworkUDP, conn.LocalAddr().String(),
+		WithGELFChunkSize(64), WithGELFCompression(zlib.BestSpeed))
+	require.Nil(t, err)
+	defer w.Close()
+	long := bytes.Repeat([]byte("0123456789"), 40)
+	_, err = w.Write([]byte(`{"level":"info","message":"` + string(long) + `","n":1}`))
+	require.Nil(t, err)
+
+	// reassemble the chunks
+	var chunks [][]byte
+	count := 1
+	buf := make([]byte, 2048)
+	for len(chunks) < count {
+		n, _, err := conn.ReadFrom(buf)
+		require.Nil(t, err)
+		b := append([]byte(nil), buf[:n]...)
+		if len(chunks) == 0 && !(b[0] == 0x1e && b[1] == 0x0f) {
+			chunks = append(chunks, b)
+			break
+		}
+		require.Equal(t, []byte{0x1e, 0x0f}, b[:2])
+		require.LessOrEqual(t, len(b), 64)
+		count = int(b[11])
+		if chunks == nil {
+			chunks = make([][]byte, 0, count)
+		}
+		require.Equal(t, len(chunks), int(b[10]))
+		chunks = append(chunks, b[12:])
+	}
+	require.Greater(t, count, 1)
+	zr, err := zlib.NewReader(bytes.NewReader(bytes.Join(chunks, nil)))
+	require.Nil(t, err)
+	msg, err := ioutil.ReadAll(zr)
+	require.Nil(t, err)
+	var m map[string]interface{}
+	require.Nil(t, json.Unmarshal(msg, &m))
+	require.Equal(t, string(long), m["short_message"])
+	require.Equal(t, float64(6), m["level"])
+	require.Equal(t, float64(1), m["_n"])
+}
+
+func TestGELFWriterTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer ln.Close()
+	received := make(chan []string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			received <- nil
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		var msgs []string
+		for len(msgs) < 2 {
+			b, err := r.ReadBytes(0)
+			if err != nil {
+				break
+			}
+			msgs = append(msgs, string(b[:len(b)-1]))
+		}
+		received <- msgs
+	}()
+
+	l := NewRZeroLogger(DisableConsolePrint(), WithNoCaller(), WithLabel("p2p"),
+		WithGELFOutput(GELFNetworkTCP, ln.Addr().String(), WithGELFHost("node1")))
+	l.Info().Msg("first")
+	l.Error().Msg("second")
+	msgs := <-received
+	require.Nil(t, l.Close())
+	require.Len(t, msgs, 2)
+	require.Contains(t, msgs[0], `"short_message":"first"`)
+	require.Contains(t, msgs[0], `"_label":"p2p"`)
+	require.Contains(t, msgs[1], `"level":3`)
+}
+
+func TestGELFWriterBackoff(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	addr := ln.Addr().String()
+	require.Nil(t, ln.Close())
+
+	w, err := NewGELFWriter(GELFNetworkTCP, addr)
+	require.Nil(t, err)
+	_, err = w.Write([]byte(`{"level":"info","message":"a"}`))
+	require.Error(t, err)
+	// the refused collector is not redialed for every event
+	_, err = w.Write([]byte(`{"level":"info","message":"b"}`))
+	require.Equal(t, ErrSinkBackoff, err)
+
+	ln, err = net.Listen("tcp", addr)
+	require.Nil(t, err)
+	defer ln.Close()
+	w.conn.retryAt = time.Now()
+	_, err = w.Write([]byte(`{"level":"info","message":"c"}`))
+	require.Nil(t, err)
+	require.Zero(t, w.conn.backoff)
+	require.Nil(t, w.Close())
+}
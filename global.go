@@ -4,6 +4,11 @@ const (
 	LabelFieldName = "label"
 )
 
+const (
+	DefaultFileMode = 0666
+	DefaultDirMode  = 0777
+)
+
 const (
 	DefaultTimeFormat   = "2006-01-02 15:04:05.000"
 	DefaultFilePath     = "./"
@@ -14,5 +19,12 @@ const (
 
 	LogFormatJSON        = "json"
 	LogFormatConsoleText = "text"
+	LogFormatLogfmt      = "logfmt"
+	LogFormatECS         = "ecs"
+	LogFormatCBOR        = "cbor"
+	LogFormatCSV         = "csv"
+	LogFormatTSV         = "tsv"
 	DefaultLogFormat     = LogFormatJSON
+	// DefaultConsoleLogFormat is the output format on console.
+	DefaultConsoleLogFormat = LogFormatConsoleText
 )
@@ -0,0 +1,593 @@
+package rzerolog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+var _ io.WriteCloser = (*HTTPWriter)(nil)
+
+// HTTPFormat defines the API which HTTPWriter pushes the events to.
+type HTTPFormat int
+
+const (
+	// HTTPLoki pushes the events to the push API of Grafana Loki, "/loki/api/v1/push".
+	HTTPLoki HTTPFormat = iota
+	// HTTPElasticsearch indexes the events as ECS documents by the bulk API of Elasticsearch, "/_bulk".
+	HTTPElasticsearch
+)
+
+const (
+	HTTPLokiName          = "loki"
+	HTTPElasticsearchName = "elasticsearch"
+)
+
+const (
+	// DefaultHTTPBatchSize is the size of events sent in a request, 1M.
+	DefaultHTTPBatchSize = 1 << 20
+	// DefaultHTTPFlushInterval is the interval the events are sent in even if the batch is not full.
+	DefaultHTTPFlushInterval = time.Second
+	// DefaultHTTPMaxRetries is the count of retries of a failed request.
+	DefaultHTTPMaxRetries = 5
+	// DefaultHTTPIndex is the index of Elasticsearch.
+	DefaultHTTPIndex = "rzerolog"
+	// httpQueueSize is the count of batches waiting to be sent, the batches beyond it are dropped.
+	httpQueueSize = 16
+	// httpTimeout is the timeout of the requests of the default client.
+	httpTimeout = 10 * time.Second
+)
+
+// defaultHTTPClient sends the requests if HTTPWriter.Client is nil.
+var defaultHTTPClient = &http.Client{Timeout: httpTimeout}
+
+// ErrHTTPClosed is returned by the writes after Close.
+var ErrHTTPClosed = errors.New("http sink: closed")
+
+// String returns the name of the HTTP format.
+func (f HTTPFormat) String() string {
+	switch f {
+	case HTTPLoki:
+		return HTTPLokiName
+	case HTTPElasticsearch:
+		return HTTPElasticsearchName
+	}
+	return fmt.Sprintf("HTTPFormat(%d)", int(f))
+}
+
+// ParseHTTPFormat returns the HTTP format with the given name.
+// An empty name means HTTPLoki.
+func ParseHTTPFormat(name string) (HTTPFormat, error) {
+	switch name {
+	case "", HTTPLokiName:
+		return HTTPLoki, nil
+	case HTTPElasticsearchName:
+		return HTTPElasticsearch, nil
+	}
+	return 0, fmt.Errorf("unsupported http format %q. supporting: %s %s", name,
+		HTTPLokiName, HTTPElasticsearchName)
+}
+
+// HTTPStats is the statistics of an HTTPWriter.
+type HTTPStats struct {
+	// Sent is the count of events accepted by the server.
+	Sent uint64
+	// Failed is the count of events given up after retries or rejected by the server.
+	Failed uint64
+	// Dropped is the count of events dropped because the queue is full.
+	Dropped uint64
+	// Requests is the count of requests, including the retries.
+	Requests uint64
+	// Retries is the count of retried requests.
+	Retries uint64
+}
+
+// HTTPWriter batches the events and pushes them to Loki or Elasticsearch over HTTP.
+//
+// The events are sent when the batch reaches BatchSize or every FlushInterval.
+// The requests failing with network errors, 429 or 5xx are retried with exponential backoff,
+// in the delay of "Retry-After" if the server gives it. The other batches are sent while a batch
+// waits for its retry, so the events may arrive out of order. The retries waiting on Close are given up.
+//
+// For Loki, the events are grouped into streams by the label and the values of LokiLabels fields,
+// each line is the JSON event. For Elasticsearch, the events are indexed into Index as ECS documents.
+type HTTPWriter struct {
+	// URL is the endpoint, such as "http://loki:3100/loki/api/v1/push" or "http://es:9200/_bulk".
+	URL string
+	// Format is the API of URL.
+	Format HTTPFormat
+	// Client sends the requests, a client with a timeout of 10 seconds if it is nil.
+	Client *http.Client
+	// Header is added to the requests, such as "Authorization".
+	Header http.Header
+	// BatchSize is the size of events sent in a request, DefaultHTTPBatchSize if it is 0.
+	BatchSize int
+	// FlushInterval is the max delay of sending an event, DefaultHTTPFlushInterval if it is 0.
+	FlushInterval time.Duration
+	// MaxRetries is the count of retries of a failed request.
+	MaxRetries int
+	// MinBackoff and MaxBackoff bound the delay of retries.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// LokiLabels is the fields which are stream labels of Loki besides the label, such as "level".
+	LokiLabels []string
+	// Index is the index of Elasticsearch, DefaultHTTPIndex if it is empty.
+	Index string
+
+	mu      sync.Mutex
+	pending *httpBatch
+	closed  bool
+	batches chan *httpBatch
+	start   sync.Once
+	done    chan struct{}
+	stopped chan struct{}
+	stats   HTTPStats
+}
+
+// httpBatch is the events sent in a request.
+type httpBatch struct {
+	lines [][]byte
+	// received is the time of lines when written
+	received []int64
+	size     int
+
+	// body is the request of the batch, which is kept for the retries
+	body        []byte
+	contentType string
+	// attempt is the count of failed requests, backoff the delay after the last one
+	attempt int
+	backoff time.Duration
+	retryAt time.Time
+}
+
+// HTTPOption configures an HTTPWriter.
+type HTTPOption func(w *HTTPWriter)
+
+// WithHTTPBatch set the size and interval the events are sent in, the zero values mean the defaults.
+func WithHTTPBatch(size int, interval time.Duration) HTTPOption {
+	return func(w *HTTPWriter) {
+		if size > 0 {
+			w.BatchSize = size
+		}
+		if interval > 0 {
+			w.FlushInterval = interval
+		}
+	}
+}
+
+// WithHTTPRetry set the count of retries and the bounds of their delay.
+func WithHTTPRetry(maxRetries int, min, max time.Duration) HTTPOption {
+	if maxRetries < 0 || min <= 0 || max < min {
+		panic(fmt.Sprintf("invalid http retry %d %v-%v", maxRetries, min, max))
+	}
+	return func(w *HTTPWriter) {
+		w.MaxRetries = maxRetries
+		w.MinBackoff = min
+		w.MaxBackoff = max
+	}
+}
+
+// WithHTTPHeader adds a header to the requests, such as the "Authorization".
+func WithHTTPHeader(key, value string) HTTPOption {
+	return func(w *HTTPWriter) {
+		w.Header.Add(key, value)
+	}
+}
+
+// WithHTTPClient set the client sending the requests.
+func WithHTTPClient(c *http.Client) HTTPOption {
+	return func(w *HTTPWriter) {
+		w.Client = c
+	}
+}
+
+// WithLokiLabels set the fields which are stream labels of Loki besides the label.
+func WithLokiLabels(fields ...string) HTTPOption {
+	return func(w *HTTPWriter) {
+		w.LokiLabels = fields
+	}
+}
+
+// WithElasticsearchIndex set the index of Elasticsearch.
+func WithElasticsearchIndex(index string) HTTPOption {
+	return func(w *HTTPWriter) {
+		w.Index = index
+	}
+}
+
+// NewHTTPWriter creates an HTTPWriter pushing to url in format.
+func NewHTTPWriter(format HTTPFormat, url string, opts ...HTTPOption) (*HTTPWriter, error) {
+	if format != HTTPLoki && format != HTTPElasticsearch {
+		return nil, fmt.Errorf("unsupported http format %v", format)
+	}
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return nil, fmt.Errorf("invalid http sink url %q", url)
+	}
+	w := &HTTPWriter{
+		URL:           url,
+		Format:        format,
+		Header:        http.Header{},
+		BatchSize:     DefaultHTTPBatchSize,
+		FlushInterval: DefaultHTTPFlushInterval,
+		MaxRetries:    DefaultHTTPMaxRetries,
+		MinBackoff:    DefaultNetworkMinBackoff,
+		MaxBackoff:    DefaultNetworkMaxBackoff,
+		Index:         DefaultHTTPIndex,
+		batches:       make(chan *httpBatch, httpQueueSize),
+		done:          make(chan struct{}),
+		stopped:       make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w, nil
+}
+
+// Stats returns the statistics of w.
+func (w *HTTPWriter) Stats() HTTPStats {
+	return HTTPStats{
+		Sent:     atomic.LoadUint64(&w.stats.Sent),
+		Failed:   atomic.LoadUint64(&w.stats.Failed),
+		Dropped:  atomic.LoadUint64(&w.stats.Dropped),
+		Requests: atomic.LoadUint64(&w.stats.Requests),
+		Retries:  atomic.LoadUint64(&w.stats.Retries),
+	}
+}
+
+// Write adds the JSON input to the batch.
+func (w *HTTPWriter) Write(p []byte) (n int, err error) {
+	w.start.Do(func() {
+		go w.run()
+	})
+	line := bytes.TrimRight(p, "\n")
+	line = append(make([]byte, 0, len(line)), line...)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return 0, ErrHTTPClosed
+	}
+	if w.pending == nil {
+		w.pending = &httpBatch{}
+	}
+	w.pending.lines = append(w.pending.lines, line)
+	w.pending.received = append(w.pending.received, time.Now().UnixNano())
+	w.pending.size += len(line)
+	if w.pending.size >= w.BatchSize {
+		w.enqueue(w.pending)
+		w.pending = nil
+	}
+	return len(p), nil
+}
+
+// enqueue queues b to be sent, or drops it if the queue is full.
+func (w *HTTPWriter) enqueue(b *httpBatch) {
+	select {
+	case w.batches <- b:
+	default:
+		atomic.AddUint64(&w.stats.Dropped, uint64(len(b.lines)))
+	}
+}
+
+// Close sends the events written and stops w.
+func (w *HTTPWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	last := w.pending
+	w.pending = nil
+	close(w.batches)
+	w.mu.Unlock()
+	close(w.done)
+
+	started := true
+	w.start.Do(func() {
+		started = false
+	})
+	if started {
+		<-w.stopped
+	}
+	if last != nil {
+		w.ship(last, nil)
+	}
+	return nil
+}
+
+// run sends the queued batches, and the pending one every FlushInterval.
+// The failed batches wait for their retries aside, so that the queue is drained meanwhile.
+func (w *HTTPWriter) run() {
+	defer close(w.stopped)
+	ticker := time.NewTicker(w.FlushInterval)
+	defer ticker.Stop()
+	// retries is the batches waiting for their retries, in the order of retryAt
+	var retries []*httpBatch
+	for {
+		var retryC <-chan time.Time
+		var timer *time.Timer
+		if len(retries) > 0 {
+			timer = time.NewTimer(time.Until(retries[0].retryAt))
+			retryC = timer.C
+		}
+		select {
+		case b, ok := <-w.batches:
+			if !ok {
+				// the retries are given up on Close
+				for _, b := range retries {
+					atomic.AddUint64(&w.stats.Failed, uint64(len(b.lines)))
+				}
+				return
+			}
+			retries = w.ship(b, retries)
+		case <-ticker.C:
+			w.mu.Lock()
+			b := w.pending
+			if !w.closed {
+				w.pending = nil
+			} else {
+				b = nil
+			}
+			w.mu.Unlock()
+			if b != nil {
+				retries = w.ship(b, retries)
+			}
+		case <-retryC:
+			b := retries[0]
+			retries = w.ship(b, retries[1:])
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// ship sends b, and adds it to retries if it should be retried.
+func (w *HTTPWriter) ship(b *httpBatch, retries []*httpBatch) []*httpBatch {
+	if b.body == nil {
+		b.body, b.contentType = w.encode(b)
+	}
+	if b.attempt > 0 {
+		atomic.AddUint64(&w.stats.Retries, 1)
+	}
+	atomic.AddUint64(&w.stats.Requests, 1)
+	resp, err := w.post(b.body, b.contentType)
+	retry := err != nil
+	var retryAfter time.Duration
+	if err == nil {
+		switch {
+		case resp.status/100 == 2:
+			failed := resp.failed
+			atomic.AddUint64(&w.stats.Sent, uint64(len(b.lines)-failed))
+			atomic.AddUint64(&w.stats.Failed, uint64(failed))
+			return retries
+		case resp.status == http.StatusTooManyRequests, resp.status/100 == 5:
+			retry = true
+			retryAfter = resp.retryAfter
+		}
+	}
+	closed := false
+	select {
+	case <-w.done:
+		closed = true
+	default:
+	}
+	if !retry || b.attempt >= w.MaxRetries || closed {
+		atomic.AddUint64(&w.stats.Failed, uint64(len(b.lines)))
+		return retries
+	}
+	b.attempt++
+	b.backoff = nextBackoff(b.backoff, w.MinBackoff, w.MaxBackoff)
+	delay := b.backoff
+	if retryAfter > 0 {
+		delay = retryAfter
+		if delay > w.MaxBackoff {
+			delay = w.MaxBackoff
+		}
+	}
+	b.retryAt = time.Now().Add(delay)
+	i := sort.Search(len(retries), func(i int) bool { return retries[i].retryAt.After(b.retryAt) })
+	retries = append(retries, nil)
+	copy(retries[i+1:], retries[i:])
+	retries[i] = b
+	return retries
+}
+
+type httpResponse struct {
+	status     int
+	retryAfter time.Duration
+	// failed is the count of events rejected in a successful bulk request
+	failed int
+}
+
+// post sends a request with body.
+func (w *HTTPWriter) post(body []byte, contentType string) (*httpResponse, error) {
+	req, err := http.NewRequest(http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range w.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", contentType)
+	client := w.Client
+	if client == nil {
+		client = defaultHTTPClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4<<20))
+
+	r := &httpResponse{status: resp.StatusCode}
+	if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && s > 0 {
+		r.retryAfter = time.Duration(s) * time.Second
+	}
+	if w.Format == HTTPElasticsearch && r.status/100 == 2 {
+		r.failed = bulkFailures(respBody)
+	}
+	return r, nil
+}
+
+// bulkFailures returns the count of failed items in the response of the bulk API.
+func bulkFailures(body []byte) int {
+	var resp struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			Status int `json:"status"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil || !resp.Errors {
+		return 0
+	}
+	failed := 0
+	for _, item := range resp.Items {
+		for _, result := range item {
+			if result.Status/100 != 2 {
+				failed++
+			}
+		}
+	}
+	return failed
+}
+
+// encode returns the request body of b and its content type.
+func (w *HTTPWriter) encode(b *httpBatch) ([]byte, string) {
+	if w.Format == HTTPElasticsearch {
+		return w.encodeBulk(b), "application/x-ndjson"
+	}
+	return w.encodeLoki(b), "application/json"
+}
+
+// encodeBulk encodes b as the body of the bulk API, the events are ECS documents.
+func (w *HTTPWriter) encodeBulk(b *httpBatch) []byte {
+	ecs := &ECSWriter{}
+	action := []byte(`{"create":{"_index":`)
+	index := w.Index
+	if index == "" {
+		index = DefaultHTTPIndex
+	}
+	action = appendJSONString(action, []byte(index))
+	action = append(action, "}}\n"...)
+
+	var body []byte
+	evt := getConsoleEvent()
+	defer evt.release()
+	for _, line := range b.lines {
+		evt.reset()
+		if err := evt.parse(line); err != nil {
+			// the event is not JSON, index it as the message
+			evt.reset()
+			evt.fields = append(evt.fields, eventField{key: []byte(zerolog.MessageFieldName), value: line})
+		}
+		ecs.appendEvent(evt)
+		body = append(body, action...)
+		body = append(body, evt.buf...)
+		body = append(body, '\n')
+	}
+	return body
+}
+
+// lokiStream is the events of a stream of Loki.
+type lokiStream struct {
+	labels map[string]string
+	values [][2]string
+}
+
+// encodeLoki encodes b as the body of the push API of Loki.
+func (w *HTTPWriter) encodeLoki(b *httpBatch) []byte {
+	var streams []*lokiStream
+	byKey := map[string]*lokiStream{}
+	evt := getConsoleEvent()
+	defer evt.release()
+	for i, line := range b.lines {
+		evt.reset()
+		labels := map[string]string{}
+		ts := b.received[i]
+		if err := evt.parse(line); err == nil {
+			if f, ok := evt.field(LabelFieldName); ok && len(f.value) > 0 {
+				labels[LabelFieldName] = string(f.value)
+			}
+			for _, name := range w.LokiLabels {
+				if f, ok := evt.field(name); ok && f.kind != jsonNull {
+					labels[lokiLabelName(name)] = string(f.value)
+				}
+			}
+			if f, ok := evt.field(zerolog.TimestampFieldName); ok && f.kind == jsonString {
+				if t, err := time.ParseInLocation(DefaultTimeFormat, string(f.value), time.Local); err == nil {
+					ts = t.UnixNano()
+				}
+			}
+		}
+		if len(labels) == 0 {
+			// a stream must have a label at least
+			labels["job"] = "rzerolog"
+		}
+		key := lokiStreamKey(labels)
+		s, ok := byKey[key]
+		if !ok {
+			s = &lokiStream{labels: labels}
+			byKey[key] = s
+			streams = append(streams, s)
+		}
+		s.values = append(s.values, [2]string{strconv.FormatInt(ts, 10), string(line)})
+	}
+
+	body := []byte(`{"streams":[`)
+	for i, s := range streams {
+		if i > 0 {
+			body = append(body, ',')
+		}
+		labels, _ := json.Marshal(s.labels)
+		values, _ := json.Marshal(s.values)
+		body = append(body, `{"stream":`...)
+		body = append(body, labels...)
+		body = append(body, `,"values":`...)
+		body = append(body, values...)
+		body = append(body, '}')
+	}
+	return append(body, "]}"...)
+}
+
+// lokiStreamKey returns the key of the label set.
+func lokiStreamKey(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	for _, k := range keys {
+		sb.WriteString(k)
+		sb.WriteByte(0)
+		sb.WriteString(labels[k])
+		sb.WriteByte(0)
+	}
+	return sb.String()
+}
+
+// lokiLabelName returns the label name of Loki of the field name, which matches [a-zA-Z_][a-zA-Z0-9_]*.
+func lokiLabelName(name string) string {
+	b := []byte(name)
+	for i, c := range b {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || i > 0 && c >= '0' && c <= '9') {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
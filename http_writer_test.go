@@ -0,0 +1,197 @@
+package rzerolog
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPWriterLoki(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		require.Equal(t, "Bearer x", r.Header.Get("Authorization"))
+		b, _ := ioutil.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, string(b))
+		mu.Unlock()
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	w, err := NewHTTPWriter(HTTPLoki, srv.URL+"/loki/api/v1/push", WithLokiLabels("level"),
+		WithHTTPHeader("Authorization", "Bearer x"), WithHTTPBatch(1<<20, time.Hour))
+	require.Nil(t, err)
+	for _, line := range []string{
+		`{"level":"info","label":"p2p","time":"2022-02-11 16:04:05.000","message":"a"}`,
+		`{"level":"warn","label":"p2p","message":"b"}`,
+		`{"level":"info","label":"p2p","message":"c"}`,
+	} {
+		_, err = w.Write([]byte(line + "\n"))
+		require.Nil(t, err)
+	}
+	// the pending batch is sent when closed
+	require.Nil(t, w.Close())
+	require.Len(t, bodies, 1)
+
+	var push struct {
+		Streams []struct {
+			Stream map[string]string `json:"stream"`
+			Values [][2]string       `json:"values"`
+		} `json:"streams"`
+	}
+	require.Nil(t, json.Unmarshal([]byte(bodies[0]), &push))
+	require.Len(t, push.Streams, 2)
+	require.Equal(t, map[string]string{"label": "p2p", "level": "info"}, push.Streams[0].Stream)
+	require.Len(t, push.Streams[0].Values, 2)
+	ts, _ := time.ParseInLocation(DefaultTimeFormat, "2022-02-11 16:04:05.000", time.Local)
+	require.Equal(t, ts.UnixNano(), mustAtoi(t, push.Streams[0].Values[0][0]))
+	require.Contains(t, push.Streams[0].Values[1][1], `"message":"c"`)
+	require.Equal(t, map[string]string{"label": "p2p", "level": "warn"}, push.Streams[1].Stream)
+	require.Equal(t, HTTPStats{Sent: 3, Requests: 1}, w.Stats())
+
+	_, err = w.Write([]byte(`{}`))
+	require.Equal(t, ErrHTTPClosed, err)
+}
+
+func mustAtoi(t *testing.T, s string) int64 {
+	var n int64
+	for _, c := range s {
+		require.True(t, c >= '0' && c <= '9')
+		n = n*10 + int64(c-'0')
+	}
+	return n
+}
+
+func TestHTTPWriterElasticsearchRetry(t *testing.T) {
+	var calls int32
+	received := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		switch atomic.AddInt32(&calls, 1) {
+		case 1:
+			rw.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			rw.WriteHeader(http.StatusTooManyRequests)
+		default:
+			require.Equal(t, "application/x-ndjson", r.Header.Get("Content-Type"))
+			b, _ := ioutil.ReadAll(r.Body)
+			received <- string(b)
+			_, _ = rw.Write([]byte(`{"errors":true,"items":[{"create":{"status":201}},{"create":{"status":400}}]}`))
+		}
+	}))
+	defer srv.Close()
+
+	w, err := NewHTTPWriter(HTTPElasticsearch, srv.URL+"/_bulk", WithElasticsearchIndex("logs"),
+		WithHTTPBatch(80, time.Hour), WithHTTPRetry(3, time.Millisecond, 10*time.Millisecond))
+	require.Nil(t, err)
+	_, err = w.Write([]byte(`{"level":"info","label":"p2p","message":"first"}`))
+	require.Nil(t, err)
+	_, err = w.Write([]byte(`{"level":"error","message":"second"}`))
+	require.Nil(t, err)
+	var body string
+	select {
+	case body = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("bulk request not received")
+	}
+	require.Nil(t, w.Close())
+	lines := strings.Split(strings.TrimSuffix(body, "\n"), "\n")
+	require.Len(t, lines, 4)
+	require.Equal(t, `{"create":{"_index":"logs"}}`, lines[0])
+	require.Equal(t, `{"log":{"level":"info","logger":"p2p"},"message":"first","ecs":{"version":"1.6.0"}}`, lines[1])
+	require.Equal(t, `{"log":{"level":"error"},"message":"second","ecs":{"version":"1.6.0"}}`, lines[3])
+	require.Equal(t, HTTPStats{Sent: 1, Failed: 1, Requests: 3, Retries: 2}, w.Stats())
+}
+
+func TestHTTPWriterGiveUp(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+	w, err := NewHTTPWriter(HTTPLoki, srv.URL, WithHTTPRetry(3, time.Millisecond, time.Millisecond))
+	require.Nil(t, err)
+	_, err = w.Write([]byte(`{"message":"a"}`))
+	require.Nil(t, err)
+	require.Nil(t, w.Close())
+	// 4xx is not retried
+	require.Equal(t, HTTPStats{Failed: 1, Requests: 1}, w.Stats())
+
+	l := NewRZeroLogger(DisableConsolePrint(), WithHTTPOutput(HTTPLoki, srv.URL))
+	l.Info().Msg("a")
+	require.Nil(t, l.Close())
+
+	_, err = NewHTTPWriter(HTTPLoki, "loki:3100")
+	require.NotNil(t, err)
+}
+
+func TestHTTPWriterRetryAside(t *testing.T) {
+	var calls int32
+	sent := make(chan struct{}, 100)
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		rw.WriteHeader(http.StatusNoContent)
+		sent <- struct{}{}
+	}))
+	defer srv.Close()
+
+	// the first batch waits for an hour to be retried
+	w, err := NewHTTPWriter(HTTPLoki, srv.URL, WithHTTPBatch(1, time.Hour), WithHTTPRetry(3, time.Hour, time.Hour))
+	require.Nil(t, err)
+	for i := 0; i < 40; i++ {
+		_, err = w.Write([]byte(`{"message":"a"}`))
+		require.Nil(t, err)
+		if i == 0 {
+			// the others are queued behind the failed one
+			for atomic.LoadInt32(&calls) == 0 {
+				time.Sleep(time.Millisecond)
+			}
+		}
+		time.Sleep(time.Millisecond)
+	}
+	for i := 1; i < 40; i++ {
+		select {
+		case <-sent:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%d batches sent behind the retry, want 39", i-1)
+		}
+	}
+	require.Nil(t, w.Close())
+	require.Equal(t, HTTPStats{Sent: 39, Failed: 1, Requests: 40}, w.Stats())
+}
+
+func TestHTTPWriterCloseGivesUp(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	w, err := NewHTTPWriter(HTTPLoki, srv.URL, WithHTTPRetry(10, time.Hour, time.Hour))
+	require.Nil(t, err)
+	_, err = w.Write([]byte(`{"message":"a"}`))
+	require.Nil(t, err)
+
+	// the retry waiting for an hour is given up
+	closed := make(chan error, 1)
+	go func() {
+		closed <- w.Close()
+	}()
+	select {
+	case err = <-closed:
+		require.Nil(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close waits for the retries")
+	}
+	require.Equal(t, HTTPStats{Failed: 1, Requests: 1}, w.Stats())
+	require.Equal(t, httpTimeout, defaultHTTPClient.Timeout)
+}
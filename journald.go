@@ -0,0 +1,203 @@
+package rzerolog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/rs/zerolog"
+)
+
+var _ io.WriteCloser = (*JournaldWriter)(nil)
+
+// DefaultJournaldSocket is the socket of the native journal protocol.
+const DefaultJournaldSocket = "/run/systemd/journal/socket"
+
+// JournaldWriter parses the JSON input and sends it to systemd-journald in the native journal protocol.
+//
+//	message     => MESSAGE
+//	level       => PRIORITY, the syslog severity
+//	label       => SYSLOG_IDENTIFIER, Identifier if there is no label
+//	caller      => CODE_FILE, CODE_LINE
+//	caller_func => CODE_FUNC
+//	other       => the uppercase name, such as "peer_id" => PEER_ID
+//
+// The other fields named as the fields above are prefixed with "FIELD_", such as "priority" => FIELD_PRIORITY.
+// The time is not sent, journald records the time of entries itself.
+// The entries too large for a datagram are passed in a sealed memfd.
+// Sending times out in a few seconds, and a failed socket is redialed with exponential backoff,
+// the events are dropped with ErrSinkBackoff until then.
+//
+// NOTE: journald is only available on linux, NewJournaldWriter fails on the other platforms.
+type JournaldWriter struct {
+	Enable bool
+	// Addr is the journal socket, DefaultJournaldSocket if it is empty.
+	Addr string
+	// Identifier is the SYSLOG_IDENTIFIER of entries without label, the name of the program if it is empty.
+	Identifier string
+
+	mu   sync.Mutex
+	conn sinkConn
+	// maxDatagram is the size of the entries passed in memfd directly, no limit if it is 0
+	maxDatagram int
+}
+
+// JournaldOption configures a JournaldWriter.
+type JournaldOption func(w *JournaldWriter)
+
+// WithJournaldIdentifier set the SYSLOG_IDENTIFIER of entries without label.
+func WithJournaldIdentifier(identifier string) JournaldOption {
+	return func(w *JournaldWriter) {
+		w.Identifier = identifier
+	}
+}
+
+// Write sends the JSON input as a journal entry.
+func (w *JournaldWriter) Write(p []byte) (n int, err error) {
+	if !w.Enable {
+		return len(p), nil
+	}
+
+	evt := getConsoleEvent()
+	defer evt.release()
+
+	if err = evt.parse(p); err != nil {
+		return n, fmt.Errorf("cannot decode event: %s", err)
+	}
+	evt.buf = w.appendEntry(evt.buf, evt)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err = w.send(evt.buf); err != nil {
+		if err == ErrSinkBackoff || w.conn.backoff > 0 {
+			return n, err
+		}
+		// journald may be restarted, redial and resend once
+		if err = w.send(evt.buf); err != nil {
+			return n, err
+		}
+	}
+	return len(p), nil
+}
+
+// Close closes the connection.
+func (w *JournaldWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.conn.close()
+}
+
+// appendEntry appends evt encoded in the native journal protocol.
+func (w *JournaldWriter) appendEntry(dst []byte, evt *consoleEvent) []byte {
+	if f, ok := evt.field(zerolog.MessageFieldName); ok {
+		dst = appendJournalField(dst, []byte("MESSAGE"), f.value)
+	}
+	severity := 6
+	if f, ok := evt.field(zerolog.LevelFieldName); ok {
+		if l, ok := levelOf(f.value); ok {
+			severity = syslogSeverity(l)
+		}
+	}
+	dst = append(dst, "PRIORITY="...)
+	dst = strconv.AppendInt(dst, int64(severity), 10)
+	dst = append(dst, '\n')
+
+	if f, ok := evt.field(LabelFieldName); ok && len(f.value) > 0 {
+		dst = appendJournalField(dst, []byte("SYSLOG_IDENTIFIER"), f.value)
+	} else {
+		identifier := w.Identifier
+		if identifier == "" {
+			identifier = filepath.Base(os.Args[0])
+		}
+		dst = appendJournalField(dst, []byte("SYSLOG_IDENTIFIER"), []byte(identifier))
+	}
+	if f, ok := evt.field(zerolog.CallerFieldName); ok {
+		file, line := f.value, []byte(nil)
+		if i := bytes.LastIndexByte(f.value, ':'); i > 0 && isDigits(f.value[i+1:]) {
+			file, line = f.value[:i], f.value[i+1:]
+		}
+		dst = appendJournalField(dst, []byte("CODE_FILE"), file)
+		if line != nil {
+			dst = appendJournalField(dst, []byte("CODE_LINE"), line)
+		}
+	}
+	if f, ok := evt.field(CallerFuncFieldName); ok {
+		dst = appendJournalField(dst, []byte("CODE_FUNC"), f.value)
+	}
+
+	for i := range evt.fields {
+		switch string(evt.fields[i].key) {
+		case zerolog.MessageFieldName, zerolog.LevelFieldName, zerolog.TimestampFieldName,
+			LabelFieldName, zerolog.CallerFieldName, CallerFuncFieldName:
+			continue
+		}
+		evt.order = append(evt.order, i)
+	}
+	evt.dedupOrder()
+	var name [64]byte
+	for _, idx := range evt.order {
+		f := &evt.fields[idx]
+		key := journalFieldName(name[:0], f.key)
+		if len(key) == 0 {
+			continue
+		}
+		if isJournalReservedField(key) {
+			key = journalFieldName(append(name[:0], "FIELD_"...), f.key)
+		}
+		dst = appendJournalField(dst, key, f.value)
+	}
+	return dst
+}
+
+// isJournalReservedField reports whether name is one of the fields mapped from the parts.
+func isJournalReservedField(name []byte) bool {
+	switch string(name) {
+	case "MESSAGE", "PRIORITY", "SYSLOG_IDENTIFIER", "CODE_FILE", "CODE_LINE", "CODE_FUNC":
+		return true
+	}
+	return false
+}
+
+// appendJournalField appends a field, the values with newlines are sized by a little endian length.
+func appendJournalField(dst, name, value []byte) []byte {
+	dst = append(dst, name...)
+	if bytes.IndexByte(value, '\n') < 0 {
+		dst = append(dst, '=')
+		dst = append(dst, value...)
+		return append(dst, '\n')
+	}
+	dst = append(dst, '\n')
+	var size [8]byte
+	binary.LittleEndian.PutUint64(size[:], uint64(len(value)))
+	dst = append(dst, size[:]...)
+	dst = append(dst, value...)
+	return append(dst, '\n')
+}
+
+// journalFieldName appends the journal field name of key to dst, which is made of uppercase letters,
+// digits and underscores, and starts with a letter, at most 64 bytes.
+func journalFieldName(dst, key []byte) []byte {
+	for _, c := range key {
+		switch {
+		case c >= 'a' && c <= 'z':
+			c -= 'a' - 'A'
+		case c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '_':
+		default:
+			c = '_'
+		}
+		// the names starting with '_' are trusted fields of journald
+		if len(dst) == 0 && (c == '_' || c >= '0' && c <= '9') {
+			continue
+		}
+		if len(dst) == 64 {
+			break
+		}
+		dst = append(dst, c)
+	}
+	return dst
+}
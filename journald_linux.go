@@ -0,0 +1,120 @@
+//go:build linux
+// +build linux
+
+package rzerolog
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+// memfdCreateTrap is the syscall number of memfd_create, which is not in package syscall.
+var memfdCreateTrap = map[string]uintptr{
+	"386":     356,
+	"amd64":   319,
+	"arm":     385,
+	"arm64":   279,
+	"ppc64":   360,
+	"ppc64le": 360,
+	"riscv64": 279,
+	"s390x":   350,
+}
+
+const (
+	mfdCloexec      = 0x1
+	mfdAllowSealing = 0x2
+	fcntlAddSeals   = 1033
+	memfdSeals      = 0x1 | 0x2 | 0x4 | 0x8 // F_SEAL_SEAL | F_SEAL_SHRINK | F_SEAL_GROW | F_SEAL_WRITE
+)
+
+// NewJournaldWriter creates a JournaldWriter sending to the journal socket at addr.
+// An empty addr means DefaultJournaldSocket.
+func NewJournaldWriter(addr string, opts ...JournaldOption) (*JournaldWriter, error) {
+	if addr == "" {
+		addr = DefaultJournaldSocket
+	}
+	w := &JournaldWriter{Enable: true, Addr: addr}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w, nil
+}
+
+// send sends the entry to journald, dialing it if it is not connected.
+func (w *JournaldWriter) send(entry []byte) error {
+	if _, err := w.conn.get(func(d *net.Dialer) (net.Conn, error) {
+		return d.Dial("unixgram", w.Addr)
+	}); err != nil {
+		return err
+	}
+	return w.conn.do(func(conn net.Conn) error {
+		return w.sendEntry(conn.(*net.UnixConn), entry)
+	})
+}
+
+// sendEntry writes the entry to conn, or passes it in a memfd if it is too large for a datagram.
+func (w *JournaldWriter) sendEntry(conn *net.UnixConn, entry []byte) error {
+	if w.maxDatagram <= 0 || len(entry) <= w.maxDatagram {
+		_, err := conn.Write(entry)
+		if err == nil || !(errors.Is(err, syscall.EMSGSIZE) || errors.Is(err, syscall.ENOBUFS)) {
+			return err
+		}
+	}
+	f, err := journalEntryFile(entry)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	rc, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	// WriteMsgUnix refuses a connected socket without address, send the fd by sendmsg directly
+	rights := syscall.UnixRights(int(f.Fd()))
+	var serr error
+	if err = rc.Write(func(fd uintptr) bool {
+		serr = syscall.Sendmsg(int(fd), nil, rights, nil, 0)
+		return serr != syscall.EAGAIN
+	}); err != nil {
+		return err
+	}
+	return serr
+}
+
+// journalEntryFile returns a sealed memfd with the entry,
+// or an unlinked file in /dev/shm if memfd is not supported.
+func journalEntryFile(entry []byte) (*os.File, error) {
+	if trap, ok := memfdCreateTrap[runtime.GOARCH]; ok {
+		name := []byte("rzerolog-journal\x00")
+		fd, _, errno := syscall.Syscall(trap, uintptr(unsafe.Pointer(&name[0])), mfdCloexec|mfdAllowSealing, 0)
+		if errno == 0 {
+			f := os.NewFile(fd, "rzerolog-journal")
+			if _, err := f.Write(entry); err != nil {
+				_ = f.Close()
+				return nil, err
+			}
+			// journald only accepts sealed memfds
+			if _, _, errno = syscall.Syscall(syscall.SYS_FCNTL, fd, fcntlAddSeals, memfdSeals); errno != 0 {
+				_ = f.Close()
+				return nil, fmt.Errorf("cannot seal journal memfd: %v", errno)
+			}
+			return f, nil
+		}
+	}
+	f, err := ioutil.TempFile("/dev/shm", "rzerolog-journal-")
+	if err != nil {
+		return nil, err
+	}
+	_ = os.Remove(f.Name())
+	if _, err = f.Write(entry); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return f, nil
+}
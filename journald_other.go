@@ -0,0 +1,15 @@
+//go:build !linux
+// +build !linux
+
+package rzerolog
+
+import "errors"
+
+// NewJournaldWriter always fails, journald is only available on linux.
+func NewJournaldWriter(addr string, opts ...JournaldOption) (*JournaldWriter, error) {
+	return nil, errors.New("journald is not supported on this platform")
+}
+
+func (w *JournaldWriter) send(entry []byte) error {
+	return errors.New("journald is not supported on this platform")
+}
@@ -0,0 +1,107 @@
+//go:build linux
+// +build linux
+
+package rzerolog
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func listenJournal(t *testing.T) (*net.UnixConn, string) {
+	dir, err := ioutil.TempDir("", "rzerolog-journal")
+	require.Nil(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	addr := filepath.Join(dir, "socket")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: addr, Net: "unixgram"})
+	require.Nil(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn, addr
+}
+
+func TestJournaldWriter(t *testing.T) {
+	conn, addr := listenJournal(t)
+	l := NewRZeroLogger(DisableConsolePrint(), WithNoCaller(), WithLabel("p2p"), WithJournaldOutput(addr))
+	l.Warn().Str("peer_id", "a").Str("_secret", "x").Str("stack", "a\nb").
+		Str("priority", "high").Str("Message", "m").Msg("dial failed")
+	buf := make([]byte, 4096)
+	n, err := conn.Read(buf)
+	require.Nil(t, err)
+	require.Nil(t, l.Close())
+
+	stack := []byte("STACK\n")
+	stack = append(stack, 3, 0, 0, 0, 0, 0, 0, 0)
+	stack = append(stack, "a\nb\n"...)
+	require.Equal(t, "MESSAGE=dial failed\nPRIORITY=4\nSYSLOG_IDENTIFIER=p2p\nPEER_ID=a\nSECRET=x\n"+string(stack)+
+		"FIELD_PRIORITY=high\nFIELD_MESSAGE=m\n", string(buf[:n]))
+}
+
+func TestJournaldWriterBackoff(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rzerolog-journal")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	addr := filepath.Join(dir, "socket")
+
+	w, err := NewJournaldWriter(addr)
+	require.Nil(t, err)
+	_, err = w.Write([]byte(`{"level":"info","message":"a"}`))
+	require.Error(t, err)
+	// the absent journald is not redialed for every event
+	_, err = w.Write([]byte(`{"level":"info","message":"b"}`))
+	require.Equal(t, ErrSinkBackoff, err)
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: addr, Net: "unixgram"})
+	require.Nil(t, err)
+	defer conn.Close()
+	w.conn.retryAt = time.Now()
+	_, err = w.Write([]byte(`{"level":"info","message":"c"}`))
+	require.Nil(t, err)
+	buf := make([]byte, 256)
+	n, err := conn.Read(buf)
+	require.Nil(t, err)
+	require.True(t, strings.HasPrefix(string(buf[:n]), "MESSAGE=c\n"))
+	require.Nil(t, w.Close())
+}
+
+func TestJournaldWriterMemfd(t *testing.T) {
+	conn, addr := listenJournal(t)
+	w, err := NewJournaldWriter(addr, WithJournaldIdentifier("app"))
+	require.Nil(t, err)
+	defer w.Close()
+	w.maxDatagram = 16
+	_, err = w.Write([]byte(`{"level":"info","message":"hello world","caller":"/src/main.go:12"}`))
+	require.Nil(t, err)
+
+	buf, oob := make([]byte, 64), make([]byte, 64)
+	n, oobn, _, _, err := conn.ReadMsgUnix(buf, oob)
+	require.Nil(t, err)
+	require.Equal(t, 0, n)
+	msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	require.Nil(t, err)
+	require.Len(t, msgs, 1)
+	fds, err := syscall.ParseUnixRights(&msgs[0])
+	require.Nil(t, err)
+	f := os.NewFile(uintptr(fds[0]), "entry")
+	defer f.Close()
+	_, err = f.Seek(0, 0)
+	require.Nil(t, err)
+	entry, err := ioutil.ReadAll(f)
+	require.Nil(t, err)
+	require.Equal(t, "MESSAGE=hello world\nPRIORITY=6\nSYSLOG_IDENTIFIER=app\nCODE_FILE=/src/main.go\nCODE_LINE=12\n",
+		string(entry))
+}
+
+func TestJournalFieldName(t *testing.T) {
+	require.Equal(t, "REQ_ID", string(journalFieldName(nil, []byte("req.id"))))
+	require.Equal(t, "X", string(journalFieldName(nil, []byte("_1x"))))
+	require.Len(t, journalFieldName(nil, bytes.Repeat([]byte("a"), 100)), 64)
+}
@@ -1,41 +1,64 @@
 package rzerolog
 
 import (
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"strconv"
+	"time"
 )
 
 var (
-	_ io.Writer  = (*LogFileWriter)(nil)
-	_ FileWriter = (*osFileWriter)(nil)
+	_ io.WriteCloser = (*LogFileWriter)(nil)
+	_ FileWriter     = (*rawFileWriter)(nil)
 )
 
-type osFileWriter struct {
-	*os.File
+// rawFileWriter writes the log records to the output unchanged.
+type rawFileWriter struct {
+	io.WriteCloser
 }
 
-func (o *osFileWriter) SetOutput(file *os.File) error {
-	o.File = file
+func (o *rawFileWriter) SetOutput(w io.WriteCloser) error {
+	o.WriteCloser = w
 	return nil
 }
 
+// LogFileWriter writes log records into rolling log files.
+//
+// It is made of two layers: the format layer is a FileWriter which encodes the records,
+// and the storage layer is a File opened from a FileSystem,
+// which may be wrapped by StorageWrapper such as compression or encryption.
 type LogFileWriter struct {
 	enable bool
 	writer FileWriter
+	fs     FileSystem
+	// storage wrappers, the first one is the closest to the file
+	wrappers []StorageWrapper
 
 	fillPath string
+	// permission params, modes are enforced with chmod only if set explicitly
+	fileMode        os.FileMode
+	dirMode         os.FileMode
+	enforceFileMode bool
+	enforceDirMode  bool
+	gid             int
 	// time rolling params
 	timeRolling bool
+	location    *time.Location
+	now         func() time.Time
 	// size rolling params
 	sizeRolling  bool
 	fileSize     int64
 	maxFileCount int
 	// file params
 	logFileName     string
+	nameTemplate    *FileNameTemplate
+	label           string
 	currentFileName string
-	file            *os.File
+	file            File
+	// out is the file wrapped by storage wrappers
+	out io.WriteCloser
 
 	lockC chan struct{}
 }
@@ -49,39 +72,186 @@ func (f *LogFileWriter) initBase() error {
 	}
 	if f.file == nil {
 		// check log file path
-		err := os.MkdirAll(f.fillPath, 0777)
-		if err != nil {
+		if err := f.makeDir(); err != nil {
 			return err
 		}
-		newFileName := f.logFileName
-		if f.timeRolling {
-			newFileName = ParseTimeFormat(newFileName)
+		if f.nameTemplate == nil {
+			tmpl, err := ParseFileNameTemplate(f.logFileName)
+			if err != nil {
+				return err
+			}
+			f.nameTemplate = tmpl
 		}
-
 		// create new file
-		newFile, err := os.OpenFile(filepath.Join(f.fillPath, newFileName),
-			os.O_CREATE|os.O_APPEND|os.O_RDWR, 0666)
-		if err != nil {
+		if err := f.openFile(f.fileName(), os.O_CREATE|os.O_APPEND|os.O_RDWR); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// makeDir creates the log file path with the directory mode and group.
+// The mode and group are only applied to the last directory, the parents created are left to MkdirAll.
+func (f *LogFileWriter) makeDir() error {
+	fs := f.fileSystem()
+	if _, err := fs.Stat(f.fillPath); err == nil {
+		return nil
+	}
+	if err := fs.MkdirAll(f.fillPath, f.dirPerm()); err != nil {
+		return err
+	}
+	return f.applyPerm(f.fillPath, f.dirMode, f.enforceDirMode)
+}
+
+// openFile opens the named log file and makes it current.
+func (f *LogFileWriter) openFile(name string, flag int) error {
+	fileLoc := filepath.Join(f.fillPath, name)
+	newFile, err := f.fileSystem().OpenFile(fileLoc, flag, f.filePerm())
+	if err != nil {
+		return err
+	}
+	if err = f.applyPerm(fileLoc, f.fileMode, f.enforceFileMode); err != nil {
+		_ = newFile.Close()
+		return err
+	}
+	if err = f.setFile(newFile); err != nil {
+		return err
+	}
+	f.currentFileName = name
+	return nil
+}
+
+// applyPerm enforces the mode regardless of umask and changes the group of name.
+func (f *LogFileWriter) applyPerm(name string, mode os.FileMode, enforce bool) error {
+	if !enforce && f.gid < 0 {
+		return nil
+	}
+	fs, ok := f.fileSystem().(PermFileSystem)
+	if !ok {
+		return fmt.Errorf("cannot change the permission of %s: %T does not implement PermFileSystem", name, f.fileSystem())
+	}
+	if enforce {
+		if err := fs.Chmod(name, mode); err != nil {
+			return err
+		}
+	}
+	if f.gid >= 0 {
+		if err := fs.Chown(name, -1, f.gid); err != nil {
 			return err
 		}
+	}
+	return nil
+}
 
-		f.file = newFile
-		if err = f.writer.SetOutput(f.file); err != nil {
+func (f *LogFileWriter) filePerm() os.FileMode {
+	if f.fileMode == 0 && !f.enforceFileMode {
+		return DefaultFileMode
+	}
+	return f.fileMode
+}
+
+func (f *LogFileWriter) dirPerm() os.FileMode {
+	if f.dirMode == 0 && !f.enforceDirMode {
+		return DefaultDirMode
+	}
+	return f.dirMode
+}
+
+// setFile wraps the file with storage wrappers and sets it as the output of the format layer.
+func (f *LogFileWriter) setFile(file File) error {
+	var out io.WriteCloser = nopCloseFile{file}
+	for _, wrap := range f.wrappers {
+		w, err := wrap(out)
+		if err != nil {
+			_ = file.Close()
 			return err
 		}
-		f.currentFileName = newFileName
+		out = w
+	}
+	if len(f.wrappers) > 0 {
+		out = storedOutput{WriteCloser: out, file: file}
+	}
+	f.file = file
+	f.out = out
+	return f.writer.SetOutput(out)
+}
+
+// closeFile flushes the storage wrappers, then syncs and closes the current file.
+func (f *LogFileWriter) closeFile() error {
+	if f.file == nil {
+		return nil
+	}
+	err := f.out.Close()
+	if sErr := f.file.Sync(); err == nil {
+		err = sErr
+	}
+	if cErr := f.file.Close(); err == nil {
+		err = cErr
+	}
+	f.file, f.out = nil, nil
+	return err
+}
+
+// Close flushes and closes the current log file.
+func (f *LogFileWriter) Close() error {
+	if !f.enable || f.lockC == nil {
+		return nil
 	}
+	f.lockC <- struct{}{}
+	defer func() { <-f.lockC }()
+	return f.closeFile()
+}
+
+// nopCloseFile prevents storage wrappers from closing the file,
+// which is synced and closed by LogFileWriter itself.
+type nopCloseFile struct {
+	File
+}
+
+func (nopCloseFile) Close() error {
 	return nil
 }
 
+// storedOutput is the output wrapped by storage wrappers, with the file under them.
+type storedOutput struct {
+	io.WriteCloser
+	file File
+}
+
+// isNewOutput reports whether out set by SetOutput is a new or empty file,
+// or a stream wrapping the file which starts anew, such as a gzip stream.
+func isNewOutput(out io.Writer) bool {
+	if s, ok := out.(interface{ Stat() (os.FileInfo, error) }); ok {
+		if info, err := s.Stat(); err == nil && info.Size() > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// isNewFile reports whether out set by SetOutput is a new or empty file, or wraps one by storage wrappers.
+func isNewFile(out io.Writer) bool {
+	if s, ok := out.(storedOutput); ok {
+		return isNewOutput(s.file)
+	}
+	return isNewOutput(out)
+}
+
 func (f *LogFileWriter) Write(p []byte) (n int, err error) {
 	if !f.enable {
 		return len(p), nil
 	}
 	f.lockC <- struct{}{}
+	defer func() { <-f.lockC }()
 	if err = f.doTimeRolling(); err != nil {
 		return 0, err
 	}
+	if f.file == nil {
+		// the file was not reopened after a failed rolling
+		if err = f.openFile(f.fileName(), os.O_CREATE|os.O_APPEND|os.O_RDWR|os.O_SYNC); err != nil {
+			return 0, err
+		}
+	}
 	n, err = f.writer.Write(p)
 	if err != nil {
 		return 0, err
@@ -89,7 +259,6 @@ func (f *LogFileWriter) Write(p []byte) (n int, err error) {
 	if err = f.doSizeRolling(len(p)); err != nil {
 		return 0, err
 	}
-	<-f.lockC
 	return
 }
 
@@ -98,25 +267,43 @@ func (f *LogFileWriter) doTimeRolling() error {
 		return nil
 	}
 
-	newFileName := ParseTimeFormat(f.logFileName)
+	newFileName := f.fileName()
 	if newFileName != f.currentFileName {
+		_ = f.closeFile()
 		// create new file
-		newFile, err := os.OpenFile(filepath.Join(f.fillPath, newFileName),
-			os.O_CREATE|os.O_APPEND|os.O_RDWR|os.O_SYNC, 0666)
-		if err != nil {
-			return err
-		}
-		oldFile := f.file
-		f.file = newFile
-		if err = f.writer.SetOutput(f.file); err != nil {
-			return err
-		}
-		f.currentFileName = newFileName
-		defer oldFile.Close()
+		return f.openFile(newFileName, os.O_CREATE|os.O_APPEND|os.O_RDWR|os.O_SYNC)
 	}
 	return nil
 }
 
+func (f *LogFileWriter) fileSystem() FileSystem {
+	if f.fs == nil {
+		return OSFileSystem{}
+	}
+	return f.fs
+}
+
+// fileName renders the name of the log file which should be written now.
+func (f *LogFileWriter) fileName() string {
+	if f.nameTemplate.isLegacy() && !f.timeRolling {
+		return f.logFileName
+	}
+	return f.nameTemplate.Execute(f.rollingTime(), f.label)
+}
+
+// rollingTime returns the current time in the rolling time zone.
+func (f *LogFileWriter) rollingTime() time.Time {
+	now := time.Now
+	if f.now != nil {
+		now = f.now
+	}
+	loc := f.location
+	if loc == nil {
+		loc = time.Local
+	}
+	return now().In(loc)
+}
+
 func (f *LogFileWriter) currFileSize() (int64, error) {
 	fInfo, err := f.file.Stat()
 	if err != nil {
@@ -139,35 +326,26 @@ func (f *LogFileWriter) doSizeRolling(append int) error {
 		return nil
 	}
 	// rename full file
-	err = f.renameCurrentFile()
-	if err != nil {
-		return err
-	}
+	renameErr := f.renameCurrentFile()
 
-	// create new file
-	newFile, err := os.OpenFile(filepath.Join(f.fillPath, f.currentFileName),
-		os.O_CREATE|os.O_APPEND|os.O_RDWR|os.O_SYNC, 0666)
-	if err != nil {
+	// create new file, the full file is reopened if it was not renamed
+	if err = f.openFile(f.currentFileName, os.O_CREATE|os.O_APPEND|os.O_RDWR|os.O_SYNC); err != nil {
 		return err
 	}
-	f.file = newFile
-	if err = f.writer.SetOutput(f.file); err != nil {
-		return err
+	if renameErr != nil {
+		return renameErr
 	}
 	fileLoc := filepath.Join(f.fillPath, f.currentFileName)
-	go f.renameOldFiles(fileLoc)
+	f.renameOldFiles(fileLoc)
 	return nil
 }
 
 func (f *LogFileWriter) renameCurrentFile() error {
-	if err := f.file.Sync(); err != nil {
-		return err
-	}
-	if err := f.file.Close(); err != nil {
+	if err := f.closeFile(); err != nil {
 		return err
 	}
 	fileLoc := filepath.Join(f.fillPath, f.currentFileName)
-	return os.Rename(fileLoc, fileLoc+".0")
+	return f.fileSystem().Rename(fileLoc, fileLoc+".0")
 }
 
 func (f *LogFileWriter) renameOldFiles(fileLoc string) {
@@ -175,9 +353,9 @@ func (f *LogFileWriter) renameOldFiles(fileLoc string) {
 		curr := fileLoc + "." + strconv.Itoa(i-1)
 		now := fileLoc + "." + strconv.Itoa(i)
 		if i == f.maxFileCount {
-			_ = os.Remove(now)
+			_ = f.fileSystem().Remove(now)
 			continue
 		}
-		_ = os.Rename(curr, now)
+		_ = f.fileSystem().Rename(curr, now)
 	}
 }
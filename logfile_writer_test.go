@@ -0,0 +1,241 @@
+package rzerolog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func newTestLogFileWriter(t *testing.T, name string, zone string, now *time.Time) *LogFileWriter {
+	cfg := defaultConfig()
+	cfg.apply(
+		WithLogFilePath(t.TempDir()),
+		WithLogFileName(name),
+		EnableTimeRolling(),
+		WithRollingTimezone(zone),
+	)
+	cfg.fw.now = func() time.Time { return *now }
+	require.Nil(t, cfg.fw.initBase())
+	return cfg.fw
+}
+
+func writeAt(t *testing.T, w *LogFileWriter, now *time.Time, at time.Time) {
+	*now = at
+	_, err := w.Write([]byte(at.UTC().Format(time.RFC3339) + "\n"))
+	require.Nil(t, err)
+}
+
+func logFileNames(t *testing.T, dir string) []string {
+	infos, err := ioutil.ReadDir(dir)
+	require.Nil(t, err)
+	var names []string
+	for _, info := range infos {
+		names = append(names, info.Name())
+	}
+	return names
+}
+
+func TestTimeRollingUTCAcrossDST(t *testing.T) {
+	// 2022-03-13 02:00 America/New_York springs forward to 03:00,
+	// 2022-11-06 02:00 America/New_York falls back to 01:00.
+	for _, start := range []time.Time{
+		time.Date(2022, 3, 13, 5, 30, 0, 0, time.UTC),
+		time.Date(2022, 11, 6, 4, 30, 0, 0, time.UTC),
+	} {
+		now := start
+		w := newTestLogFileWriter(t, "{yyyy}{MM}{dd}{HH}.log", "UTC", &now)
+		for i := 0; i < 4; i++ {
+			writeAt(t, w, &now, start.Add(time.Duration(i)*time.Hour))
+		}
+		var want []string
+		for i := 0; i < 4; i++ {
+			want = append(want, start.Add(time.Duration(i)*time.Hour).Format("2006010215")+".log")
+		}
+		require.Equal(t, want, logFileNames(t, w.fillPath))
+	}
+}
+
+func TestTimeRollingZoneAcrossDST(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("no zoneinfo database:", err)
+	}
+
+	// spring forward: 01:30 EST -> 03:30 EDT, the hour 02 does not exist
+	start := time.Date(2022, 3, 13, 1, 30, 0, 0, ny)
+	now := start
+	w := newTestLogFileWriter(t, "{HH}.log", "America/New_York", &now)
+	writeAt(t, w, &now, start)
+	writeAt(t, w, &now, start.Add(time.Hour))
+	require.Equal(t, []string{"01.log", "03.log"}, logFileNames(t, w.fillPath))
+
+	// fall back: 01:30 EDT and 01:30 EST are both written to the same file
+	start = time.Date(2022, 11, 6, 0, 30, 0, 0, ny)
+	now = start
+	w = newTestLogFileWriter(t, "{HH}.log", "America/New_York", &now)
+	for i := 0; i < 4; i++ {
+		writeAt(t, w, &now, start.Add(time.Duration(i)*time.Hour))
+	}
+	require.Equal(t, []string{"00.log", "01.log", "02.log"}, logFileNames(t, w.fillPath))
+	b, err := ioutil.ReadFile(filepath.Join(w.fillPath, "01.log"))
+	require.Nil(t, err)
+	require.Equal(t, "2022-11-06T05:30:00Z\n2022-11-06T06:30:00Z\n", string(b))
+}
+
+func TestTimeRollingLegacyName(t *testing.T) {
+	start := time.Date(2022, 2, 11, 23, 30, 0, 0, time.UTC)
+	now := start
+	w := newTestLogFileWriter(t, "yyyyMMdd.log", "Asia/Shanghai", &now)
+	writeAt(t, w, &now, start)
+	require.Equal(t, []string{"20220212.log"}, logFileNames(t, w.fillPath))
+}
+
+func TestLoadRollingTimezone(t *testing.T) {
+	loc, err := LoadRollingTimezone("")
+	require.Nil(t, err)
+	require.Equal(t, time.Local, loc)
+	loc, err = LoadRollingTimezone("UTC")
+	require.Nil(t, err)
+	require.Equal(t, time.UTC, loc)
+	_, err = LoadRollingTimezone("Mars/Olympus_Mons")
+	require.Error(t, err)
+	require.Panics(t, func() { WithRollingTimezone("Mars/Olympus_Mons") })
+}
+
+func newMemLogFileWriter(t *testing.T, fs *MemFileSystem, opts ...Option) *LogFileWriter {
+	cfg := defaultConfig()
+	cfg.apply(append([]Option{EnableLogFiles(), WithFileSystem(fs), WithLogFilePath("logs"), WithLogFileName("app.log")}, opts...)...)
+	require.Nil(t, cfg.fw.initBase())
+	return cfg.fw
+}
+
+func TestSizeRollingMemFileSystem(t *testing.T) {
+	fs := NewMemFileSystem()
+	w := newMemLogFileWriter(t, fs, WithSizeRolling(1, 3))
+	w.fileSize = 10
+
+	for _, line := range []string{"aaaaaaaa\n", "bbbbbbbb\n", "cccccccc\n", "dddddddd\n"} {
+		_, err := w.Write([]byte(line))
+		require.Nil(t, err)
+	}
+	require.Equal(t, []string{"logs/app.log", "logs/app.log.1", "logs/app.log.2"}, fs.Files())
+	b, err := fs.ReadFile("logs/app.log.1")
+	require.Nil(t, err)
+	require.Equal(t, "dddddddd\n", string(b))
+	b, err = fs.ReadFile("logs/app.log.2")
+	require.Nil(t, err)
+	require.Equal(t, "cccccccc\n", string(b))
+}
+
+func TestLogFileWriterDiskFull(t *testing.T) {
+	fs := NewMemFileSystem()
+	w := newMemLogFileWriter(t, fs)
+	fs.SetCapacity(8)
+
+	_, err := w.Write([]byte("12345\n"))
+	require.Nil(t, err)
+	_, err = w.Write([]byte("67890\n"))
+	require.True(t, errors.Is(err, syscall.ENOSPC), err)
+
+	// the writer is still usable once space is available
+	fs.SetCapacity(0)
+	_, err = w.Write([]byte("abc\n"))
+	require.Nil(t, err)
+	b, err := fs.ReadFile("logs/app.log")
+	require.Nil(t, err)
+	require.Equal(t, "12345\n67abc\n", string(b))
+}
+
+func TestLogFileWriterPermissionDenied(t *testing.T) {
+	fs := NewMemFileSystem()
+	require.Nil(t, fs.MkdirAll("logs", 0555))
+	cfg := defaultConfig()
+	cfg.apply(EnableLogFiles(), WithFileSystem(fs), WithLogFilePath("logs"))
+	err := cfg.fw.initBase()
+	require.True(t, errors.Is(err, os.ErrPermission), err)
+
+	fs = NewMemFileSystem()
+	w := newMemLogFileWriter(t, fs, WithSizeRolling(1, 3))
+	w.fileSize = 4
+	fs.InjectError(MemFSOpRename, "logs/app.log", os.ErrPermission)
+	_, err = w.Write([]byte("12345\n"))
+	require.True(t, errors.Is(err, os.ErrPermission), err)
+}
+
+func TestGzipStorage(t *testing.T) {
+	fs := NewMemFileSystem()
+	w := newMemLogFileWriter(t, fs, WithLogFileName("app.log.gz"), WithLogFormat(LogFormatConsoleText),
+		WithStorageWrapper(GzipStorage(gzip.BestSpeed)))
+
+	_, err := w.Write([]byte(`{"level":"info","message":"first"}`))
+	require.Nil(t, err)
+	require.Nil(t, w.Close())
+	// the file is reopened and a new gzip member is appended
+	_, err = w.Write([]byte(`{"level":"warn","message":"second"}`))
+	require.Nil(t, err)
+	require.Nil(t, w.Close())
+
+	b, err := fs.ReadFile("logs/app.log.gz")
+	require.Nil(t, err)
+	zr, err := gzip.NewReader(bytes.NewReader(b))
+	require.Nil(t, err)
+	text, err := ioutil.ReadAll(zr)
+	require.Nil(t, err)
+	require.Equal(t, "<nil> INF first\n<nil> WRN second\n", string(text))
+}
+
+func TestLogFilePermission(t *testing.T) {
+	fs := NewMemFileSystem()
+	w := newMemLogFileWriter(t, fs, WithLogFilePath("var/logs"), WithSizeRolling(1, 3),
+		WithLogFileMode(0640), WithLogDirMode(0750), WithLogFileGroup("1234"))
+	w.fileSize = 4
+	_, err := w.Write([]byte("12345\n"))
+	require.Nil(t, err)
+
+	info, err := fs.Stat("var/logs")
+	require.Nil(t, err)
+	require.Equal(t, os.FileMode(0750), info.Mode().Perm())
+	for _, name := range []string{"var/logs/app.log", "var/logs/app.log.1"} {
+		info, err = fs.Stat(name)
+		require.Nil(t, err)
+		require.Equal(t, os.FileMode(0640), info.Mode().Perm(), name)
+		_, gid, err := fs.Owner(name)
+		require.Nil(t, err)
+		require.Equal(t, 1234, gid, name)
+	}
+
+	fs = NewMemFileSystem()
+	fs.InjectError(MemFSOpChown, "", os.ErrPermission)
+	cfg := defaultConfig()
+	cfg.apply(EnableLogFiles(), WithFileSystem(fs), WithLogFileGroup("1234"))
+	require.True(t, errors.Is(cfg.fw.initBase(), os.ErrPermission))
+
+	// the file systems without Chmod and Chown only fail if the permission is set
+	basic := struct{ FileSystem }{NewMemFileSystem()}
+	cfg = defaultConfig()
+	cfg.apply(EnableLogFiles(), WithFileSystem(basic), WithLogFilePath("logs"))
+	require.Nil(t, cfg.fw.initBase())
+	cfg = defaultConfig()
+	cfg.apply(EnableLogFiles(), WithFileSystem(basic), WithLogFilePath("logs"), WithLogFileMode(0640))
+	require.Error(t, cfg.fw.initBase())
+}
+
+func TestParseFileMode(t *testing.T) {
+	mode, err := ParseFileMode("0640")
+	require.Nil(t, err)
+	require.Equal(t, os.FileMode(0640), mode)
+	mode, err = ParseFileMode("750")
+	require.Nil(t, err)
+	require.Equal(t, os.FileMode(0750), mode)
+	for _, s := range []string{"", "0x1ff", "0999", "01777"} {
+		_, err = ParseFileMode(s)
+		require.Error(t, err, s)
+	}
+}
@@ -0,0 +1,174 @@
+package rzerolog
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/rs/zerolog"
+)
+
+var (
+	_ io.Writer  = (*LogfmtWriter)(nil)
+	_ FileWriter = (*LogfmtWriter)(nil)
+)
+
+// logfmtLeadingKeys are the fields printed first in logfmt, in this order.
+var logfmtLeadingKeys = [...]string{
+	zerolog.TimestampFieldName,
+	zerolog.LevelFieldName,
+	LabelFieldName,
+	zerolog.MessageFieldName,
+	zerolog.CallerFieldName,
+	CallerFuncFieldName,
+}
+
+// LogfmtMessageFieldName is the key of the message in logfmt.
+const LogfmtMessageFieldName = "msg"
+
+// LogfmtWriter parses the JSON input and writes it in logfmt to Out, such as
+//
+//	time="2022-02-11 16:04:05.000" level=info label=p2p msg="peer connected" peer=12D3KooW
+//
+// The time, level, label, message and caller are printed first,
+// then the other fields in the order they are added to the event.
+// Objects and arrays are printed as quoted JSON.
+type LogfmtWriter struct {
+	Enable bool
+	// Out is the output destination.
+	Out io.Writer
+
+	// ErrOut is the output of the events at or above ErrLevel, all the events are written to Out if it is nil.
+	ErrOut io.Writer
+	// ErrLevel is the lowest level of the events written to ErrOut.
+	ErrLevel Level
+	// lastErr is 1 if the last event was written to ErrOut
+	lastErr uint32
+}
+
+// NewLogfmtWriter creates a LogfmtWriter writing to out.
+func NewLogfmtWriter(out io.Writer) *LogfmtWriter {
+	return &LogfmtWriter{Enable: true, Out: out}
+}
+
+func (w *LogfmtWriter) SetOutput(out io.WriteCloser) error {
+	w.Out = out
+	return nil
+}
+
+// Write transforms the JSON input into a logfmt line and appends it to w.Out.
+func (w *LogfmtWriter) Write(p []byte) (n int, err error) {
+	if !w.Enable {
+		return len(p), nil
+	}
+
+	evt := getConsoleEvent()
+	defer evt.release()
+
+	if err = evt.parse(p); err != nil {
+		return n, fmt.Errorf("cannot decode event: %s", err)
+	}
+
+	for _, key := range logfmtLeadingKeys {
+		f, ok := evt.field(key)
+		if !ok {
+			continue
+		}
+		name := key
+		if key == zerolog.MessageFieldName {
+			name = LogfmtMessageFieldName
+		}
+		evt.buf = appendLogfmtPair(evt.buf, []byte(name), f)
+	}
+
+	for i := range evt.fields {
+		if isLogfmtLeadingKey(evt.fields[i].key) {
+			continue
+		}
+		evt.order = append(evt.order, i)
+	}
+	evt.dedupOrder()
+	for _, idx := range evt.order {
+		f := &evt.fields[idx]
+		evt.buf = appendLogfmtPair(evt.buf, f.key, f)
+	}
+
+	evt.buf = append(evt.buf, '\n')
+	out, toErr := splitOutput(evt, w.Out, w.ErrOut, w.ErrLevel)
+	if w.ErrOut != nil {
+		switchOutput(&w.lastErr, toErr, w.Out, w.ErrOut)
+	}
+	_, err = out.Write(evt.buf)
+	return len(p), err
+}
+
+func isLogfmtLeadingKey(key []byte) bool {
+	for _, k := range logfmtLeadingKeys {
+		if string(key) == k {
+			return true
+		}
+	}
+	return false
+}
+
+// appendLogfmtPair appends " key=value", without the leading space if dst is empty.
+func appendLogfmtPair(dst, key []byte, f *eventField) []byte {
+	if len(dst) > 0 {
+		dst = append(dst, ' ')
+	}
+	dst = appendLogfmtKey(dst, key)
+	dst = append(dst, '=')
+	switch f.kind {
+	case jsonString:
+		return appendLogfmtString(dst, f.value)
+	case jsonObject, jsonArray:
+		// the raw JSON of zerolog is compact, quoted as a string
+		return appendLogfmtString(dst, f.value)
+	}
+	return append(dst, f.value...)
+}
+
+// appendLogfmtKey appends key with the bytes not allowed in keys replaced by '_'.
+func appendLogfmtKey(dst, key []byte) []byte {
+	if len(key) == 0 {
+		return append(dst, '_')
+	}
+	for _, c := range key {
+		if c <= ' ' || c == '=' || c == '"' || c == utf8.RuneSelf-1 {
+			c = '_'
+		}
+		dst = append(dst, c)
+	}
+	return dst
+}
+
+// appendLogfmtString appends s, quoted and escaped if it is empty or contains spaces, '=', '"' or control characters.
+func appendLogfmtString(dst, s []byte) []byte {
+	if !logfmtNeedsQuote(s) {
+		return append(dst, s...)
+	}
+	return appendQuoted(dst, s)
+}
+
+func logfmtNeedsQuote(s []byte) bool {
+	if len(s) == 0 {
+		return true
+	}
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			if c <= ' ' || c == '=' || c == '"' || c == '\\' || c == utf8.RuneSelf-1 {
+				return true
+			}
+			i++
+			continue
+		}
+		r, width := utf8.DecodeRune(s[i:])
+		if (r == utf8.RuneError && width == 1) || !strconv.IsPrint(r) {
+			return true
+		}
+		i += width
+	}
+	return false
+}
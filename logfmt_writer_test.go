@@ -0,0 +1,64 @@
+package rzerolog
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogfmtWriter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := NewLogfmtWriter(buf)
+	_, err := w.Write([]byte(`{"user":"bob smith","level":"info","n":1.5,"message":"peer \"a\" connected\n",` +
+		`"time":"2022-02-11 16:04:05.000","label":"p2p","ok":true,"nil":null,"empty":"",` +
+		`"req":{"id":"x y"},"a b=c":"d=e","caller":"/src/p2p/host.go:12","n":2}`))
+	require.Nil(t, err)
+	require.Equal(t, `time="2022-02-11 16:04:05.000" level=info label=p2p msg="peer \"a\" connected\n" `+
+		`caller=/src/p2p/host.go:12 user="bob smith" ok=true nil=null empty="" `+
+		`req="{\"id\":\"x y\"}" a_b_c="d=e" n=2`+"\n", buf.String())
+
+	buf.Reset()
+	w.Enable = false
+	_, err = w.Write([]byte(`{"level":"info"}`))
+	require.Nil(t, err)
+	require.Equal(t, "", buf.String())
+
+	_, err = NewLogfmtWriter(buf).Write([]byte(`{"level":`))
+	require.NotNil(t, err)
+}
+
+func TestLogfmtWriterAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("sync.Pool drops items under the race detector")
+	}
+	w := NewLogfmtWriter(&bytes.Buffer{})
+	p := []byte(`{"level":"info","time":"2022-02-11 16:04:05.000","message":"hello world","user":"bob","n":1}`)
+	allocs := testing.AllocsPerRun(100, func() {
+		_, _ = w.Write(p)
+	})
+	require.Equal(t, 0.0, allocs)
+}
+
+func TestLogFormatOptions(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.apply(WithLogFormat(LogFormatLogfmt), WithConsoleLogFormat(LogFormatLogfmt))
+	_, ok := cfg.fw.writer.(*LogfmtWriter)
+	require.True(t, ok)
+	_, ok = cfg.consoleWriter().(*LogfmtWriter)
+	require.True(t, ok)
+	require.Panics(t, func() { WithConsoleLogFormat(LogFormatJSON) })
+
+	// the console output at or above the level is written to the error output
+	out, errOut := &bytes.Buffer{}, &bytes.Buffer{}
+	cfg = defaultConfig()
+	cfg.apply(WithConsoleErrOutput(errOut, WarnLevel), WithConsoleLogFormat(LogFormatLogfmt))
+	cfg.cw.Out = out
+	w := cfg.consoleWriter()
+	_, err := w.Write([]byte(`{"level":"info","message":"a"}`))
+	require.Nil(t, err)
+	_, err = w.Write([]byte(`{"level":"error","message":"b"}`))
+	require.Nil(t, err)
+	require.Equal(t, "level=info msg=a\n", out.String())
+	require.Equal(t, "level=error msg=b\n", errOut.String())
+}
@@ -1,6 +1,9 @@
 package rzerolog
 
 import (
+	"io"
+	"strings"
+
 	"github.com/rs/zerolog"
 )
 
@@ -28,6 +31,12 @@ const (
 	TraceLevel = Level(zerolog.TraceLevel)
 )
 
+// ParseLevel returns the level with the given name, such as "DEBUG" or "warn".
+func ParseLevel(name string) (Level, error) {
+	l, err := zerolog.ParseLevel(strings.ToLower(name))
+	return Level(l), err
+}
+
 func init() {
 	zerolog.TimeFieldFormat = DefaultTimeFormat
 }
@@ -35,22 +44,32 @@ func init() {
 // RZeroLogger is a logger wrapped with zerolog.
 type RZeroLogger struct {
 	zerolog.Logger
-	label string
+	label      string
+	callerFunc bool
+	fw         *LogFileWriter
+	// sinks are closed with the log file, nil in the sub loggers
+	sinks []io.Writer
 }
 
 func newRZeroLogger(cfg loggerPrepare) *RZeroLogger {
+	cfg.fw.label = cfg.label
+	cfg.applyTextOptions()
 	if err := cfg.fw.initBase(); err != nil {
 		panic(err)
 	}
-	multi := zerolog.MultiLevelWriter(cfg.cw, cfg.fw)
+	writers := append([]io.Writer{cfg.consoleWriter(), cfg.fw}, cfg.sinks...)
+	multi := zerolog.MultiLevelWriter(writers...)
 	ctx := zerolog.New(multi).Level(zerolog.Level(cfg.level)).With().Timestamp()
 	if cfg.caller {
 		ctx = ctx.Caller()
 	}
 	zeroLog := ctx.Logger()
 	return &RZeroLogger{
-		Logger: zeroLog,
-		label:  cfg.label,
+		Logger:     zeroLog,
+		label:      cfg.label,
+		callerFunc: cfg.caller && cfg.callerFunc,
+		fw:         cfg.fw,
+		sinks:      cfg.sinks,
 	}
 }
 
@@ -70,12 +89,33 @@ func NewRZeroLogger(opts ...Option) *RZeroLogger {
 
 // GetLabeledSubLogger create a new sub logger with a new label given.
 //
-// The internal logger is the same as parent.
+// The internal logger is the same as parent, the sub logger does not close the sinks of it.
 func (l *RZeroLogger) GetLabeledSubLogger(label string) *RZeroLogger {
 	return &RZeroLogger{
-		Logger: l.Logger,
-		label:  label,
+		Logger:     l.Logger,
+		label:      label,
+		callerFunc: l.callerFunc,
+		fw:         l.fw,
+	}
+}
+
+// Close flushes and closes the log file, and the sinks which are io.Closer if l is not a sub logger.
+//
+// The log file is shared with all the sub loggers, it is reopened if any of them writes again.
+// The network sinks drop the events written after Close, so only the root logger closes them.
+func (l *RZeroLogger) Close() error {
+	var err error
+	if l.fw != nil {
+		err = l.fw.Close()
+	}
+	for _, s := range l.sinks {
+		if c, ok := s.(io.Closer); ok {
+			if cerr := c.Close(); err == nil {
+				err = cerr
+			}
+		}
 	}
+	return err
 }
 
 // Trace starts a new message with trace level.
@@ -83,8 +123,9 @@ func (l *RZeroLogger) GetLabeledSubLogger(label string) *RZeroLogger {
 // You must call Msg on the returned event in order to send the event.
 func (l *RZeroLogger) Trace() *Event {
 	return &Event{
-		Event: l.Logger.Trace(),
-		label: l.label,
+		Event:      l.Logger.Trace(),
+		label:      l.label,
+		callerFunc: l.callerFunc,
 	}
 }
 
@@ -93,8 +134,9 @@ func (l *RZeroLogger) Trace() *Event {
 // You must call Msg on the returned event in order to send the event.
 func (l *RZeroLogger) Debug() *Event {
 	return &Event{
-		Event: l.Logger.Debug(),
-		label: l.label,
+		Event:      l.Logger.Debug(),
+		label:      l.label,
+		callerFunc: l.callerFunc,
 	}
 }
 
@@ -103,8 +145,9 @@ func (l *RZeroLogger) Debug() *Event {
 // You must call Msg on the returned event in order to send the event.
 func (l *RZeroLogger) Info() *Event {
 	return &Event{
-		Event: l.Logger.Info(),
-		label: l.label,
+		Event:      l.Logger.Info(),
+		label:      l.label,
+		callerFunc: l.callerFunc,
 	}
 }
 
@@ -113,8 +156,9 @@ func (l *RZeroLogger) Info() *Event {
 // You must call Msg on the returned event in order to send the event.
 func (l *RZeroLogger) Warn() *Event {
 	return &Event{
-		Event: l.Logger.Warn(),
-		label: l.label,
+		Event:      l.Logger.Warn(),
+		label:      l.label,
+		callerFunc: l.callerFunc,
 	}
 }
 
@@ -123,8 +167,9 @@ func (l *RZeroLogger) Warn() *Event {
 // You must call Msg on the returned event in order to send the event.
 func (l *RZeroLogger) Error() *Event {
 	return &Event{
-		Event: l.Logger.Error(),
-		label: l.label,
+		Event:      l.Logger.Error(),
+		label:      l.label,
+		callerFunc: l.callerFunc,
 	}
 }
 
@@ -134,8 +179,9 @@ func (l *RZeroLogger) Error() *Event {
 // You must call Msg on the returned event in order to send the event.
 func (l *RZeroLogger) Err(err error) *Event {
 	return &Event{
-		Event: l.Logger.Err(err),
-		label: l.label,
+		Event:      l.Logger.Err(err),
+		label:      l.label,
+		callerFunc: l.callerFunc,
 	}
 }
 
@@ -145,8 +191,9 @@ func (l *RZeroLogger) Err(err error) *Event {
 // You must call Msg on the returned event in order to send the event.
 func (l *RZeroLogger) Fatal() *Event {
 	return &Event{
-		Event: l.Logger.Fatal(),
-		label: l.label,
+		Event:      l.Logger.Fatal(),
+		label:      l.label,
+		callerFunc: l.callerFunc,
 	}
 }
 
@@ -156,8 +203,9 @@ func (l *RZeroLogger) Fatal() *Event {
 // You must call Msg on the returned event in order to send the event.
 func (l *RZeroLogger) Panic() *Event {
 	return &Event{
-		Event: l.Logger.Panic(),
-		label: l.label,
+		Event:      l.Logger.Panic(),
+		label:      l.label,
+		callerFunc: l.callerFunc,
 	}
 }
 
@@ -168,8 +216,9 @@ func (l *RZeroLogger) Panic() *Event {
 // You must call Msg on the returned event in order to send the event.
 func (l *RZeroLogger) WithLevel(level Level) *Event {
 	return &Event{
-		Event: l.Logger.WithLevel(zerolog.Level(level)),
-		label: l.label,
+		Event:      l.Logger.WithLevel(zerolog.Level(level)),
+		label:      l.label,
+		callerFunc: l.callerFunc,
 	}
 }
 
@@ -179,7 +228,8 @@ func (l *RZeroLogger) WithLevel(level Level) *Event {
 // You must call Msg on the returned event in order to send the event.
 func (l *RZeroLogger) Log() *Event {
 	return &Event{
-		Event: l.Logger.Log(),
-		label: l.label,
+		Event:      l.Logger.Log(),
+		label:      l.label,
+		callerFunc: l.callerFunc,
 	}
 }
@@ -0,0 +1,426 @@
+package rzerolog
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var (
+	_ FileSystem     = (*MemFileSystem)(nil)
+	_ PermFileSystem = (*MemFileSystem)(nil)
+	_ File           = (*memFile)(nil)
+)
+
+// MemFSOp is a file system operation which errors can be injected into.
+type MemFSOp string
+
+const (
+	MemFSOpOpen   MemFSOp = "open"
+	MemFSOpWrite  MemFSOp = "write"
+	MemFSOpSync   MemFSOp = "sync"
+	MemFSOpClose  MemFSOp = "close"
+	MemFSOpRename MemFSOp = "rename"
+	MemFSOpRemove MemFSOp = "remove"
+	MemFSOpStat   MemFSOp = "stat"
+	MemFSOpMkdir  MemFSOp = "mkdir"
+	MemFSOpChmod  MemFSOp = "chmod"
+	MemFSOpChown  MemFSOp = "chown"
+)
+
+// MemFileSystem is an in-memory FileSystem, it is usually used in tests.
+//
+// It can simulate a full disk with SetCapacity, permission errors with Chmod,
+// and any other failure with InjectError.
+type MemFileSystem struct {
+	mu       sync.Mutex
+	files    map[string]*memFileData
+	dirs     map[string]os.FileMode
+	capacity int64
+	used     int64
+	faults   map[memFault]error
+}
+
+type memFault struct {
+	op   MemFSOp
+	name string
+}
+
+type memFileData struct {
+	name    string
+	data    []byte
+	mode    os.FileMode
+	uid     int
+	gid     int
+	modTime time.Time
+}
+
+// NewMemFileSystem creates an empty in-memory file system.
+func NewMemFileSystem() *MemFileSystem {
+	return &MemFileSystem{
+		files:  make(map[string]*memFileData),
+		dirs:   make(map[string]os.FileMode),
+		faults: make(map[memFault]error),
+	}
+}
+
+// SetCapacity limits the total bytes of all files, writes beyond it fail with ENOSPC.
+// Zero means unlimited.
+func (m *MemFileSystem) SetCapacity(capacity int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.capacity = capacity
+}
+
+// InjectError makes the operation op on the named file return err.
+// An empty name matches all files. A nil err removes the injected error.
+func (m *MemFileSystem) InjectError(op MemFSOp, name string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := memFault{op: op}
+	if name != "" {
+		key.name = cleanName(name)
+	}
+	if err == nil {
+		delete(m.faults, key)
+		return
+	}
+	m.faults[key] = err
+}
+
+// Chmod changes the mode of the named file or directory.
+// Files without the owner write bit cannot be opened for writing,
+// and directories without it cannot have files created, renamed or removed in them.
+func (m *MemFileSystem) Chmod(name string, mode os.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	name = cleanName(name)
+	if err := m.fault(MemFSOpChmod, name); err != nil {
+		return err
+	}
+	if f, ok := m.files[name]; ok {
+		f.mode = mode.Perm()
+		return nil
+	}
+	if _, ok := m.dirs[name]; ok {
+		m.dirs[name] = mode.Perm()
+		return nil
+	}
+	return &os.PathError{Op: "chmod", Path: name, Err: os.ErrNotExist}
+}
+
+// Chown changes the owner of the named file, a uid or gid of -1 is not changed.
+func (m *MemFileSystem) Chown(name string, uid, gid int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	name = cleanName(name)
+	if err := m.fault(MemFSOpChown, name); err != nil {
+		return err
+	}
+	f, ok := m.files[name]
+	if !ok {
+		if _, ok = m.dirs[name]; ok {
+			return nil
+		}
+		return &os.PathError{Op: "chown", Path: name, Err: os.ErrNotExist}
+	}
+	if uid != -1 {
+		f.uid = uid
+	}
+	if gid != -1 {
+		f.gid = gid
+	}
+	return nil
+}
+
+// Owner returns the uid and gid of the named file.
+func (m *MemFileSystem) Owner(name string) (uid, gid int, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, ok := m.files[cleanName(name)]
+	if !ok {
+		return 0, 0, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	}
+	return f.uid, f.gid, nil
+}
+
+// ReadFile returns the content of the named file.
+func (m *MemFileSystem) ReadFile(name string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, ok := m.files[cleanName(name)]
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	return append([]byte(nil), f.data...), nil
+}
+
+// Files returns the sorted names of all files.
+func (m *MemFileSystem) Files() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	names := make([]string, 0, len(m.files))
+	for name := range m.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (m *MemFileSystem) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	name = cleanName(name)
+	if err := m.fault(MemFSOpOpen, name); err != nil {
+		return nil, err
+	}
+	writable := flag&(os.O_WRONLY|os.O_RDWR) != 0
+
+	f, ok := m.files[name]
+	switch {
+	case ok && flag&os.O_CREATE != 0 && flag&os.O_EXCL != 0:
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrExist}
+	case ok:
+		if writable && f.mode&0200 == 0 {
+			return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrPermission}
+		}
+		if flag&os.O_TRUNC != 0 {
+			m.used -= int64(len(f.data))
+			f.data = nil
+		}
+	case flag&os.O_CREATE != 0:
+		if err := m.checkDir("open", name); err != nil {
+			return nil, err
+		}
+		f = &memFileData{name: name, mode: perm.Perm(), modTime: time.Now()}
+		m.files[name] = f
+	default:
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	return &memFile{fs: m, data: f, name: name, writable: writable}, nil
+}
+
+func (m *MemFileSystem) Rename(oldpath, newpath string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	oldpath, newpath = cleanName(oldpath), cleanName(newpath)
+	if err := m.fault(MemFSOpRename, oldpath); err != nil {
+		return err
+	}
+	f, ok := m.files[oldpath]
+	if !ok {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: os.ErrNotExist}
+	}
+	if err := m.checkDir("rename", oldpath); err != nil {
+		return err
+	}
+	if err := m.checkDir("rename", newpath); err != nil {
+		return err
+	}
+	if replaced, ok := m.files[newpath]; ok {
+		m.used -= int64(len(replaced.data))
+	}
+	delete(m.files, oldpath)
+	f.name = newpath
+	m.files[newpath] = f
+	return nil
+}
+
+func (m *MemFileSystem) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	name = cleanName(name)
+	if err := m.fault(MemFSOpRemove, name); err != nil {
+		return err
+	}
+	f, ok := m.files[name]
+	if !ok {
+		return &os.PathError{Op: "remove", Path: name, Err: os.ErrNotExist}
+	}
+	if err := m.checkDir("remove", name); err != nil {
+		return err
+	}
+	m.used -= int64(len(f.data))
+	delete(m.files, name)
+	return nil
+}
+
+func (m *MemFileSystem) Stat(name string) (os.FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	name = cleanName(name)
+	if err := m.fault(MemFSOpStat, name); err != nil {
+		return nil, err
+	}
+	if f, ok := m.files[name]; ok {
+		return f.info(), nil
+	}
+	if mode, ok := m.dirs[name]; ok || isRootDir(name) {
+		if !ok {
+			mode = 0777
+		}
+		return &memFileInfo{name: filepath.Base(name), mode: mode | os.ModeDir}, nil
+	}
+	return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+}
+
+func (m *MemFileSystem) MkdirAll(path string, perm os.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	path = cleanName(path)
+	if err := m.fault(MemFSOpMkdir, path); err != nil {
+		return err
+	}
+	for dir := path; !isRootDir(dir); dir = filepath.Dir(dir) {
+		if _, ok := m.files[dir]; ok {
+			return &os.PathError{Op: "mkdir", Path: dir, Err: syscall.ENOTDIR}
+		}
+	}
+	var create []string
+	for dir := path; !isRootDir(dir); dir = filepath.Dir(dir) {
+		if _, ok := m.dirs[dir]; ok {
+			break
+		}
+		create = append(create, dir)
+	}
+	for i := len(create) - 1; i >= 0; i-- {
+		if err := m.checkDir("mkdir", create[i]); err != nil {
+			return err
+		}
+		m.dirs[create[i]] = perm.Perm()
+	}
+	return nil
+}
+
+// fault returns the error injected into op on name.
+func (m *MemFileSystem) fault(op MemFSOp, name string) error {
+	if err, ok := m.faults[memFault{op: op, name: name}]; ok {
+		return err
+	}
+	return m.faults[memFault{op: op}]
+}
+
+// checkDir checks that the parent directory of name exists and is writable.
+func (m *MemFileSystem) checkDir(op, name string) error {
+	dir := filepath.Dir(name)
+	if isRootDir(dir) {
+		return nil
+	}
+	mode, ok := m.dirs[dir]
+	if !ok {
+		return &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+	}
+	if mode&0200 == 0 {
+		return &os.PathError{Op: op, Path: name, Err: os.ErrPermission}
+	}
+	return nil
+}
+
+func cleanName(name string) string {
+	return filepath.Clean(name)
+}
+
+func isRootDir(dir string) bool {
+	return dir == "." || dir == string(filepath.Separator) || filepath.Dir(dir) == dir
+}
+
+func (f *memFileData) info() os.FileInfo {
+	return &memFileInfo{
+		name:    filepath.Base(f.name),
+		size:    int64(len(f.data)),
+		mode:    f.mode,
+		modTime: f.modTime,
+	}
+}
+
+// memFile is a file opened from MemFileSystem.
+type memFile struct {
+	fs       *MemFileSystem
+	data     *memFileData
+	name     string
+	writable bool
+	closed   bool
+}
+
+func (f *memFile) Name() string {
+	return f.name
+}
+
+func (f *memFile) Write(p []byte) (int, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	if f.closed {
+		return 0, &os.PathError{Op: "write", Path: f.name, Err: os.ErrClosed}
+	}
+	if !f.writable {
+		return 0, &os.PathError{Op: "write", Path: f.name, Err: os.ErrPermission}
+	}
+	if err := f.fs.fault(MemFSOpWrite, f.name); err != nil {
+		return 0, err
+	}
+	n := len(p)
+	var err error
+	if f.fs.capacity > 0 && f.fs.used+int64(n) > f.fs.capacity {
+		n = int(f.fs.capacity - f.fs.used)
+		if n < 0 {
+			n = 0
+		}
+		err = &os.PathError{Op: "write", Path: f.name, Err: syscall.ENOSPC}
+	}
+	f.data.data = append(f.data.data, p[:n]...)
+	f.data.modTime = time.Now()
+	f.fs.used += int64(n)
+	return n, err
+}
+
+func (f *memFile) Stat() (os.FileInfo, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	if f.closed {
+		return nil, &os.PathError{Op: "stat", Path: f.name, Err: os.ErrClosed}
+	}
+	if err := f.fs.fault(MemFSOpStat, f.name); err != nil {
+		return nil, err
+	}
+	return f.data.info(), nil
+}
+
+func (f *memFile) Sync() error {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	if f.closed {
+		return &os.PathError{Op: "sync", Path: f.name, Err: os.ErrClosed}
+	}
+	return f.fs.fault(MemFSOpSync, f.name)
+}
+
+func (f *memFile) Close() error {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	if f.closed {
+		return &os.PathError{Op: "close", Path: f.name, Err: os.ErrClosed}
+	}
+	if err := f.fs.fault(MemFSOpClose, f.name); err != nil {
+		return err
+	}
+	f.closed = true
+	return nil
+}
+
+// memFileInfo implements os.FileInfo for MemFileSystem.
+type memFileInfo struct {
+	name    string
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+}
+
+func (i *memFileInfo) Name() string       { return i.name }
+func (i *memFileInfo) Size() int64        { return i.size }
+func (i *memFileInfo) Mode() os.FileMode  { return i.mode }
+func (i *memFileInfo) ModTime() time.Time { return i.modTime }
+func (i *memFileInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i *memFileInfo) Sys() interface{}   { return nil }
@@ -0,0 +1,485 @@
+package rzerolog
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var _ io.WriteCloser = (*NetworkWriter)(nil)
+
+const (
+	NetworkTCP  = "tcp"
+	NetworkTLS  = "tls"
+	NetworkUnix = "unix"
+)
+
+const (
+	// DefaultNetworkBufferSize is the size of events buffered in memory, 4M.
+	DefaultNetworkBufferSize = 4 << 20
+	DefaultNetworkMinBackoff = 100 * time.Millisecond
+	DefaultNetworkMaxBackoff = 30 * time.Second
+	// networkTimeout is the timeout of dialing and writing to the collector.
+	networkTimeout = 10 * time.Second
+	// networkBatchSize is the size of the events sent in a write.
+	networkBatchSize = 64 << 10
+)
+
+var (
+	// ErrNetworkBufferFull is returned if an event is dropped because the buffers are full.
+	ErrNetworkBufferFull = errors.New("network sink: buffer full, event dropped")
+	// ErrNetworkClosed is returned by the writes after Close.
+	ErrNetworkClosed = errors.New("network sink: closed")
+)
+
+// NetworkWriter ships the events as newline-delimited JSON to a collector over TCP, TLS or a unix socket.
+//
+// The events are buffered in memory up to BufferSize and sent in the background,
+// the connection is redialed with exponential backoff if it fails.
+// If SpillPath is set, the events exceeding the memory buffer are appended to a queue file in it,
+// which is replayed in order once the collector is back, and the events not sent when closed are saved there,
+// so that they are sent by the next NetworkWriter of the same address.
+// Otherwise the events exceeding the buffer are dropped.
+// The queue file is read again with backoff if reading fails, the errors are reported by zerolog.ErrorHandler.
+//
+// NOTE: The events are delivered at least once, an event being written when the connection breaks is resent.
+type NetworkWriter struct {
+	// Network is NetworkTCP, NetworkTLS or NetworkUnix.
+	Network string
+	// Addr is the address of the collector, or the path of the socket for NetworkUnix.
+	Addr string
+	// TLSConfig is the config of NetworkTLS, the default config with the host of Addr if it is nil.
+	TLSConfig *tls.Config
+	// BufferSize is the size of events buffered in memory, DefaultNetworkBufferSize if it is not positive.
+	BufferSize int
+	// SpillPath is the directory of the queue file, the events exceeding BufferSize are dropped if it is empty.
+	SpillPath string
+	// SpillSize is the max size of the queue file, no limit if it is 0.
+	SpillSize int64
+	// MinBackoff and MaxBackoff bound the delay of redialing.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	mu   sync.Mutex
+	cond *sync.Cond
+	// mem is the events in memory, which are older than the ones in the queue file
+	mem     [][]byte
+	memSize int
+	// spilling is set if the new events are appended to the queue file, until it is replayed
+	spilling  bool
+	spill     *os.File
+	spillRead int64
+	spillSize int64
+	// spillLines is the count of events in the queue file not replayed yet
+	spillLines int64
+	closed     bool
+
+	start   sync.Once
+	done    chan struct{}
+	stopped chan struct{}
+	conn    net.Conn
+	dropped uint64
+}
+
+// NetworkOption configures a NetworkWriter.
+type NetworkOption func(w *NetworkWriter)
+
+// WithNetworkTLSConfig set the TLS config of NetworkTLS.
+func WithNetworkTLSConfig(cfg *tls.Config) NetworkOption {
+	return func(w *NetworkWriter) {
+		w.TLSConfig = cfg
+	}
+}
+
+// WithNetworkBuffer set the size of events buffered in memory.
+func WithNetworkBuffer(size int) NetworkOption {
+	return func(w *NetworkWriter) {
+		w.BufferSize = size
+	}
+}
+
+// WithNetworkSpill spills the events exceeding the memory buffer to a queue file in path,
+// which grows up to maxSize bytes, no limit if it is 0.
+func WithNetworkSpill(path string, maxSize int64) NetworkOption {
+	return func(w *NetworkWriter) {
+		w.SpillPath = path
+		w.SpillSize = maxSize
+	}
+}
+
+// WithNetworkBackoff set the bounds of the delay of redialing.
+func WithNetworkBackoff(min, max time.Duration) NetworkOption {
+	if min <= 0 || max < min {
+		panic(fmt.Sprintf("invalid network backoff %v-%v", min, max))
+	}
+	return func(w *NetworkWriter) {
+		w.MinBackoff = min
+		w.MaxBackoff = max
+	}
+}
+
+// NewNetworkWriter creates a NetworkWriter shipping to addr over network.
+// The events left in the queue file by the previous writer of addr are replayed first, starting at once.
+func NewNetworkWriter(network, addr string, opts ...NetworkOption) (*NetworkWriter, error) {
+	switch network {
+	case NetworkTCP, NetworkTLS:
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return nil, fmt.Errorf("invalid network sink address %q: %v", addr, err)
+		}
+	case NetworkUnix:
+	default:
+		return nil, fmt.Errorf("unsupported network sink network %q. supporting: %s %s %s", network,
+			NetworkTCP, NetworkTLS, NetworkUnix)
+	}
+	w := &NetworkWriter{
+		Network:    network,
+		Addr:       addr,
+		BufferSize: DefaultNetworkBufferSize,
+		MinBackoff: DefaultNetworkMinBackoff,
+		MaxBackoff: DefaultNetworkMaxBackoff,
+		done:       make(chan struct{}),
+		stopped:    make(chan struct{}),
+	}
+	w.cond = sync.NewCond(&w.mu)
+	for _, opt := range opts {
+		opt(w)
+	}
+	if w.SpillPath != "" {
+		if err := w.openSpill(); err != nil {
+			return nil, err
+		}
+	}
+	if w.spillSize > 0 {
+		// replay the events left by the previous writer without waiting for a write
+		w.start.Do(func() {
+			go w.run()
+		})
+	}
+	return w, nil
+}
+
+// spillName returns the path of the queue file of the address.
+func (w *NetworkWriter) spillName() string {
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '.' {
+			return r
+		}
+		return '_'
+	}, w.Network+"-"+w.Addr)
+	return filepath.Join(w.SpillPath, name+".spill")
+}
+
+func (w *NetworkWriter) openSpill() error {
+	if err := os.MkdirAll(w.SpillPath, DefaultDirMode); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(w.spillName(), os.O_CREATE|os.O_RDWR|os.O_APPEND, DefaultFileMode)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	lines, err := countLines(io.NewSectionReader(f, 0, info.Size()))
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	w.spill = f
+	w.spillSize = info.Size()
+	w.spillLines = lines
+	w.spilling = w.spillSize > 0
+	return nil
+}
+
+// countLines returns the count of newlines read from r.
+func countLines(r io.Reader) (int64, error) {
+	var n int64
+	buf := make([]byte, 32<<10)
+	for {
+		m, err := r.Read(buf)
+		n += int64(bytes.Count(buf[:m], []byte{'\n'}))
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+	}
+}
+
+// Dropped returns the count of events dropped because the buffers are full, or lost from a truncated queue file.
+func (w *NetworkWriter) Dropped() uint64 {
+	return atomic.LoadUint64(&w.dropped)
+}
+
+// Write buffers the JSON input to be shipped in the background.
+func (w *NetworkWriter) Write(p []byte) (n int, err error) {
+	w.start.Do(func() {
+		go w.run()
+	})
+	line := make([]byte, len(p), len(p)+1)
+	copy(line, p)
+	if len(line) == 0 || line[len(line)-1] != '\n' {
+		line = append(line, '\n')
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return 0, ErrNetworkClosed
+	}
+	switch {
+	case !w.spilling && w.memSize+len(line) <= w.bufferSize():
+		w.mem = append(w.mem, line)
+		w.memSize += len(line)
+	case w.spill != nil && (w.SpillSize <= 0 || w.spillSize+int64(len(line)) <= w.SpillSize):
+		// once spilled, the events go to the queue file until it is replayed to keep the order
+		if _, err = w.spill.Write(line); err != nil {
+			atomic.AddUint64(&w.dropped, 1)
+			return 0, err
+		}
+		w.spilling = true
+		w.spillSize += int64(len(line))
+		w.spillLines++
+	default:
+		atomic.AddUint64(&w.dropped, 1)
+		return 0, ErrNetworkBufferFull
+	}
+	w.cond.Signal()
+	return len(p), nil
+}
+
+func (w *NetworkWriter) bufferSize() int {
+	if w.BufferSize <= 0 {
+		return DefaultNetworkBufferSize
+	}
+	return w.BufferSize
+}
+
+// Close sends the events in memory if the collector is connected, and saves the others to the queue file.
+func (w *NetworkWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	w.cond.Broadcast()
+	w.mu.Unlock()
+	close(w.done)
+
+	started := true
+	w.start.Do(func() {
+		started = false
+	})
+	if started {
+		<-w.stopped
+	}
+	if w.conn != nil {
+		_ = w.conn.Close()
+		w.conn = nil
+	}
+	return w.saveSpill()
+}
+
+// saveSpill saves the events in memory before the unread ones of the queue file.
+func (w *NetworkWriter) saveSpill() error {
+	if w.spill == nil {
+		return nil
+	}
+	defer w.spill.Close()
+	if len(w.mem) == 0 {
+		if w.spillRead == w.spillSize {
+			return w.spill.Truncate(0)
+		}
+		if w.spillRead == 0 {
+			return nil
+		}
+	}
+	var buf bytes.Buffer
+	for _, line := range w.mem {
+		buf.Write(line)
+	}
+	if _, err := io.Copy(&buf, io.NewSectionReader(w.spill, w.spillRead, w.spillSize-w.spillRead)); err != nil {
+		return err
+	}
+	tmp := w.spillName() + ".tmp"
+	if err := ioutil.WriteFile(tmp, buf.Bytes(), DefaultFileMode); err != nil {
+		return err
+	}
+	return os.Rename(tmp, w.spillName())
+}
+
+// run sends the buffered events until closed.
+func (w *NetworkWriter) run() {
+	defer close(w.stopped)
+	backoff := time.Duration(0)
+	var batch []byte
+	for {
+		w.mu.Lock()
+		for len(w.mem) == 0 && w.spillRead == w.spillSize && !w.closed {
+			w.cond.Wait()
+		}
+		closed := w.closed
+		// the queue file is kept for the next writer when closed
+		fromMem := len(w.mem) > 0
+		count := 0
+		batch = batch[:0]
+		if fromMem {
+			for count < len(w.mem) && (count == 0 || len(batch)+len(w.mem[count]) <= networkBatchSize) {
+				batch = append(batch, w.mem[count]...)
+				count++
+			}
+		}
+		w.mu.Unlock()
+		if closed && !fromMem {
+			return
+		}
+		if !fromMem {
+			var err error
+			if batch, err = w.readSpill(batch); err != nil {
+				if err == io.EOF || err == io.ErrUnexpectedEOF {
+					// the rest of the queue file is lost, such as truncated by others
+					w.mu.Lock()
+					atomic.AddUint64(&w.dropped, uint64(w.spillLines))
+					w.resetSpill()
+					w.mu.Unlock()
+					reportSinkError(fmt.Errorf("network sink: queue file is truncated, events dropped: %v", err))
+					continue
+				}
+				// the events are kept in the queue file until they are read
+				if backoff == 0 {
+					reportSinkError(fmt.Errorf("network sink: cannot read queue file: %v", err))
+				}
+				backoff = nextBackoff(backoff, w.MinBackoff, w.MaxBackoff)
+				select {
+				case <-time.After(backoff):
+				case <-w.done:
+				}
+				continue
+			}
+		}
+
+		if err := w.send(batch); err != nil {
+			if closed {
+				return
+			}
+			backoff = nextBackoff(backoff, w.MinBackoff, w.MaxBackoff)
+			select {
+			case <-time.After(backoff):
+			case <-w.done:
+			}
+			continue
+		}
+		backoff = 0
+
+		w.mu.Lock()
+		if fromMem {
+			for i := 0; i < count; i++ {
+				w.memSize -= len(w.mem[i])
+				w.mem[i] = nil
+			}
+			w.mem = w.mem[count:]
+		} else {
+			w.spillRead += int64(len(batch))
+			w.spillLines -= int64(bytes.Count(batch, []byte{'\n'}))
+			if w.spillRead == w.spillSize {
+				w.resetSpill()
+			}
+		}
+		w.mu.Unlock()
+	}
+}
+
+// readSpill reads the complete lines of the queue file from spillRead into buf.
+func (w *NetworkWriter) readSpill(buf []byte) ([]byte, error) {
+	w.mu.Lock()
+	off, size := w.spillRead, w.spillSize
+	w.mu.Unlock()
+	n := size - off
+	if n > networkBatchSize {
+		n = networkBatchSize
+	}
+	if cap(buf) < int(n) {
+		buf = make([]byte, n)
+	}
+	buf = buf[:n]
+	if _, err := w.spill.ReadAt(buf, off); err != nil {
+		return buf[:0], err
+	}
+	if i := bytes.LastIndexByte(buf, '\n'); i >= 0 {
+		return buf[:i+1], nil
+	}
+	if int64(len(buf)) == size-off {
+		return buf, nil
+	}
+	// a line longer than the batch
+	return w.readLongLine(off)
+}
+
+func (w *NetworkWriter) readLongLine(off int64) ([]byte, error) {
+	w.mu.Lock()
+	size := w.spillSize
+	w.mu.Unlock()
+	buf := make([]byte, size-off)
+	if _, err := w.spill.ReadAt(buf, off); err != nil {
+		return buf[:0], err
+	}
+	if i := bytes.IndexByte(buf, '\n'); i >= 0 {
+		return buf[:i+1], nil
+	}
+	return buf, nil
+}
+
+// resetSpill empties the queue file, the new events go to memory again.
+func (w *NetworkWriter) resetSpill() {
+	_ = w.spill.Truncate(0)
+	w.spillRead, w.spillSize, w.spillLines = 0, 0, 0
+	w.spilling = false
+}
+
+// send writes b to the collector, dialing it if it is not connected.
+func (w *NetworkWriter) send(b []byte) error {
+	if w.conn == nil {
+		conn, err := w.dial()
+		if err != nil {
+			return err
+		}
+		w.conn = conn
+	}
+	_ = w.conn.SetWriteDeadline(time.Now().Add(networkTimeout))
+	if _, err := w.conn.Write(b); err != nil {
+		_ = w.conn.Close()
+		w.conn = nil
+		return err
+	}
+	return nil
+}
+
+func (w *NetworkWriter) dial() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: networkTimeout}
+	switch w.Network {
+	case NetworkTLS:
+		cfg := w.TLSConfig
+		if cfg == nil {
+			host, _, _ := net.SplitHostPort(w.Addr)
+			cfg = &tls.Config{ServerName: host}
+		}
+		return tls.DialWithDialer(dialer, "tcp", w.Addr, cfg)
+	default:
+		return dialer.Dial(w.Network, w.Addr)
+	}
+}
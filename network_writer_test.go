@@ -0,0 +1,205 @@
+package rzerolog
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+// collect accepts the connections of ln and sends the lines received.
+func collect(ln net.Listener) <-chan string {
+	lines := make(chan string, 100)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				s := bufio.NewScanner(conn)
+				for s.Scan() {
+					lines <- s.Text()
+				}
+			}()
+		}
+	}()
+	return lines
+}
+
+func receive(t *testing.T, lines <-chan string, n int) []string {
+	var got []string
+	for len(got) < n {
+		select {
+		case l := <-lines:
+			got = append(got, l)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("received %d lines, want %d", len(got), n)
+		}
+	}
+	return got
+}
+
+func TestNetworkWriter(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer ln.Close()
+	lines := collect(ln)
+
+	l := NewRZeroLogger(DisableConsolePrint(), WithNoCaller(), WithLabel("p2p"),
+		WithNetworkOutput(NetworkTCP, ln.Addr().String()))
+	l.Info().Int("n", 1).Msg("first")
+	l.Info().Int("n", 2).Msg("second")
+	// closing a sub logger keeps the shared sink open
+	require.Nil(t, l.GetLabeledSubLogger("sub").Close())
+	l.Info().Int("n", 3).Msg("third")
+	got := receive(t, lines, 3)
+	require.Nil(t, l.Close())
+	require.Contains(t, got[0], `"n":1`)
+	require.Contains(t, got[0], `"label":"p2p"`)
+	require.Contains(t, got[1], `"n":2`)
+	require.Contains(t, got[2], `"n":3`)
+
+	_, err = NewNetworkWriter("udp", ln.Addr().String())
+	require.NotNil(t, err)
+}
+
+func TestNetworkWriterSpill(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	addr := ln.Addr().String()
+	// the collector is down
+	require.Nil(t, ln.Close())
+
+	dir, err := ioutil.TempDir("", "rzerolog-spill")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	w, err := NewNetworkWriter(NetworkTCP, addr, WithNetworkBuffer(64),
+		WithNetworkSpill(dir, 0), WithNetworkBackoff(5*time.Millisecond, 20*time.Millisecond))
+	require.Nil(t, err)
+	for i := 0; i < 20; i++ {
+		_, err = w.Write([]byte(fmt.Sprintf(`{"n":%d}`, i)))
+		require.Nil(t, err)
+	}
+	info, err := os.Stat(w.spillName())
+	require.Nil(t, err)
+	require.Greater(t, info.Size(), int64(0))
+
+	// the events are replayed in order once the collector is back
+	ln, err = net.Listen("tcp", addr)
+	require.Nil(t, err)
+	defer ln.Close()
+	lines := collect(ln)
+	got := receive(t, lines, 20)
+	for i, l := range got {
+		require.Equal(t, fmt.Sprintf(`{"n":%d}`, i), l)
+	}
+	require.Nil(t, w.Close())
+	require.Equal(t, uint64(0), w.Dropped())
+}
+
+func TestNetworkWriterSpillTruncated(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	addr := ln.Addr().String()
+	require.Nil(t, ln.Close())
+
+	errs := make(chan error, 4)
+	defer func(h func(error)) { zerolog.ErrorHandler = h }(zerolog.ErrorHandler)
+	zerolog.ErrorHandler = func(err error) { errs <- err }
+
+	dir, err := ioutil.TempDir("", "rzerolog-spill")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	w, err := NewNetworkWriter(NetworkTCP, addr, WithNetworkBuffer(16),
+		WithNetworkSpill(dir, 0), WithNetworkBackoff(5*time.Millisecond, 20*time.Millisecond))
+	require.Nil(t, err)
+	for i := 0; i < 10; i++ {
+		_, err = w.Write([]byte(fmt.Sprintf(`{"n":%d}`, i)))
+		require.Nil(t, err)
+	}
+	// the queue file loses its contents while the collector is down
+	require.Nil(t, os.Truncate(w.spillName(), 4))
+
+	ln, err = net.Listen("tcp", addr)
+	require.Nil(t, err)
+	defer ln.Close()
+	lines := collect(ln)
+	select {
+	case err = <-errs:
+		require.Contains(t, err.Error(), "truncated")
+	case <-time.After(5 * time.Second):
+		t.Fatal("the lost events are not reported")
+	}
+	// the events in memory are sent, the lost ones are counted
+	got := receive(t, lines, 2)
+	require.Equal(t, []string{`{"n":0}`, `{"n":1}`}, got)
+	require.Equal(t, uint64(8), w.Dropped())
+
+	// the new events are sent again
+	_, err = w.Write([]byte(`{"n":10}`))
+	require.Nil(t, err)
+	require.Equal(t, []string{`{"n":10}`}, receive(t, lines, 1))
+	require.Nil(t, w.Close())
+}
+
+func TestNetworkWriterCloseSaves(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	addr := ln.Addr().String()
+	require.Nil(t, ln.Close())
+
+	dir, err := ioutil.TempDir("", "rzerolog-spill")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	opts := []NetworkOption{WithNetworkSpill(dir, 0), WithNetworkBackoff(5*time.Millisecond, 20*time.Millisecond)}
+	w, err := NewNetworkWriter(NetworkTCP, addr, opts...)
+	require.Nil(t, err)
+	for i := 0; i < 3; i++ {
+		_, err = w.Write([]byte(fmt.Sprintf(`{"n":%d}`, i)))
+		require.Nil(t, err)
+	}
+	require.Nil(t, w.Close())
+	_, err = w.Write([]byte(`{"n":9}`))
+	require.Equal(t, ErrNetworkClosed, err)
+	b, err := ioutil.ReadFile(w.spillName())
+	require.Nil(t, err)
+	require.Equal(t, "{\"n\":0}\n{\"n\":1}\n{\"n\":2}\n", string(b))
+
+	// the next writer sends the saved events first, without waiting for a write
+	ln, err = net.Listen("tcp", addr)
+	require.Nil(t, err)
+	defer ln.Close()
+	lines := collect(ln)
+	w, err = NewNetworkWriter(NetworkTCP, addr, opts...)
+	require.Nil(t, err)
+	got := receive(t, lines, 3)
+	require.Equal(t, []string{`{"n":0}`, `{"n":1}`, `{"n":2}`}, got)
+	_, err = w.Write([]byte(`{"n":3}`))
+	require.Nil(t, err)
+	require.Equal(t, []string{`{"n":3}`}, receive(t, lines, 1))
+	require.Nil(t, w.Close())
+
+	// no buffer without spill
+	w, err = NewNetworkWriter(NetworkTCP, addr, WithNetworkBuffer(8))
+	require.Nil(t, err)
+	_, err = w.Write([]byte(`{"n":100}`))
+	require.Equal(t, ErrNetworkBufferFull, err)
+	require.Equal(t, uint64(1), w.Dropped())
+	require.Nil(t, w.Close())
+
+	// the buffer size defaults if it is not positive
+	w, err = NewNetworkWriter(NetworkTCP, addr, WithNetworkBuffer(0))
+	require.Nil(t, err)
+	_, err = w.Write([]byte(`{"n":101}`))
+	require.Nil(t, err)
+	require.Equal(t, []string{`{"n":101}`}, receive(t, lines, 1))
+	require.Nil(t, w.Close())
+}
@@ -0,0 +1,7 @@
+//go:build !race
+// +build !race
+
+package rzerolog
+
+// raceEnabled reports whether the race detector is enabled, which drops the items of sync.Pool randomly.
+const raceEnabled = false
@@ -2,31 +2,48 @@ package rzerolog
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"time"
 )
 
 type loggerPrepare struct {
 	cw *ConsoleWriter
 	fw *LogFileWriter
+	// textOpts are applied to the console writer and the text format writer of log files
+	textOpts []ConsoleWriterOption
+	// sinks are the other outputs besides console and log files, such as network sinks
+	sinks []io.Writer
+	// csvColumns are the columns of CSV log files, see WithCSVColumns
+	csvColumns []string
 
 	level     Level
 	logFormat string
-	label     string
-	caller    bool
+	// consoleFormat is the output format on console, see WithConsoleLogFormat
+	consoleFormat string
+	label         string
+	caller        bool
+	callerFunc    bool
 }
 
 func defaultConfig() loggerPrepare {
 	consoleWriter := &ConsoleWriter{
-		Enable:  true,
-		Out:     os.Stdout,
-		NoColor: false,
+		Enable:    true,
+		Out:       os.Stdout,
+		NoColor:   false,
+		ColorMode: ColorAuto,
 	}
 
 	fw := &LogFileWriter{
 		enable:          false,
-		writer:          &osFileWriter{},
+		writer:          &rawFileWriter{},
+		fs:              OSFileSystem{},
 		fillPath:        DefaultFilePath,
+		fileMode:        DefaultFileMode,
+		dirMode:         DefaultDirMode,
+		gid:             -1,
 		timeRolling:     false,
+		location:        time.Local,
 		sizeRolling:     false,
 		fileSize:        DefaultFileSize,
 		maxFileCount:    DefaultMaxFileCount,
@@ -35,12 +52,13 @@ func defaultConfig() loggerPrepare {
 		file:            nil,
 	}
 	cfg := loggerPrepare{
-		cw:        consoleWriter,
-		fw:        fw,
-		level:     DefaultLevel,
-		logFormat: DefaultLogFormat,
-		label:     "",
-		caller:    true,
+		cw:            consoleWriter,
+		fw:            fw,
+		level:         DefaultLevel,
+		logFormat:     DefaultLogFormat,
+		consoleFormat: DefaultConsoleLogFormat,
+		label:         "",
+		caller:        true,
 	}
 	return cfg
 }
@@ -53,6 +71,22 @@ func (lc *loggerPrepare) apply(opts ...Option) {
 	}
 }
 
+// applyTextOptions applies textOpts to the writers of text format, and csvColumns to the CSV writer of log files.
+func (lc *loggerPrepare) applyTextOptions() {
+	if w, ok := lc.fw.writer.(*CSVWriter); ok && len(lc.csvColumns) > 0 {
+		w.Columns = lc.csvColumns
+	}
+	writers := []*ConsoleWriter{lc.cw}
+	if w, ok := lc.fw.writer.(*ConsoleWriter); ok {
+		writers = append(writers, w)
+	}
+	for _, w := range writers {
+		for _, opt := range lc.textOpts {
+			opt(w)
+		}
+	}
+}
+
 // WithLevel set logger level.
 func WithLevel(l Level) Option {
 	return func(cfg *loggerPrepare) {
@@ -74,6 +108,128 @@ func WithNoConsolePrintColor() Option {
 	}
 }
 
+// WithConsoleColorMode set when the console output is colorized, ColorAuto by default.
+//
+// NOTE: WithNoConsolePrintColor takes precedence over the color mode.
+func WithConsoleColorMode(mode ColorMode) Option {
+	return func(cfg *loggerPrepare) {
+		cfg.cw.ColorMode = mode
+	}
+}
+
+// WithConsoleTheme set the colors of the console output, see ThemeByName for the built-in themes.
+func WithConsoleTheme(t *Theme) Option {
+	return func(cfg *loggerPrepare) {
+		cfg.cw.Theme = t
+	}
+}
+
+// WithConsoleLabelColors colors each label on console by the hash of its name,
+// overrides is the fixed styles of some labels.
+func WithConsoleLabelColors(overrides map[string]Style) Option {
+	return func(cfg *loggerPrepare) {
+		WithLabelColors(overrides)(cfg.cw)
+	}
+}
+
+// WithConsoleAlignedLayout lays out the console output in columns, widths overrides the widths of parts.
+func WithConsoleAlignedLayout(widths map[string]int) Option {
+	return func(cfg *loggerPrepare) {
+		WithAlignedLayout(widths)(cfg.cw)
+	}
+}
+
+// WithConsoleLineWidth set the width of console lines in the aligned layout,
+// and what to do with the fields exceeding it. A width of 0 means the width of the terminal.
+func WithConsoleLineWidth(width int, overflow Overflow) Option {
+	return func(cfg *loggerPrepare) {
+		WithLineWidth(width, overflow)(cfg.cw)
+	}
+}
+
+// WithConsoleMultiline set how the messages, errors and stacks of multiple lines are rendered on console.
+func WithConsoleMultiline(mode MultilineMode) Option {
+	return func(cfg *loggerPrepare) {
+		cfg.cw.Multiline = mode
+	}
+}
+
+// WithConsoleCallerLinks prints the caller on console as an OSC 8 hyperlink to linkFormat,
+// see WithCallerLinks for the format.
+func WithConsoleCallerLinks(linkFormat string) Option {
+	return func(cfg *loggerPrepare) {
+		WithCallerLinks(linkFormat)(cfg.cw)
+	}
+}
+
+// WithConsoleErrOutput writes the console output at or above level to out instead of os.Stdout,
+// in both "text" and "logfmt" console formats. A nil out means os.Stderr.
+func WithConsoleErrOutput(out io.Writer, level Level) Option {
+	return func(cfg *loggerPrepare) {
+		WithErrOutput(out, level)(cfg.cw)
+	}
+}
+
+// WithGELFOutput sends the logs to a Graylog GELF input at addr over network, "udp" or "tcp".
+// It panics if the network or addr is invalid, see GELFWriter.
+func WithGELFOutput(network, addr string, opts ...GELFOption) Option {
+	w, err := NewGELFWriter(network, addr, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return func(cfg *loggerPrepare) {
+		cfg.sinks = append(cfg.sinks, w)
+	}
+}
+
+// WithSyslogOutput sends the logs to a syslog daemon at addr over network, "unix", "udp" or "tcp".
+// An empty addr of "unix" means "/dev/log". It panics if the network or addr is invalid, see SyslogWriter.
+func WithSyslogOutput(network, addr string, opts ...SyslogOption) Option {
+	w, err := NewSyslogWriter(network, addr, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return func(cfg *loggerPrepare) {
+		cfg.sinks = append(cfg.sinks, w)
+	}
+}
+
+// WithJournaldOutput sends the logs to systemd-journald at the journal socket addr,
+// an empty addr means "/run/systemd/journal/socket". It panics on the platforms other than linux.
+func WithJournaldOutput(addr string, opts ...JournaldOption) Option {
+	w, err := NewJournaldWriter(addr, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return func(cfg *loggerPrepare) {
+		cfg.sinks = append(cfg.sinks, w)
+	}
+}
+
+// WithNetworkOutput ships the logs as newline-delimited JSON to a collector at addr over network,
+// "tcp", "tls" or "unix". It panics if the network or addr is invalid, see NetworkWriter.
+func WithNetworkOutput(network, addr string, opts ...NetworkOption) Option {
+	w, err := NewNetworkWriter(network, addr, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return func(cfg *loggerPrepare) {
+		cfg.sinks = append(cfg.sinks, w)
+	}
+}
+
+// WithHTTPOutput pushes the logs in batches to url, the push API of Loki or the bulk API of Elasticsearch.
+// It panics if the url is invalid, see HTTPWriter.
+func WithHTTPOutput(format HTTPFormat, url string, opts ...HTTPOption) Option {
+	w, err := NewHTTPWriter(format, url, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return func(cfg *loggerPrepare) {
+		cfg.sinks = append(cfg.sinks, w)
+	}
+}
+
 // EnableLogFiles will make logger to write logs to log files.
 func EnableLogFiles() Option {
 	return func(cfg *loggerPrepare) {
@@ -90,17 +246,82 @@ func WithLogFilePath(path string) Option {
 
 // WithLogFileName set the filename of log files.
 //
-// NOTE: If EnableTimeRolling() invoked, the final log file name will be parsed by the time format parser.
+// The name may be a FileNameTemplate with braced tokens, it panics if the template is invalid.
+// eg:
+// "{label}-{host}-{yyyy}{MM}{dd}{HH}.log" => "p2p-server1-2022021116.log"
+//
+// The {label} is the label of the logger created with the option, the sub loggers write to the same files.
+//
+// NOTE: A name without braces is a legacy format, which will be parsed by the time format parser
+// only if EnableTimeRolling() invoked.
 // eg:
 // "yyyyMMddHH.log" => "2022021116.log"
 func WithLogFileName(name string) Option {
+	tmpl, err := ParseFileNameTemplate(name)
+	if err != nil {
+		panic(err)
+	}
 	return func(cfg *loggerPrepare) {
 		cfg.fw.logFileName = name
+		cfg.fw.nameTemplate = tmpl
+	}
+}
+
+// WithFileSystem set the file system which log files are written to.
+// The default is the file system of the operating system.
+// eg: NewMemFileSystem() keeps log files in memory for tests.
+func WithFileSystem(fs FileSystem) Option {
+	return func(cfg *loggerPrepare) {
+		cfg.fw.fs = fs
+	}
+}
+
+// WithLogFileMode set the permission of log files, including the rotated and compressed ones.
+// The mode is applied with chmod, so it is not affected by umask.
+// eg: 0640
+func WithLogFileMode(mode os.FileMode) Option {
+	return func(cfg *loggerPrepare) {
+		cfg.fw.fileMode = mode.Perm()
+		cfg.fw.enforceFileMode = true
+	}
+}
+
+// WithLogDirMode set the permission of the log file path when it is created.
+// The mode is applied with chmod, so it is not affected by umask.
+// Only the last directory of the path is changed, the parent directories created are masked by umask.
+// eg: 0750
+func WithLogDirMode(mode os.FileMode) Option {
+	return func(cfg *loggerPrepare) {
+		cfg.fw.dirMode = mode.Perm()
+		cfg.fw.enforceDirMode = true
+	}
+}
+
+// WithLogFileGroup set the group owner of log files and the log file path created.
+// The group is a group name or a numeric group id, it panics if the group does not exist.
+//
+// NOTE: The process must be a member of the group or have the privilege to change it.
+func WithLogFileGroup(group string) Option {
+	gid, err := LookupGroupID(group)
+	if err != nil {
+		panic(fmt.Sprintf("invalid log file group %q: %v", group, err))
+	}
+	return func(cfg *loggerPrepare) {
+		cfg.fw.gid = gid
+	}
+}
+
+// WithStorageWrapper wraps the storage stream of log files, such as GzipStorage.
+// Wrappers are applied in order, the first one is the closest to the file.
+func WithStorageWrapper(wrappers ...StorageWrapper) Option {
+	return func(cfg *loggerPrepare) {
+		cfg.fw.wrappers = append(cfg.fw.wrappers, wrappers...)
 	}
 }
 
 // EnableTimeRolling enable rolling the log files on rules implicit in LogFileName set.
 // eg:
+// "{yyyy}{MM}{dd}{HH}.log" => "2022021116.log"
 // "yyyyMMddHH.log" => "2022021116.log"
 func EnableTimeRolling() Option {
 	return func(cfg *loggerPrepare) {
@@ -109,6 +330,37 @@ func EnableTimeRolling() Option {
 	}
 }
 
+// WithRollingTimezone set the time zone used for time rolling and time tokens of log file names.
+// The zone is "UTC", "Local" or an IANA time zone name such as "Asia/Shanghai",
+// it panics if the zone cannot be loaded.
+// Rolling in UTC keeps nodes in different regions rolling at the same moment
+// and is not affected by daylight saving time shifts.
+//
+// NOTE: IANA zones are loaded from the system zoneinfo database,
+// import "time/tzdata" to embed it if the system has none.
+func WithRollingTimezone(zone string) Option {
+	loc, err := LoadRollingTimezone(zone)
+	if err != nil {
+		panic(err)
+	}
+	return func(cfg *loggerPrepare) {
+		cfg.fw.location = loc
+	}
+}
+
+// LoadRollingTimezone returns the time zone with the given name for WithRollingTimezone.
+// An empty name means the local time zone.
+func LoadRollingTimezone(zone string) (*time.Location, error) {
+	if zone == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rolling timezone %q: %v", zone, err)
+	}
+	return loc, nil
+}
+
 // WithSizeRolling enable rolling the log files on rules bounded by file size.
 // This option helps prevent log files from taking up too much disk space.
 // When the number of log files cut reaches the threshold,
@@ -130,22 +382,92 @@ func WithNoCaller() Option {
 	}
 }
 
+// WithCallerFunc adds the function of caller to the logs as CallerFuncFieldName,
+// which is printed after the caller in text output.
+func WithCallerFunc() Option {
+	return func(cfg *loggerPrepare) {
+		cfg.callerFunc = true
+	}
+}
+
 // WithLogFormat set the output format when logger printing.
-// Current supporting:"text","json"
+// Current supporting:"text","json","logfmt","ecs","cbor","csv","tsv"
 func WithLogFormat(format string) Option {
-	var w FileWriter = &osFileWriter{}
+	var w FileWriter = &rawFileWriter{}
 	switch format {
 	case LogFormatJSON:
 	case LogFormatConsoleText:
 		w = &ConsoleWriter{Enable: true, NoColor: true, Out: w}
+	case LogFormatLogfmt:
+		w = &LogfmtWriter{Enable: true, Out: w}
+	case LogFormatECS:
+		w = &ECSWriter{Enable: true, Out: w}
+	case LogFormatCBOR:
+		w = &CBORWriter{Enable: true, Out: w}
+	case LogFormatCSV:
+		w = &CSVWriter{Enable: true, Out: w}
+	case LogFormatTSV:
+		w = &CSVWriter{Enable: true, Out: w, Comma: '\t'}
 	default:
-		panic(fmt.Sprintln("unsupported log format. supporting:", LogFormatJSON, LogFormatConsoleText))
+		panic(fmt.Sprintln("unsupported log format. supporting:",
+			LogFormatJSON, LogFormatConsoleText, LogFormatLogfmt, LogFormatECS, LogFormatCBOR, LogFormatCSV, LogFormatTSV))
 	}
 	return func(cfg *loggerPrepare) {
 		cfg.fw.writer = w
 	}
 }
 
+// WithCSVColumns set the field names of columns in the "csv" and "tsv" log formats,
+// DefaultCSVColumns if it is not set.
+func WithCSVColumns(columns ...string) Option {
+	return func(cfg *loggerPrepare) {
+		cfg.csvColumns = columns
+	}
+}
+
+// WithConsoleLogFormat set the output format on console.
+// Current supporting:"text","logfmt"
+//
+// NOTE: The console options of colors and layout only take effect in "text".
+func WithConsoleLogFormat(format string) Option {
+	switch format {
+	case LogFormatConsoleText, LogFormatLogfmt:
+	default:
+		panic(fmt.Sprintln("unsupported console log format. supporting:", LogFormatConsoleText, LogFormatLogfmt))
+	}
+	return func(cfg *loggerPrepare) {
+		cfg.consoleFormat = format
+	}
+}
+
+// consoleWriter returns the writer of console in the console format.
+func (lc *loggerPrepare) consoleWriter() io.Writer {
+	if lc.consoleFormat == LogFormatLogfmt {
+		return &LogfmtWriter{Enable: lc.cw.Enable, Out: lc.cw.Out, ErrOut: lc.cw.ErrOut, ErrLevel: lc.cw.ErrLevel}
+	}
+	return lc.cw
+}
+
+// WithTextFieldOrder set the order of fields in text output, both on console and in text log files.
+// priority is the fields pinned first in FieldOrderPriority order.
+func WithTextFieldOrder(order FieldOrder, priority ...string) Option {
+	return func(cfg *loggerPrepare) {
+		cfg.textOpts = append(cfg.textOpts, WithFieldOrder(order, priority...))
+	}
+}
+
+// WithTextTemplate set the line layout of text output in the syntax of text/template,
+// both on console and in text log files, see TextTemplate. It panics if the layout is invalid.
+func WithTextTemplate(layout string) Option {
+	t, err := ParseTextTemplate(layout)
+	if err != nil {
+		panic(fmt.Sprintf("invalid text template %q: %s", layout, err))
+	}
+	return func(cfg *loggerPrepare) {
+		cfg.textOpts = append(cfg.textOpts, WithTemplate(t))
+	}
+}
+
 // WithLabel set the logger label.
 // The label will be print to log records automatically.
 // It is usually used to mark modules.
@@ -154,3 +476,26 @@ func WithLabel(label string) Option {
 		cfg.label = label
 	}
 }
+
+// WithTextLabelWidth pads the label in text output to width, both on console and in text log files.
+func WithTextLabelWidth(width int) Option {
+	return func(cfg *loggerPrepare) {
+		cfg.textOpts = append(cfg.textOpts, WithLabelWidth(width))
+	}
+}
+
+// WithTextNested set how the fields of objects and arrays are rendered in text output,
+// both on console and in text log files. The members deeper than depth are printed as JSON, no limit if depth is 0.
+func WithTextNested(mode NestedMode, depth int) Option {
+	return func(cfg *loggerPrepare) {
+		cfg.textOpts = append(cfg.textOpts, WithNested(mode, depth))
+	}
+}
+
+// WithTextCallerFormat set how the file path of caller is printed in text output,
+// both on console and in text log files.
+func WithTextCallerFormat(format CallerFormat) Option {
+	return func(cfg *loggerPrepare) {
+		cfg.textOpts = append(cfg.textOpts, WithCallerFormat(format))
+	}
+}
@@ -0,0 +1,104 @@
+package rzerolog
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// sinkTimeout is the timeout of dialing and writing of the sinks written synchronously by the logger,
+// which bounds the time a log call is blocked by an unreachable or stalled collector.
+const sinkTimeout = 3 * time.Second
+
+// ErrSinkBackoff is returned by the writes dropped while waiting to redial a failed collector.
+var ErrSinkBackoff = errors.New("sink: collector unavailable, event dropped")
+
+// sinkConn is the connection of a synchronous sink, which is redialed with exponential backoff after failures.
+type sinkConn struct {
+	conn net.Conn
+	// backoff is the delay of redialing after the last failure, 0 if the last write succeeded
+	backoff time.Duration
+	// retryAt is the time redialing is allowed
+	retryAt time.Time
+}
+
+// get returns the connection, dialing it if it is not connected and the backoff has passed.
+func (c *sinkConn) get(dial func(d *net.Dialer) (net.Conn, error)) (net.Conn, error) {
+	if c.conn != nil {
+		return c.conn, nil
+	}
+	if c.backoff > 0 && time.Now().Before(c.retryAt) {
+		return nil, ErrSinkBackoff
+	}
+	conn, err := dial(&net.Dialer{Timeout: sinkTimeout})
+	if err != nil {
+		c.fail()
+		return nil, err
+	}
+	c.conn = conn
+	return conn, nil
+}
+
+// write writes b with the write deadline, the connection is closed if it fails.
+// A broken connection is redialed at once by the next get, a stalled one after backoff.
+func (c *sinkConn) write(b []byte) error {
+	return c.do(func(conn net.Conn) error {
+		_, err := conn.Write(b)
+		return err
+	})
+}
+
+// do runs send on the connection with the write deadline, and handles the failure as write.
+func (c *sinkConn) do(send func(conn net.Conn) error) error {
+	_ = c.conn.SetWriteDeadline(time.Now().Add(sinkTimeout))
+	if err := send(c.conn); err != nil {
+		_ = c.close()
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			c.fail()
+		}
+		return err
+	}
+	c.backoff = 0
+	return nil
+}
+
+// fail delays the next dialing.
+func (c *sinkConn) fail() {
+	c.backoff = nextBackoff(c.backoff, DefaultNetworkMinBackoff, DefaultNetworkMaxBackoff)
+	c.retryAt = time.Now().Add(c.backoff)
+}
+
+func (c *sinkConn) close() error {
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+// reportSinkError reports the error of a sink running in the background by zerolog.ErrorHandler,
+// or prints it on the stderr if it is not set.
+func reportSinkError(err error) {
+	if zerolog.ErrorHandler != nil {
+		zerolog.ErrorHandler(err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "rzerolog: %v\n", err)
+}
+
+// nextBackoff doubles backoff within [min, max], it is min after a success.
+func nextBackoff(backoff, min, max time.Duration) time.Duration {
+	if backoff == 0 {
+		return min
+	}
+	backoff *= 2
+	if backoff > max {
+		backoff = max
+	}
+	return backoff
+}
@@ -0,0 +1,40 @@
+package rzerolog
+
+import (
+	"compress/gzip"
+	"io"
+)
+
+// StorageWrapper wraps the storage stream of a log file, it is usually used to
+// compress, encrypt or mirror the log files.
+//
+// Closing the returned writer must flush it and close w.
+type StorageWrapper func(w io.WriteCloser) (io.WriteCloser, error)
+
+// GzipStorage compresses the log files with gzip at the given level.
+// Every opened file is a gzip member, so appending to an existing file keeps it valid.
+//
+// NOTE: The size of the file grows only when the compressor flushes,
+// so size rolling works on the compressed size.
+func GzipStorage(level int) StorageWrapper {
+	return func(w io.WriteCloser) (io.WriteCloser, error) {
+		zw, err := gzip.NewWriterLevel(w, level)
+		if err != nil {
+			return nil, err
+		}
+		return &gzipStorage{Writer: zw, out: w}, nil
+	}
+}
+
+type gzipStorage struct {
+	*gzip.Writer
+	out io.WriteCloser
+}
+
+func (g *gzipStorage) Close() error {
+	err := g.Writer.Close()
+	if cErr := g.out.Close(); err == nil {
+		err = cErr
+	}
+	return err
+}
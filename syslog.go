@@ -0,0 +1,492 @@
+package rzerolog
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+var _ io.WriteCloser = (*SyslogWriter)(nil)
+
+const (
+	SyslogNetworkUnix = "unix"
+	SyslogNetworkUDP  = "udp"
+	SyslogNetworkTCP  = "tcp"
+)
+
+// DefaultSyslogSocket is the unix socket of the local syslog daemon.
+const DefaultSyslogSocket = "/dev/log"
+
+// DefaultSyslogSDID is the SD-ID of the structured data carrying the fields in RFC 5424,
+// 32473 is the private enterprise number reserved for documentation.
+const DefaultSyslogSDID = "fields@32473"
+
+// SyslogFormat defines the format of syslog messages.
+type SyslogFormat int
+
+const (
+	// SyslogRFC5424 formats messages as RFC 5424, the fields are sent as structured data.
+	SyslogRFC5424 SyslogFormat = iota
+	// SyslogRFC3164 formats messages as the BSD syslog of RFC 3164, the fields are appended to the message.
+	SyslogRFC3164
+)
+
+const (
+	SyslogRFC5424Name = "rfc5424"
+	SyslogRFC3164Name = "rfc3164"
+)
+
+// String returns the name of the syslog format.
+func (f SyslogFormat) String() string {
+	switch f {
+	case SyslogRFC5424:
+		return SyslogRFC5424Name
+	case SyslogRFC3164:
+		return SyslogRFC3164Name
+	}
+	return fmt.Sprintf("SyslogFormat(%d)", int(f))
+}
+
+// ParseSyslogFormat returns the syslog format with the given name.
+// An empty name means SyslogRFC5424.
+func ParseSyslogFormat(name string) (SyslogFormat, error) {
+	switch name {
+	case "", SyslogRFC5424Name:
+		return SyslogRFC5424, nil
+	case SyslogRFC3164Name:
+		return SyslogRFC3164, nil
+	}
+	return 0, fmt.Errorf("unsupported syslog format %q. supporting: %s %s", name,
+		SyslogRFC5424Name, SyslogRFC3164Name)
+}
+
+// SyslogFacility is the facility of syslog messages.
+type SyslogFacility int
+
+const (
+	SyslogKern SyslogFacility = iota
+	SyslogUser
+	SyslogMail
+	SyslogDaemon
+	SyslogAuth
+	SyslogSyslog
+	SyslogLPR
+	SyslogNews
+	SyslogUUCP
+	SyslogCron
+	SyslogAuthPriv
+	SyslogFTP
+	SyslogLocal0 SyslogFacility = iota + 4
+	SyslogLocal1
+	SyslogLocal2
+	SyslogLocal3
+	SyslogLocal4
+	SyslogLocal5
+	SyslogLocal6
+	SyslogLocal7
+)
+
+var syslogFacilityNames = map[SyslogFacility]string{
+	SyslogKern:     "kern",
+	SyslogUser:     "user",
+	SyslogMail:     "mail",
+	SyslogDaemon:   "daemon",
+	SyslogAuth:     "auth",
+	SyslogSyslog:   "syslog",
+	SyslogLPR:      "lpr",
+	SyslogNews:     "news",
+	SyslogUUCP:     "uucp",
+	SyslogCron:     "cron",
+	SyslogAuthPriv: "authpriv",
+	SyslogFTP:      "ftp",
+	SyslogLocal0:   "local0",
+	SyslogLocal1:   "local1",
+	SyslogLocal2:   "local2",
+	SyslogLocal3:   "local3",
+	SyslogLocal4:   "local4",
+	SyslogLocal5:   "local5",
+	SyslogLocal6:   "local6",
+	SyslogLocal7:   "local7",
+}
+
+// String returns the name of the facility.
+func (f SyslogFacility) String() string {
+	if name, ok := syslogFacilityNames[f]; ok {
+		return name
+	}
+	return fmt.Sprintf("SyslogFacility(%d)", int(f))
+}
+
+// ParseSyslogFacility returns the facility with the given name, such as "user" or "local0".
+// An empty name means SyslogUser.
+func ParseSyslogFacility(name string) (SyslogFacility, error) {
+	if name == "" {
+		return SyslogUser, nil
+	}
+	for f, n := range syslogFacilityNames {
+		if n == name {
+			return f, nil
+		}
+	}
+	return 0, fmt.Errorf("unsupported syslog facility %q. supporting: kern user mail daemon auth syslog "+
+		"lpr news uucp cron authpriv ftp local0-local7", name)
+}
+
+// SyslogWriter parses the JSON input and sends it to a syslog daemon.
+//
+// The level is mapped to the syslog severity, and the label is sent as APP-NAME,
+// or as MSGID if LabelAsMsgID is set.
+// Over TCP, the messages are framed by octet counting of RFC 6587, over a unix stream socket by newlines,
+// where the newlines in the messages are written as "#012".
+// The connection is dialed on the first write, and redialed once to resend a message if it is broken.
+// After failed dialing or a write timeout, it is redialed with exponential backoff and the events are dropped
+// with ErrSinkBackoff until then. Dialing and writing time out in a few seconds, so an unreachable daemon
+// does not block the logger.
+type SyslogWriter struct {
+	Enable bool
+	// Network is SyslogNetworkUnix, SyslogNetworkUDP or SyslogNetworkTCP.
+	Network string
+	// Addr is the address of the syslog daemon, or the path of the socket for SyslogNetworkUnix.
+	Addr string
+	// Format is the format of messages, SyslogRFC5424 by default.
+	Format SyslogFormat
+	// Facility is the facility of messages.
+	Facility SyslogFacility
+	// Host is the HOSTNAME of messages, the host name if it is empty.
+	Host string
+	// AppName is the APP-NAME of messages without label, the name of the program if it is empty.
+	AppName string
+	// LabelAsMsgID sends the label as MSGID, and AppName as APP-NAME.
+	LabelAsMsgID bool
+	// SDID is the SD-ID of the fields in SyslogRFC5424, DefaultSyslogSDID if it is empty.
+	SDID string
+	// TimeFormat is the layout of the input time, DefaultTimeFormat if it is empty.
+	TimeFormat string
+
+	mu   sync.Mutex
+	conn sinkConn
+	msg  []byte
+}
+
+// SyslogOption configures a SyslogWriter.
+type SyslogOption func(w *SyslogWriter)
+
+// WithSyslogFormat set the format of syslog messages.
+func WithSyslogFormat(format SyslogFormat) SyslogOption {
+	return func(w *SyslogWriter) {
+		w.Format = format
+	}
+}
+
+// WithSyslogFacility set the facility of syslog messages.
+func WithSyslogFacility(facility SyslogFacility) SyslogOption {
+	return func(w *SyslogWriter) {
+		w.Facility = facility
+	}
+}
+
+// WithSyslogAppName set the APP-NAME of syslog messages without label.
+func WithSyslogAppName(name string) SyslogOption {
+	return func(w *SyslogWriter) {
+		w.AppName = name
+	}
+}
+
+// WithSyslogHost set the HOSTNAME of syslog messages.
+func WithSyslogHost(host string) SyslogOption {
+	return func(w *SyslogWriter) {
+		w.Host = host
+	}
+}
+
+// WithSyslogLabelAsMsgID sends the label as MSGID instead of APP-NAME.
+func WithSyslogLabelAsMsgID() SyslogOption {
+	return func(w *SyslogWriter) {
+		w.LabelAsMsgID = true
+	}
+}
+
+// NewSyslogWriter creates a SyslogWriter sending to addr over network.
+// An empty addr of SyslogNetworkUnix means DefaultSyslogSocket.
+func NewSyslogWriter(network, addr string, opts ...SyslogOption) (*SyslogWriter, error) {
+	switch network {
+	case SyslogNetworkUnix:
+		if addr == "" {
+			addr = DefaultSyslogSocket
+		}
+	case SyslogNetworkUDP, SyslogNetworkTCP:
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return nil, fmt.Errorf("invalid syslog address %q: %v", addr, err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported syslog network %q. supporting: %s %s %s", network,
+			SyslogNetworkUnix, SyslogNetworkUDP, SyslogNetworkTCP)
+	}
+	w := &SyslogWriter{Enable: true, Network: network, Addr: addr, Facility: SyslogUser}
+	for _, opt := range opts {
+		opt(w)
+	}
+	if w.Host == "" {
+		w.Host, _ = os.Hostname()
+	}
+	if w.AppName == "" {
+		w.AppName = filepath.Base(os.Args[0])
+	}
+	return w, nil
+}
+
+// Write sends the JSON input as a syslog message.
+func (w *SyslogWriter) Write(p []byte) (n int, err error) {
+	if !w.Enable {
+		return len(p), nil
+	}
+
+	evt := getConsoleEvent()
+	defer evt.release()
+
+	if err = evt.parse(p); err != nil {
+		return n, fmt.Errorf("cannot decode event: %s", err)
+	}
+	if w.Format == SyslogRFC3164 {
+		evt.buf = w.appendRFC3164(evt.buf, evt)
+	} else {
+		evt.buf = w.appendRFC5424(evt.buf, evt)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err = w.send(evt.buf); err != nil {
+		if err == ErrSinkBackoff || w.conn.backoff > 0 {
+			return n, err
+		}
+		// the connection may be broken by a restarted daemon, redial and resend once
+		if err = w.send(evt.buf); err != nil {
+			return n, err
+		}
+	}
+	return len(p), nil
+}
+
+// Close closes the connection.
+func (w *SyslogWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.conn.close()
+}
+
+func (w *SyslogWriter) dial(d *net.Dialer) (net.Conn, error) {
+	if w.Network != SyslogNetworkUnix {
+		return d.Dial(w.Network, w.Addr)
+	}
+	// the local daemon listens on a datagram socket mostly
+	conn, err := d.Dial("unixgram", w.Addr)
+	if err == nil {
+		return conn, nil
+	}
+	return d.Dial("unix", w.Addr)
+}
+
+// send sends the message over the connection.
+func (w *SyslogWriter) send(msg []byte) error {
+	conn, err := w.conn.get(w.dial)
+	if err != nil {
+		return err
+	}
+	switch {
+	case w.Network == SyslogNetworkTCP:
+		w.msg = strconv.AppendInt(w.msg[:0], int64(len(msg)), 10)
+		w.msg = append(w.msg, ' ')
+		w.msg = append(w.msg, msg...)
+		msg = w.msg
+	case conn.RemoteAddr() != nil && conn.RemoteAddr().Network() == "unix":
+		// the messages over a unix stream socket are delimited by newlines,
+		// the ones in the message are escaped as rsyslog does with control characters
+		w.msg = w.msg[:0]
+		for {
+			i := bytes.IndexByte(msg, '\n')
+			if i < 0 {
+				break
+			}
+			w.msg = append(append(w.msg, msg[:i]...), "#012"...)
+			msg = msg[i+1:]
+		}
+		w.msg = append(append(w.msg, msg...), '\n')
+		msg = w.msg
+	}
+	return w.conn.write(msg)
+}
+
+// appendPriority appends the PRI of evt.
+func (w *SyslogWriter) appendPriority(dst []byte, evt *consoleEvent) []byte {
+	severity := 6
+	if f, ok := evt.field(zerolog.LevelFieldName); ok {
+		if l, ok := levelOf(f.value); ok {
+			severity = syslogSeverity(l)
+		}
+	}
+	dst = append(dst, '<')
+	dst = strconv.AppendInt(dst, int64(w.Facility)*8+int64(severity), 10)
+	return append(dst, '>')
+}
+
+// names returns the APP-NAME and MSGID of evt.
+func (w *SyslogWriter) names(evt *consoleEvent) (appName, msgID []byte) {
+	appName = []byte(w.AppName)
+	if f, ok := evt.field(LabelFieldName); ok && len(f.value) > 0 {
+		if w.LabelAsMsgID {
+			return appName, f.value
+		}
+		return f.value, nil
+	}
+	return appName, nil
+}
+
+// time returns the time of evt.
+func (w *SyslogWriter) time(evt *consoleEvent) time.Time {
+	if f, ok := evt.field(zerolog.TimestampFieldName); ok && f.kind == jsonString {
+		layout := w.TimeFormat
+		if layout == "" {
+			layout = DefaultTimeFormat
+		}
+		if t, err := time.ParseInLocation(layout, string(f.value), time.Local); err == nil {
+			return t
+		}
+	}
+	return time.Now()
+}
+
+// appendRFC5424 appends evt formatted as RFC 5424:
+//
+//	<PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [SD-ID name="value"...] MSG
+func (w *SyslogWriter) appendRFC5424(dst []byte, evt *consoleEvent) []byte {
+	dst = w.appendPriority(dst, evt)
+	dst = append(dst, '1', ' ')
+	dst = w.time(evt).AppendFormat(dst, "2006-01-02T15:04:05.000000Z07:00")
+	appName, msgID := w.names(evt)
+	dst = append(dst, ' ')
+	dst = appendSyslogName(dst, []byte(w.Host), 255)
+	dst = append(dst, ' ')
+	dst = appendSyslogName(dst, appName, 48)
+	dst = append(dst, ' ')
+	dst = strconv.AppendInt(dst, int64(os.Getpid()), 10)
+	dst = append(dst, ' ')
+	dst = appendSyslogName(dst, msgID, 32)
+	dst = append(dst, ' ')
+
+	w.collectFields(evt)
+	if len(evt.order) == 0 {
+		dst = append(dst, '-')
+	} else {
+		sdID := w.SDID
+		if sdID == "" {
+			sdID = DefaultSyslogSDID
+		}
+		dst = append(dst, '[')
+		dst = append(dst, sdID...)
+		for _, idx := range evt.order {
+			f := &evt.fields[idx]
+			dst = append(dst, ' ')
+			dst = appendSyslogName(dst, f.key, 32)
+			dst = append(dst, '=', '"')
+			for _, c := range f.value {
+				if c == '"' || c == '\\' || c == ']' {
+					dst = append(dst, '\\')
+				}
+				dst = append(dst, c)
+			}
+			dst = append(dst, '"')
+		}
+		dst = append(dst, ']')
+	}
+	if f, ok := evt.field(zerolog.MessageFieldName); ok && len(f.value) > 0 {
+		dst = append(dst, ' ')
+		dst = append(dst, f.value...)
+	}
+	return dst
+}
+
+// appendRFC3164 appends evt formatted as RFC 3164, the fields are appended to the message in logfmt:
+//
+//	<PRI>Mmm dd hh:mm:ss HOSTNAME TAG[PID]: MSG key=value...
+//
+// The HOSTNAME is omitted for the local daemon, which adds it itself.
+func (w *SyslogWriter) appendRFC3164(dst []byte, evt *consoleEvent) []byte {
+	dst = w.appendPriority(dst, evt)
+	dst = w.time(evt).AppendFormat(dst, time.Stamp)
+	if w.Network != SyslogNetworkUnix {
+		dst = append(dst, ' ')
+		dst = appendSyslogName(dst, []byte(w.Host), 255)
+	}
+	appName, _ := w.names(evt)
+	dst = append(dst, ' ')
+	dst = appendSyslogName(dst, appName, 32)
+	dst = append(dst, '[')
+	dst = strconv.AppendInt(dst, int64(os.Getpid()), 10)
+	dst = append(dst, ']', ':')
+	if f, ok := evt.field(zerolog.MessageFieldName); ok && len(f.value) > 0 {
+		dst = append(dst, ' ')
+		dst = append(dst, f.value...)
+	}
+	w.collectFields(evt)
+	for _, idx := range evt.order {
+		dst = appendLogfmtPair(dst, evt.fields[idx].key, &evt.fields[idx])
+	}
+	return dst
+}
+
+// collectFields collects the fields sent besides the header and the message into evt.order.
+func (w *SyslogWriter) collectFields(evt *consoleEvent) {
+	for i := range evt.fields {
+		switch string(evt.fields[i].key) {
+		case zerolog.LevelFieldName, zerolog.TimestampFieldName, zerolog.MessageFieldName, LabelFieldName:
+			continue
+		}
+		evt.order = append(evt.order, i)
+	}
+	evt.dedupOrder()
+}
+
+// appendSyslogName appends a header field or a PARAM-NAME of at most max printable ASCII bytes,
+// the others are replaced by '_', and "-" is appended for an empty name.
+func appendSyslogName(dst, name []byte, max int) []byte {
+	if len(name) == 0 {
+		return append(dst, '-')
+	}
+	if len(name) > max {
+		name = name[:max]
+	}
+	for _, c := range name {
+		if c <= ' ' || c >= 0x7f || c == '=' || c == ']' || c == '"' {
+			c = '_'
+		}
+		dst = append(dst, c)
+	}
+	return dst
+}
+
+// syslogSeverity returns the syslog severity of level l.
+func syslogSeverity(l Level) int {
+	switch l {
+	case TraceLevel, DebugLevel:
+		return 7 // debug
+	case InfoLevel:
+		return 6 // informational
+	case WarnLevel:
+		return 4 // warning
+	case ErrorLevel:
+		return 3 // error
+	case FatalLevel:
+		return 2 // critical
+	case PanicLevel:
+		return 0 // emergency
+	}
+	return 6
+}
@@ -0,0 +1,160 @@
+package rzerolog
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyslogMessage(t *testing.T) {
+	pid := strconv.Itoa(os.Getpid())
+	w, err := NewSyslogWriter(SyslogNetworkUDP, "127.0.0.1:514", WithSyslogHost("node1"),
+		WithSyslogAppName("app"), WithSyslogFacility(SyslogLocal0))
+	require.Nil(t, err)
+	evt := getConsoleEvent()
+	defer evt.release()
+	require.Nil(t, evt.parse([]byte(`{"level":"error","label":"p2p","time":"2022-02-11 16:04:05.123",`+
+		`"peer":"a\"]b","n":1,"message":"dial failed"}`)))
+
+	b := w.appendRFC5424(nil, evt)
+	ts := strings.Fields(string(b))[1]
+	require.True(t, strings.HasPrefix(ts, "2022-02-11T16:04:05.123000"))
+	require.Equal(t, `<131>1 `+ts+` node1 p2p `+pid+` - [fields@32473 peer="a\"\]b" n="1"] dial failed`, string(b))
+
+	w.LabelAsMsgID = true
+	evt.order = evt.order[:0]
+	b = w.appendRFC5424(nil, evt)
+	require.Equal(t, `<131>1 `+ts+` node1 app `+pid+` p2p [fields@32473 peer="a\"\]b" n="1"] dial failed`, string(b))
+
+	w.LabelAsMsgID = false
+	evt.order = evt.order[:0]
+	b = w.appendRFC3164(nil, evt)
+	require.Equal(t, `<131>Feb 11 16:04:05 node1 p2p[`+pid+`]: dial failed peer="a\"]b" n=1`, string(b))
+
+	_, err = ParseSyslogFacility("local7")
+	require.Nil(t, err)
+	_, err = NewSyslogWriter("unixgram", "")
+	require.NotNil(t, err)
+}
+
+func TestSyslogWriterTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer ln.Close()
+	received := make(chan string, 4)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				for {
+					// octet counting: MSG-LEN SP SYSLOG-MSG
+					l, err := r.ReadString(' ')
+					if err != nil {
+						return
+					}
+					n, _ := strconv.Atoi(strings.TrimSpace(l))
+					msg := make([]byte, n)
+					if _, err = io.ReadFull(r, msg); err != nil {
+						return
+					}
+					received <- string(msg)
+				}
+			}()
+		}
+	}()
+
+	w, err := NewSyslogWriter(SyslogNetworkTCP, ln.Addr().String(), WithSyslogAppName("app"))
+	require.Nil(t, err)
+	defer w.Close()
+	_, err = w.Write([]byte(`{"level":"info","message":"first"}`))
+	require.Nil(t, err)
+	require.True(t, strings.HasSuffix(<-received, " app "+strconv.Itoa(os.Getpid())+" - - first"))
+
+	// the broken connection is redialed
+	require.Nil(t, w.conn.conn.Close())
+	_, err = w.Write([]byte(`{"level":"warn","message":"second"}`))
+	require.Nil(t, err)
+	require.True(t, strings.HasPrefix(<-received, "<12>1 "))
+}
+
+func TestSyslogWriterUnix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rzerolog-syslog")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	addr := filepath.Join(dir, "log.sock")
+	conn, err := net.ListenPacket("unixgram", addr)
+	if err != nil {
+		t.Skip("unixgram is not supported:", err)
+	}
+	defer conn.Close()
+
+	l := NewRZeroLogger(DisableConsolePrint(), WithNoCaller(), WithLabel("p2p"),
+		WithSyslogOutput(SyslogNetworkUnix, addr, WithSyslogFormat(SyslogRFC3164)))
+	l.Warn().Str("peer", "a").Msg("dial failed")
+	buf := make([]byte, 1024)
+	n, _, err := conn.ReadFrom(buf)
+	require.Nil(t, err)
+	require.Nil(t, l.Close())
+	msg := string(buf[:n])
+	require.True(t, strings.HasPrefix(msg, "<12>"))
+	require.True(t, strings.HasSuffix(msg, " p2p["+strconv.Itoa(os.Getpid())+"]: dial failed peer=a"), msg)
+}
+
+func TestSyslogWriterUnixStream(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rzerolog-syslog")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	addr := filepath.Join(dir, "log.sock")
+
+	w, err := NewSyslogWriter(SyslogNetworkUnix, addr, WithSyslogFormat(SyslogRFC3164))
+	require.Nil(t, err)
+	_, err = w.Write([]byte(`{"level":"info","message":"a"}`))
+	require.Error(t, err)
+	// the absent daemon is not redialed for every event
+	_, err = w.Write([]byte(`{"level":"info","message":"b"}`))
+	require.Equal(t, ErrSinkBackoff, err)
+
+	ln, err := net.Listen("unix", addr)
+	require.Nil(t, err)
+	defer ln.Close()
+	received := make(chan string, 2)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			received <- line
+		}
+	}()
+
+	w.conn.retryAt = time.Now()
+	for _, msg := range []string{"first", `second\nline`} {
+		_, err = w.Write([]byte(`{"level":"info","message":"` + msg + `"}`))
+		require.Nil(t, err)
+	}
+	require.True(t, strings.HasSuffix(<-received, "]: first\n"))
+	require.True(t, strings.HasSuffix(<-received, "]: second#012line\n"))
+	require.Zero(t, w.conn.backoff)
+	require.Nil(t, w.Close())
+}
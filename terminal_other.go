@@ -0,0 +1,14 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd
+
+package rzerolog
+
+// isTerminalFd always reports false, the output is colorized on these platforms only if forced.
+func isTerminalFd(fd uintptr) bool {
+	return false
+}
+
+// terminalWidth always reports 0, the width is taken from the COLUMNS environment variable on these platforms.
+func terminalWidth(fd uintptr) int {
+	return 0
+}
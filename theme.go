@@ -0,0 +1,298 @@
+package rzerolog
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Color is a foreground color of the terminal, in the 16-color, 256-color or truecolor palette.
+// The zero value ColorDefault keeps the color of the terminal.
+type Color uint32
+
+const (
+	colorKindMask  Color = 0xff << 24
+	colorKindBasic Color = 1 << 24
+	colorKind256   Color = 2 << 24
+	colorKindRGB   Color = 3 << 24
+)
+
+// ColorDefault keeps the color of the terminal.
+const ColorDefault Color = 0
+
+// Colors of the 16-color palette.
+const (
+	ColorBlack Color = colorKindBasic | iota
+	ColorRed
+	ColorGreen
+	ColorYellow
+	ColorBlue
+	ColorMagenta
+	ColorCyan
+	ColorWhite
+	ColorBrightBlack
+	ColorBrightRed
+	ColorBrightGreen
+	ColorBrightYellow
+	ColorBrightBlue
+	ColorBrightMagenta
+	ColorBrightCyan
+	ColorBrightWhite
+)
+
+// Color256 returns the color n of the 256-color palette.
+func Color256(n uint8) Color {
+	return colorKind256 | Color(n)
+}
+
+// ColorRGB returns the truecolor with the given red, green and blue.
+func ColorRGB(r, g, b uint8) Color {
+	return colorKindRGB | Color(r)<<16 | Color(g)<<8 | Color(b)
+}
+
+var colorNames = map[string]Color{
+	"black": ColorBlack, "red": ColorRed, "green": ColorGreen, "yellow": ColorYellow,
+	"blue": ColorBlue, "magenta": ColorMagenta, "cyan": ColorCyan, "white": ColorWhite,
+	"bright-black": ColorBrightBlack, "bright-red": ColorBrightRed, "bright-green": ColorBrightGreen,
+	"bright-yellow": ColorBrightYellow, "bright-blue": ColorBrightBlue, "bright-magenta": ColorBrightMagenta,
+	"bright-cyan": ColorBrightCyan, "bright-white": ColorBrightWhite,
+}
+
+// ParseColor parses a color name of the 16-color palette such as "red" or "bright-red",
+// a number of the 256-color palette such as "208", or a truecolor such as "#ff8700".
+func ParseColor(s string) (Color, error) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	if c, ok := colorNames[name]; ok {
+		return c, nil
+	}
+	if strings.HasPrefix(name, "#") {
+		if v, err := strconv.ParseUint(name[1:], 16, 32); err == nil && len(name) == 7 {
+			return ColorRGB(uint8(v>>16), uint8(v>>8), uint8(v)), nil
+		}
+	} else if n, err := strconv.ParseUint(name, 10, 8); err == nil {
+		return Color256(uint8(n)), nil
+	}
+	return 0, fmt.Errorf("invalid color %q: a color name, a number 0-255 or \"#rrggbb\" is expected", s)
+}
+
+// appendCode appends the SGR parameters of the color c.
+func (c Color) appendCode(dst []byte) []byte {
+	v := int64(c &^ colorKindMask)
+	switch c & colorKindMask {
+	case colorKindBasic:
+		if v < 8 {
+			return strconv.AppendInt(dst, 30+v, 10)
+		}
+		return strconv.AppendInt(dst, 90+v-8, 10)
+	case colorKind256:
+		dst = append(dst, "38;5;"...)
+		return strconv.AppendInt(dst, v, 10)
+	case colorKindRGB:
+		dst = append(dst, "38;2;"...)
+		dst = strconv.AppendInt(dst, v>>16, 10)
+		dst = append(dst, ';')
+		dst = strconv.AppendInt(dst, v>>8&0xff, 10)
+		dst = append(dst, ';')
+		return strconv.AppendInt(dst, v&0xff, 10)
+	}
+	return dst
+}
+
+// Style is the color and attributes of a piece of text output.
+// The zero value prints the text unchanged.
+type Style struct {
+	Fg     Color
+	Bold   bool
+	Dim    bool
+	Italic bool
+}
+
+// appendStart appends the escape sequences which start the style.
+//
+// NOTE: Every attribute is a sequence of its own and closed by a reset of its own,
+// which keeps the output of DarkTheme the same as the colors before themes.
+func (s Style) appendStart(dst []byte) []byte {
+	if s.Bold {
+		dst = append(dst, "\x1b[1m"...)
+	}
+	if s.Dim {
+		dst = append(dst, "\x1b[2m"...)
+	}
+	if s.Italic {
+		dst = append(dst, "\x1b[3m"...)
+	}
+	if s.Fg != ColorDefault {
+		dst = append(dst, "\x1b["...)
+		dst = s.Fg.appendCode(dst)
+		dst = append(dst, 'm')
+	}
+	return dst
+}
+
+// appendEnd appends the escape sequences which end the style.
+func (s Style) appendEnd(dst []byte) []byte {
+	for _, on := range [...]bool{s.Bold, s.Dim, s.Italic, s.Fg != ColorDefault} {
+		if on {
+			dst = append(dst, "\x1b[0m"...)
+		}
+	}
+	return dst
+}
+
+// render returns s wrapped in the style.
+func (s Style) render(i interface{}) string {
+	if s == (Style{}) {
+		return fmt.Sprintf("%s", i)
+	}
+	b := s.appendStart(nil)
+	b = append(b, fmt.Sprintf("%v", i)...)
+	return string(s.appendEnd(b))
+}
+
+// Theme defines the styles of the parts and fields in the output of ConsoleWriter.
+type Theme struct {
+	LevelTrace   Style
+	LevelDebug   Style
+	LevelInfo    Style
+	LevelWarn    Style
+	LevelError   Style
+	LevelFatal   Style
+	LevelPanic   Style
+	LevelUnknown Style
+
+	Timestamp Style
+	Label     Style
+	Caller    Style
+	// FieldName is also the style of the mark " >" after the caller.
+	FieldName  Style
+	FieldValue Style
+	// Error is the style of the "error" field and the errors of formatting.
+	Error Style
+
+	// LabelPalette is the colors of labels picked by hash if ConsoleWriter.LabelColors is set.
+	LabelPalette []Color
+}
+
+const (
+	ThemeDarkName         = "dark"
+	ThemeLightName        = "light"
+	ThemeHighContrastName = "high-contrast"
+)
+
+var (
+	// noColorTheme is used when the colors are disabled.
+	noColorTheme = Theme{}
+
+	darkTheme = Theme{
+		LevelTrace:   Style{Fg: ColorMagenta},
+		LevelDebug:   Style{Fg: ColorYellow},
+		LevelInfo:    Style{Fg: ColorGreen},
+		LevelWarn:    Style{Fg: ColorRed},
+		LevelError:   Style{Fg: ColorRed, Bold: true},
+		LevelFatal:   Style{Fg: ColorRed, Bold: true},
+		LevelPanic:   Style{Fg: ColorRed, Bold: true},
+		LevelUnknown: Style{Bold: true},
+		Timestamp:    Style{Fg: ColorBrightBlack},
+		Label:        Style{Fg: ColorBlue},
+		Caller:       Style{Bold: true},
+		FieldName:    Style{Fg: ColorCyan},
+		Error:        Style{Fg: ColorRed},
+	}
+
+	lightTheme = Theme{
+		LevelTrace:   Style{Fg: Color256(127)},
+		LevelDebug:   Style{Fg: Color256(130)},
+		LevelInfo:    Style{Fg: Color256(28)},
+		LevelWarn:    Style{Fg: Color256(166)},
+		LevelError:   Style{Fg: Color256(160), Bold: true},
+		LevelFatal:   Style{Fg: Color256(160), Bold: true},
+		LevelPanic:   Style{Fg: Color256(160), Bold: true},
+		LevelUnknown: Style{Bold: true},
+		Timestamp:    Style{Fg: Color256(244), Italic: true},
+		Label:        Style{Fg: Color256(25)},
+		Caller:       Style{Bold: true},
+		FieldName:    Style{Fg: Color256(30)},
+		Error:        Style{Fg: Color256(160)},
+		LabelPalette: []Color{
+			Color256(25), Color256(28), Color256(90), Color256(94), Color256(124), Color256(130),
+			Color256(30), Color256(54), Color256(58), Color256(88), Color256(22), Color256(19),
+		},
+	}
+
+	highContrastTheme = Theme{
+		LevelTrace:   Style{Fg: ColorBrightMagenta, Bold: true},
+		LevelDebug:   Style{Fg: ColorBrightYellow, Bold: true},
+		LevelInfo:    Style{Fg: ColorBrightGreen, Bold: true},
+		LevelWarn:    Style{Fg: ColorRGB(255, 165, 0), Bold: true},
+		LevelError:   Style{Fg: ColorBrightRed, Bold: true},
+		LevelFatal:   Style{Fg: ColorBrightRed, Bold: true},
+		LevelPanic:   Style{Fg: ColorBrightRed, Bold: true},
+		LevelUnknown: Style{Fg: ColorBrightWhite, Bold: true},
+		Timestamp:    Style{Fg: ColorBrightWhite},
+		Label:        Style{Fg: ColorBrightBlue, Bold: true},
+		Caller:       Style{Fg: ColorBrightWhite, Bold: true},
+		FieldName:    Style{Fg: ColorBrightCyan},
+		FieldValue:   Style{Fg: ColorBrightWhite},
+		Error:        Style{Fg: ColorBrightRed, Bold: true},
+		LabelPalette: []Color{
+			ColorBrightRed, ColorBrightGreen, ColorBrightYellow, ColorBrightBlue, ColorBrightMagenta, ColorBrightCyan,
+		},
+	}
+)
+
+// DarkTheme returns the default theme for terminals with a dark background.
+func DarkTheme() *Theme {
+	return darkTheme.clone()
+}
+
+// LightTheme returns the theme for terminals with a light background, in the 256-color palette.
+func LightTheme() *Theme {
+	return lightTheme.clone()
+}
+
+// HighContrastTheme returns the theme with bold and bright colors.
+func HighContrastTheme() *Theme {
+	return highContrastTheme.clone()
+}
+
+// clone returns a copy of t which shares nothing with t.
+func (t Theme) clone() *Theme {
+	t.LabelPalette = append([]Color(nil), t.LabelPalette...)
+	return &t
+}
+
+// ThemeByName returns the built-in theme with the given name.
+// An empty name means DarkTheme.
+func ThemeByName(name string) (*Theme, error) {
+	switch name {
+	case "", ThemeDarkName:
+		return DarkTheme(), nil
+	case ThemeLightName:
+		return LightTheme(), nil
+	case ThemeHighContrastName:
+		return HighContrastTheme(), nil
+	}
+	return nil, fmt.Errorf("unsupported theme %q. supporting: %s %s %s", name,
+		ThemeDarkName, ThemeLightName, ThemeHighContrastName)
+}
+
+// level returns the style of the level l, which is a zerolog level name.
+func (t *Theme) level(l string) Style {
+	switch l {
+	case "trace":
+		return t.LevelTrace
+	case "debug":
+		return t.LevelDebug
+	case "info":
+		return t.LevelInfo
+	case "warn":
+		return t.LevelWarn
+	case "error":
+		return t.LevelError
+	case "fatal":
+		return t.LevelFatal
+	case "panic":
+		return t.LevelPanic
+	}
+	return t.LevelUnknown
+}
@@ -2,12 +2,15 @@ package rzerolog
 
 import (
 	"io"
-	"os"
 )
 
 // FileWriter is a writer for writing log into files.
+//
+// It is the format layer of LogFileWriter, which encodes the log records
+// and writes them to the output given by the storage layer.
 type FileWriter interface {
 	io.Writer
-	// SetOutput change the file which be written in.
-	SetOutput(file *os.File) error
+	// SetOutput change the output which be written in.
+	// The output may be a file or a stream wrapping it, such as a gzip stream.
+	SetOutput(w io.WriteCloser) error
 }